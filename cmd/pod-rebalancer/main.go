@@ -16,7 +16,6 @@ import (
 	appsv1 "k8s.io/api/apps/v1"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes"
 )
 
@@ -96,36 +95,9 @@ func getTargetReplicaSets(ctx context.Context, client kubernetes.Interface, ns s
 
 // getCandidatePods gets pod candidate.
 func getCandidatePods(ctx context.Context, client kubernetes.Interface, ns string, nodes []*v1.Node, replicas []*appsv1.ReplicaSet) ([]*rebalancer.ReplicaState, error) {
-	nodeMap := make(map[string]*v1.Node)
-	var stats []*rebalancer.ReplicaState
-	rsmap := make(map[types.UID]*rebalancer.ReplicaState)
-
-	for _, n := range nodes {
-		nodeMap[n.Name] = n
-	}
-
 	pods, err := client.CoreV1().Pods(ns).List(ctx, metav1.ListOptions{})
 	if err != nil {
 		return nil, fmt.Errorf("failed to list pod for: %s, error: %w", ns, err)
 	}
-	for _, po := range pods.Items {
-		if !kube.IsPodReadyRunning(po) {
-			continue
-		}
-		for _, rs := range replicas {
-			if !kube.IsPodOwnedBy(rs, &po) {
-				continue
-			}
-			postat := rebalancer.PodStatus{Pod: po.DeepCopy()}
-			rstat, ok := rsmap[rs.UID]
-			if !ok {
-				rstat = &rebalancer.ReplicaState{Replicaset: rs, Nodes: nodes}
-				rsmap[rs.UID] = rstat
-				stats = append(stats, rstat)
-			}
-			rstat.PodStatus = append(rstat.PodStatus, &postat)
-			break
-		}
-	}
-	return stats, nil
+	return rebalancer.BuildReplicaStates(ctx, pods.Items, nodes, replicas), nil
 }
@@ -26,20 +26,49 @@ package main
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
+	cccmd "github.com/norseto/k8s-watchdogs/internal/cmd/clean-completed"
 	cecmd "github.com/norseto/k8s-watchdogs/internal/cmd/clean-evicted"
+	cfcmd "github.com/norseto/k8s-watchdogs/internal/cmd/clean-failed"
+	cpcmd "github.com/norseto/k8s-watchdogs/internal/cmd/clean-pending"
+	crcmd "github.com/norseto/k8s-watchdogs/internal/cmd/clean-replicasets"
 	docmd "github.com/norseto/k8s-watchdogs/internal/cmd/delete-oldest"
+	dncmd "github.com/norseto/k8s-watchdogs/internal/cmd/drain-node"
+	pfcmd "github.com/norseto/k8s-watchdogs/internal/cmd/preflight"
 	rpcmd "github.com/norseto/k8s-watchdogs/internal/cmd/rebalance-pods"
+	racmd "github.com/norseto/k8s-watchdogs/internal/cmd/restart-all"
 	rdcmd "github.com/norseto/k8s-watchdogs/internal/cmd/restart-deploy"
+	rscmd "github.com/norseto/k8s-watchdogs/internal/cmd/restart-sts"
+	sccmd "github.com/norseto/k8s-watchdogs/internal/cmd/scale"
+	vercmd "github.com/norseto/k8s-watchdogs/internal/cmd/version"
+	"github.com/norseto/k8s-watchdogs/internal/config"
+	"github.com/norseto/k8s-watchdogs/internal/output"
+	"github.com/norseto/k8s-watchdogs/internal/preflight"
 	"github.com/norseto/k8s-watchdogs/pkg/kube/client"
 	"github.com/norseto/k8s-watchdogs/pkg/logger"
 	"github.com/spf13/cobra"
 )
 
 func main() {
+	var junitReport string
+	var timeout time.Duration
+
 	opts := &client.Options{}
-	ctx := client.WithContext(context.Background(), opts)
+	outOpts := &output.Options{}
+	pfOpts := &preflight.Options{}
+	cfgOpts := &config.Options{}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	ctx = client.WithContext(ctx, opts)
+	ctx = output.WithContext(ctx, outOpts)
+	ctx = output.WithResults(ctx)
+	ctx = preflight.WithContext(ctx, pfOpts)
 
 	var rootCmd = &cobra.Command{
 		Use:   "watchdogs",
@@ -51,16 +80,117 @@ func main() {
 	}
 	rootCmd.SetContext(ctx)
 	logger.InitCmdLogger(rootCmd)
+	cancel := setupTimeout(rootCmd, &timeout)
+	defer cancel()
+	setupConfig(rootCmd, cfgOpts)
+	cfgOpts.BindPFlags(rootCmd.PersistentFlags())
 	opts.BindPFlags(rootCmd.PersistentFlags())
+	outOpts.BindPFlags(rootCmd.PersistentFlags())
+	pfOpts.BindPFlags(rootCmd.PersistentFlags())
+	rootCmd.PersistentFlags().DurationVar(&timeout, "timeout", 0,
+		"Maximum duration of the command run (e.g. 30s, 5m). Zero means no timeout.")
+	rootCmd.PersistentFlags().StringVar(&junitReport, "junit-report", "", "Path to write a JUnit XML report of the run.")
 	rootCmd.AddCommand(
 		cecmd.NewCommand(),
 		rpcmd.NewCommand(),
 		docmd.NewCommand(),
 		rdcmd.NewCommand(),
+		rscmd.NewCommand(),
+		dncmd.NewCommand(),
+		racmd.NewCommand(),
+		cfcmd.NewCommand(),
+		cpcmd.NewCommand(),
+		cccmd.NewCommand(),
+		crcmd.NewCommand(),
+		sccmd.NewCommand(),
+		pfcmd.NewCommand(),
+		vercmd.NewCommand(),
 	)
+	output.RouteErrors(rootCmd)
 
-	if err := rootCmd.Execute(); err != nil {
+	cmd, err := rootCmd.ExecuteC()
+	if isTimeout(cmd, err) {
+		err = fmt.Errorf("command timed out after %v: %w", timeout, err)
+	} else if ctx.Err() != nil {
+		if err == nil {
+			err = ctx.Err()
+		}
+		err = fmt.Errorf("interrupted: %w", err)
+	}
+	if junitReport != "" {
+		writeJUnitReport(ctx, junitReport, cmd, err)
+	}
+	if err != nil {
 		logger.FromContext(ctx).Error(err, "Failed to execute command")
-		os.Exit(1)
+		cancel()
+		stop()
+		os.Exit(output.ExitCode(err))
+	}
+}
+
+// setupTimeout wraps the command context with the timeout before the command runs.
+// It returns a function that releases the resources of the timeout context.
+func setupTimeout(rootCmd *cobra.Command, timeout *time.Duration) context.CancelFunc {
+	cancel := func() {}
+	preRun := rootCmd.PersistentPreRun
+	rootCmd.PersistentPreRun = func(cmd *cobra.Command, args []string) {
+		if preRun != nil {
+			preRun(cmd, args)
+		}
+		if *timeout > 0 {
+			var ctx context.Context
+			ctx, cancel = context.WithTimeout(cmd.Context(), *timeout)
+			cmd.SetContext(ctx)
+		}
+	}
+	return func() { cancel() }
+}
+
+// setupConfig applies the default flag values of the config file before the other
+// pre-run hooks so that the logger and the command see them. The config file values
+// are applied only to flags that are not explicitly set.
+func setupConfig(rootCmd *cobra.Command, opts *config.Options) {
+	preRun := rootCmd.PersistentPreRun
+	rootCmd.PersistentPreRun = nil
+	rootCmd.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
+		cfg, err := config.Load(opts.Path())
+		if err != nil {
+			return err
+		}
+		if err := cfg.Apply(cmd.Flags()); err != nil {
+			return err
+		}
+		if preRun != nil {
+			preRun(cmd, args)
+		}
+		return nil
+	}
+}
+
+// isTimeout checks if the command failed because its context deadline was exceeded.
+func isTimeout(cmd *cobra.Command, err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	return cmd != nil && cmd.Context() != nil && errors.Is(cmd.Context().Err(), context.DeadlineExceeded)
+}
+
+// writeJUnitReport writes the results of the executed command as a JUnit XML report.
+// Each namespace result recorded by the command is a test case, and the command itself is
+// one when it recorded none or failed otherwise.
+func writeJUnitReport(ctx context.Context, path string, cmd *cobra.Command, err error) {
+	if cmd == nil || !cmd.Runnable() || !cmd.HasParent() {
+		return
+	}
+	namespace := ""
+	if f := cmd.Flags().Lookup("namespace"); f != nil {
+		namespace = f.Value.String()
+	}
+	results := output.CollectResults(ctx, namespace, cmd.Name(), err)
+	if werr := output.WriteJUnitFile(path, cmd.Root().Name(), results); werr != nil {
+		logger.FromContext(ctx).Error(werr, "failed to write junit report", "path", path)
 	}
 }
@@ -0,0 +1,136 @@
+/*
+MIT License
+
+Copyright (c) 2024 Norihiro Seto
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package checkpoint
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// Checkpoint records the UIDs of processed items so that a re-run after an
+// interruption skips them. A nil *Checkpoint records nothing.
+type Checkpoint struct {
+	path      string
+	mu        sync.Mutex
+	processed map[types.UID]struct{}
+	// saveMu serializes saves so that an older snapshot is never renamed over a newer one.
+	saveMu sync.Mutex
+}
+
+// file is the on-disk form of a Checkpoint.
+type file struct {
+	Processed []types.UID `json:"processed"`
+}
+
+// Load reads the checkpoint at path. A missing file yields an empty checkpoint.
+// An empty path returns nil, which disables checkpointing.
+func Load(path string) (*Checkpoint, error) {
+	if path == "" {
+		return nil, nil
+	}
+	c := &Checkpoint{path: path, processed: make(map[types.UID]struct{})}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read checkpoint %s: %w", path, err)
+	}
+	var f file
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("failed to parse checkpoint %s: %w", path, err)
+	}
+	for _, uid := range f.Processed {
+		c.processed[uid] = struct{}{}
+	}
+	return c, nil
+}
+
+// Has checks if the UID has already been processed.
+func (c *Checkpoint) Has(uid types.UID) bool {
+	if c == nil {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, ok := c.processed[uid]
+	return ok
+}
+
+// Add records the UID as processed. Call Save to persist it.
+func (c *Checkpoint) Add(uid types.UID) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.processed[uid] = struct{}{}
+}
+
+// Save writes the checkpoint atomically by renaming a temporary file in the
+// same directory over the checkpoint file. It is safe to call concurrently.
+func (c *Checkpoint) Save() error {
+	if c == nil {
+		return nil
+	}
+	c.saveMu.Lock()
+	defer c.saveMu.Unlock()
+
+	c.mu.Lock()
+	f := file{Processed: make([]types.UID, 0, len(c.processed))}
+	for uid := range c.processed {
+		f.Processed = append(f.Processed, uid)
+	}
+	c.mu.Unlock()
+	sort.Slice(f.Processed, func(i, j int) bool { return f.Processed[i] < f.Processed[j] })
+
+	data, err := json.Marshal(f)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(c.path), filepath.Base(c.path)+".tmp*")
+	if err != nil {
+		return fmt.Errorf("failed to write checkpoint %s: %w", c.path, err)
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to write checkpoint %s: %w", c.path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write checkpoint %s: %w", c.path, err)
+	}
+	if err := os.Rename(tmp.Name(), c.path); err != nil {
+		return fmt.Errorf("failed to write checkpoint %s: %w", c.path, err)
+	}
+	return nil
+}
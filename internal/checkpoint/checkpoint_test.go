@@ -0,0 +1,98 @@
+/*
+MIT License
+
+Copyright (c) 2024 Norihiro Seto
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package checkpoint
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestCheckpoint(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "checkpoint.json")
+
+	c, err := Load(path)
+	assert.NoError(t, err)
+	assert.False(t, c.Has("uid-1"))
+
+	c.Add("uid-2")
+	c.Add("uid-1")
+	assert.True(t, c.Has("uid-1"))
+	assert.NoError(t, c.Save())
+
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"processed":["uid-1","uid-2"]}`, string(data))
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	assert.Len(t, entries, 1, "temporary files must be removed")
+
+	resumed, err := Load(path)
+	assert.NoError(t, err)
+	assert.True(t, resumed.Has("uid-1"))
+	assert.True(t, resumed.Has("uid-2"))
+	assert.False(t, resumed.Has("uid-3"))
+
+	assert.NoError(t, os.WriteFile(path, []byte("broken"), 0o600))
+	_, err = Load(path)
+	assert.Error(t, err)
+}
+
+func TestCheckpoint_ConcurrentSave(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "checkpoint.json")
+	c, err := Load(path)
+	assert.NoError(t, err)
+
+	// Each goroutine adds a UID then saves, like the namespaces processed concurrently.
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(uid types.UID) {
+			defer wg.Done()
+			c.Add(uid)
+			assert.NoError(t, c.Save())
+		}(types.UID(fmt.Sprintf("uid-%d", i)))
+	}
+	wg.Wait()
+
+	resumed, err := Load(path)
+	assert.NoError(t, err)
+	for i := 0; i < 20; i++ {
+		assert.True(t, resumed.Has(types.UID(fmt.Sprintf("uid-%d", i))), i)
+	}
+}
+
+func TestCheckpoint_Disabled(t *testing.T) {
+	c, err := Load("")
+	assert.NoError(t, err)
+	assert.Nil(t, c)
+	c.Add("uid-1")
+	assert.False(t, c.Has("uid-1"))
+	assert.NoError(t, c.Save())
+}
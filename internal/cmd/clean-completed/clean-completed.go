@@ -0,0 +1,169 @@
+/*
+MIT License
+
+Copyright (c) 2024 Norihiro Seto
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cleancompleted
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/norseto/k8s-watchdogs/internal/options"
+	"github.com/norseto/k8s-watchdogs/internal/preflight"
+	"github.com/norseto/k8s-watchdogs/internal/validation"
+	"github.com/norseto/k8s-watchdogs/pkg/kube"
+	"github.com/norseto/k8s-watchdogs/pkg/kube/client"
+	"github.com/norseto/k8s-watchdogs/pkg/logger"
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// NewCommand returns a new Cobra command for cleaning completed standalone pods.
+func NewCommand() *cobra.Command {
+	var ccOpts cleanOptions
+
+	opts := &options.Options{}
+	cmd := &cobra.Command{
+		Use:   "clean-completed",
+		Short: "Clean completed pods not managed by any controller",
+		Long: "Clean succeeded pods that have no controller owner, e.g. pods run manually. " +
+			"Pods owned by a Job are left to the Job cleanup.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			ccOpts.namespace = opts.Namespace()
+			ccOpts.namespaceScope = opts.NamespaceScope()
+			if err := ccOpts.validate(); err != nil {
+				logger.FromContext(ctx).Error(err, "invalid options")
+				return err
+			}
+			clnt, err := client.NewClientset(client.FromContext(ctx))
+			if err != nil {
+				logger.FromContext(ctx).Error(err, "failed to create clientset")
+				return err
+			}
+			if done, err := preflight.Run(ctx, clnt); done {
+				return err
+			}
+			return cleanCompletedPods(ctx, clnt, ccOpts)
+		},
+	}
+	opts.BindCommonFlags(cmd)
+	opts.BindNamespaceScopeFlags(cmd)
+
+	flg := cmd.Flags()
+	flg.DurationVar(&ccOpts.olderThan, "older-than", defaultOlderThan,
+		"Only delete pods completed longer ago than this duration (e.g. 30m, 2h).")
+	flg.IntVar(&ccOpts.maxDeletions, "max-deletions", defaultMaxDeletions,
+		"Maximum number of pods to delete in a run. Zero or less means unlimited.")
+	return cmd
+}
+
+const (
+	defaultMaxDeletions = 100
+	defaultOlderThan    = 24 * time.Hour
+)
+
+// cleanOptions represents options for cleaning completed pods.
+type cleanOptions struct {
+	namespace      string
+	namespaceScope kube.NamespaceScope
+	olderThan      time.Duration
+	maxDeletions   int
+}
+
+// now returns the current time. It is replaced in tests.
+var now = time.Now
+
+// validate checks the namespace and the age of the pods.
+func (o cleanOptions) validate() error {
+	if err := validation.ValidateNamespace(o.namespace); err != nil {
+		return err
+	}
+	if o.olderThan < 0 {
+		return fmt.Errorf("invalid older-than %v: must not be negative", o.olderThan)
+	}
+	return nil
+}
+
+// +kubebuilder:rbac:groups=core,resources=pods,verbs=get;list;delete
+
+// cleanCompletedPods deletes completed standalone pods in the specified namespace.
+func cleanCompletedPods(ctx context.Context, client kubernetes.Interface, opts cleanOptions) error {
+	log := logger.FromContext(ctx)
+
+	pods, err := kube.ListAllPods(ctx, client, opts.namespace, metav1.ListOptions{})
+	if err != nil {
+		log.Error(err, "failed to list pods", "namespace", opts.namespace)
+		return err
+	}
+
+	targets := kube.FilterPods(&corev1.PodList{Items: pods}, func(pod *corev1.Pod) bool {
+		return opts.namespaceScope.Match(pod) && isTarget(pod, opts.olderThan)
+	})
+
+	deleted := 0
+	for _, pod := range targets {
+		if err := ctx.Err(); err != nil {
+			log.Info("interrupted", "deleted", deleted, "targets", len(targets))
+			return err
+		}
+		if opts.maxDeletions > 0 && deleted >= opts.maxDeletions {
+			log.Info("reached the deletion cap", "max", opts.maxDeletions)
+			break
+		}
+		if err := kube.DeletePod(ctx, client, *pod); err != nil {
+			log.Error(err, "failed to delete pod", "pod", fmt.Sprintf("%s/%s", pod.Namespace, pod.Name))
+			continue
+		}
+		deleted++
+	}
+
+	log.Info("pods delete result", "deleted", deleted, "targets", len(targets))
+	return nil
+}
+
+// isTarget checks if the pod is a completed standalone pod that finished longer ago than olderThan.
+func isTarget(pod *corev1.Pod, olderThan time.Duration) bool {
+	if kube.IsPodTerminating(pod) || !kube.IsCompletedStandalonePod(pod) {
+		return false
+	}
+	return now().Sub(finishedTime(pod)) > olderThan
+}
+
+// finishedTime returns the time the last container of the pod terminated.
+// It falls back to the start time of the pod when no container reports it.
+func finishedTime(pod *corev1.Pod) time.Time {
+	var finished time.Time
+	for _, s := range pod.Status.ContainerStatuses {
+		if t := s.State.Terminated; t != nil && t.FinishedAt.After(finished) {
+			finished = t.FinishedAt.Time
+		}
+	}
+	if finished.IsZero() {
+		return kube.PodStartTime(pod)
+	}
+	return finished
+}
@@ -0,0 +1,135 @@
+/*
+MIT License
+
+Copyright (c) 2024 Norihiro Seto
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cleancompleted
+
+import (
+	"context"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+var testNow = time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+func testPod(name string, phase corev1.PodPhase, owner string, age time.Duration) *corev1.Pod {
+	p := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			Namespace:         "default",
+			CreationTimestamp: metav1.NewTime(testNow.Add(-age - time.Hour)),
+		},
+		Status: corev1.PodStatus{
+			Phase: phase,
+			ContainerStatuses: []corev1.ContainerStatus{{State: corev1.ContainerState{
+				Terminated: &corev1.ContainerStateTerminated{FinishedAt: metav1.NewTime(testNow.Add(-age))},
+			}}},
+		},
+	}
+	if owner != "" {
+		controller := true
+		p.OwnerReferences = []metav1.OwnerReference{{Kind: owner, Name: "owner", Controller: &controller}}
+	}
+	return p
+}
+
+func TestCleanCompletedPods(t *testing.T) {
+	defer func(f func() time.Time) { now = f }(now)
+	now = func() time.Time { return testNow }
+
+	objects := func() []runtime.Object {
+		return []runtime.Object{
+			testPod("completed-old", corev1.PodSucceeded, "", 2*time.Hour),
+			testPod("completed-older", corev1.PodSucceeded, "", 3*time.Hour),
+			testPod("completed-new", corev1.PodSucceeded, "", 10*time.Minute),
+			testPod("job", corev1.PodSucceeded, "Job", 2*time.Hour),
+			testPod("failed", corev1.PodFailed, "", 2*time.Hour),
+			testPod("running", corev1.PodRunning, "", 2*time.Hour),
+		}
+	}
+
+	tests := []struct {
+		name      string
+		opts      cleanOptions
+		remaining []string
+		wantErr   bool
+	}{
+		{"Default", cleanOptions{namespace: "default", olderThan: time.Hour},
+			[]string{"completed-new", "failed", "job", "running"}, false},
+		{"OlderThan", cleanOptions{namespace: "default", olderThan: 0},
+			[]string{"failed", "job", "running"}, false},
+		{"MaxDeletions", cleanOptions{namespace: "default", olderThan: time.Hour, maxDeletions: 1},
+			[]string{"completed-new", "completed-older", "failed", "job", "running"}, false},
+		{"NegativeOlderThan", cleanOptions{namespace: "default", olderThan: -time.Hour},
+			[]string{"completed-new", "completed-old", "completed-older", "failed", "job", "running"}, true},
+		{"InvalidNamespace", cleanOptions{namespace: "Invalid_NS"},
+			[]string{"completed-new", "completed-old", "completed-older", "failed", "job", "running"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			client := fake.NewSimpleClientset(objects()...)
+
+			err := tt.opts.validate()
+			if err == nil {
+				err = cleanCompletedPods(ctx, client, tt.opts)
+			}
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			pods, err := client.CoreV1().Pods("default").List(ctx, metav1.ListOptions{})
+			assert.NoError(t, err)
+			var names []string
+			for _, p := range pods.Items {
+				names = append(names, p.Name)
+			}
+			sort.Strings(names)
+			assert.Equal(t, tt.remaining, names)
+		})
+	}
+}
+
+func TestFinishedTime(t *testing.T) {
+	pod := testPod("completed", corev1.PodSucceeded, "", time.Hour)
+	assert.Equal(t, testNow.Add(-time.Hour), finishedTime(pod))
+
+	pod.Status.ContainerStatuses = nil
+	assert.Equal(t, pod.CreationTimestamp.Time, finishedTime(pod))
+}
+
+func TestNewCommand(t *testing.T) {
+	cmd := NewCommand()
+	assert.NotNil(t, cmd)
+	assert.Equal(t, "clean-completed", cmd.Use)
+}
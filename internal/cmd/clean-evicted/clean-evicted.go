@@ -26,15 +26,27 @@ package cleanevicted
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"k8s.io/client-go/kubernetes"
 
+	"github.com/norseto/k8s-watchdogs/internal/checkpoint"
+	"github.com/norseto/k8s-watchdogs/internal/concurrent"
 	"github.com/norseto/k8s-watchdogs/internal/options"
+	"github.com/norseto/k8s-watchdogs/internal/output"
+	"github.com/norseto/k8s-watchdogs/internal/preflight"
+	"github.com/norseto/k8s-watchdogs/internal/validation"
+	"github.com/norseto/k8s-watchdogs/pkg/generics"
 	"github.com/norseto/k8s-watchdogs/pkg/kube"
 	"github.com/norseto/k8s-watchdogs/pkg/kube/client"
 	"github.com/norseto/k8s-watchdogs/pkg/logger"
 	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -42,48 +54,397 @@ import (
 // It creates and returns a command with the given Use and Short descriptions,
 // and sets the Run function to execute the cleanEvictedPods function.
 func NewCommand() *cobra.Command {
+	var ceOpts cleanOptions
+	var propagation string
+
 	opts := &options.Options{}
 	cmd := &cobra.Command{
 		Use:   "clean-evicted",
 		Short: "Clean evicted pods",
 		RunE: func(cmd *cobra.Command, args []string) error {
 			ctx := cmd.Context()
+			if ceOpts.allNamespaces && cmd.Flags().Changed("namespace") {
+				err := errors.New("--namespace cannot be used with --all-namespaces")
+				logger.FromContext(ctx).Error(err, "invalid namespace")
+				return err
+			}
+			policy, err := kube.ParsePropagationPolicy(propagation)
+			if err != nil {
+				logger.FromContext(ctx).Error(err, "invalid propagation policy")
+				return err
+			}
+			ceOpts.propagation = policy
+			if ceOpts.callTimeout, err = opts.CallTimeout(); err != nil {
+				logger.FromContext(ctx).Error(err, "invalid call timeout")
+				return err
+			}
+			ceOpts.namespace = opts.Namespace()
+			ceOpts.priorityClass = opts.PriorityClassFilter()
+			ceOpts.namespaceScope = opts.NamespaceScope()
+			if !ceOpts.allNamespaces && ceOpts.namespace != metav1.NamespaceAll && !cmd.Flags().Changed("exclude-namespace") {
+				// The system namespaces are only excluded by default from sweeps across namespaces.
+				ceOpts.namespaceScope.Exclude = nil
+			}
+			ceOpts.reportOnly = opts.ReportOnly()
+			ceOpts.failOnFindings = opts.FailOnFindings()
+			if err := ceOpts.validate(); err != nil {
+				logger.FromContext(ctx).Error(err, "invalid options")
+				return err
+			}
 			clnt, err := client.NewClientset(client.FromContext(ctx))
 			if err != nil {
 				logger.FromContext(ctx).Error(err, "failed to create clientset")
 				return err
 			}
-			return cleanEvictedPods(cmd.Context(), clnt, opts.Namespace())
+			if done, err := preflight.Run(ctx, clnt); done {
+				return err
+			}
+			return cleanEvictedPods(cmd.Context(), clnt, ceOpts)
 		},
 	}
 	opts.BindCommonFlags(cmd)
+	opts.BindPriorityClassFlags(cmd)
+	opts.BindNamespaceScopeFlags(cmd, systemNamespaces...)
+	opts.BindReportFlags(cmd)
+	opts.BindCallTimeoutFlags(cmd)
+
+	flg := cmd.Flags()
+	flg.BoolVarP(&ceOpts.allNamespaces, "all-namespaces", "A", false,
+		"Delete evicted pods across all namespaces. Cannot be used with --namespace.")
+	flg.IntVar(&ceOpts.maxDeletions, "max-deletions", 0,
+		"Maximum number of pods to delete in a run across all namespaces. Zero or less means unlimited.")
+	flg.IntVar(&ceOpts.parallelism, "namespace-parallelism", 1,
+		"Number of namespaces processed concurrently when targeting all namespaces.")
+	flg.DurationVar(&ceOpts.minAge, "min-age", 0,
+		"Only delete evicted pods started at least this long ago (e.g. 10m). Zero deletes regardless of age.")
+	flg.DurationVar(&ceOpts.since, "since", 0,
+		"Only delete evicted pods whose conditions last changed at least this long ago (e.g. 5m), "+
+			"falling back to the creation time without conditions. Zero deletes regardless of the transition time.")
+	flg.IntVar(&ceOpts.retries, "retries", 0,
+		"Number of retries of a pod deletion failed with a conflict or server error. Zero makes a single attempt.")
+	flg.DurationVar(&ceOpts.deleteInterval, "delete-interval", 0,
+		"Wait this duration plus a small jitter between successive pod deletions (e.g. 2s). Zero deletes without waiting.")
+	flg.StringVar(&ceOpts.checkpoint, "checkpoint", "",
+		fmt.Sprintf("Path of a file recording deleted pods so that a re-run after an interruption skips them. "+
+			"It is saved after every %d deletions and at the end of the run.", checkpointBatchSize))
+	flg.StringSliceVar(&ceOpts.reasons, "reasons", []string{kube.ReasonEvicted},
+		"Status reasons of failed pods to delete (e.g. Evicted,Preempted,Shutdown).")
+	flg.BoolVar(&ceOpts.skipDaemonSet, "skip-daemonset", false,
+		"Do not delete evicted pods owned by a DaemonSet.")
+	flg.BoolVar(&ceOpts.verbose, "verbose", false,
+		"Log the node, owner and age of each deleted pod, and a breakdown of the evicted pods by node and by owner kind.")
+	flg.StringVar(&propagation, "propagation", "",
+		"Propagation policy of the deletion: Background, Foreground or Orphan. Empty uses the server default.")
 	return cmd
 }
 
+// checkpointBatchSize is the number of deletions after which the checkpoint is saved,
+// so that a run killed without a chance to save loses at most a batch of progress.
+const checkpointBatchSize = 10
+
+// systemNamespaces are the namespaces excluded by default when targeting all namespaces.
+var systemNamespaces = []string{"kube-system", "kube-public"}
+
+// cleanOptions represents options for cleaning evicted pods.
+type cleanOptions struct {
+	namespace      string
+	allNamespaces  bool
+	priorityClass  kube.PriorityClassFilter
+	namespaceScope kube.NamespaceScope
+	maxDeletions   int
+	parallelism    int
+	minAge         time.Duration
+	since          time.Duration
+	checkpoint     string
+	retries        int
+	deleteInterval time.Duration
+	propagation    *metav1.DeletionPropagation
+	skipDaemonSet  bool
+	reasons        []string
+	reportOnly     bool
+	failOnFindings bool
+	// callTimeout bounds each list and delete call. 0 disables it.
+	callTimeout time.Duration
+	// verbose logs each deleted pod and the breakdown of the evicted pods.
+	verbose bool
+}
+
+// validate checks the namespace, unless all namespaces are targeted, and the excluded namespaces.
+func (o cleanOptions) validate() error {
+	if !o.allNamespaces {
+		if err := validation.ValidateNamespace(o.namespace); err != nil {
+			return err
+		}
+	}
+	for ns := range o.namespaceScope.Exclude {
+		if err := validation.ValidateNamespace(ns); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// now returns the current time. It is replaced in tests.
+var now = time.Now
+
 // +kubebuilder:rbac:groups=core,resources=pods,verbs=get;list;delete
 // +kubebuilder:rbac:groups=core,resources=pods/status,verbs=get
+// +kubebuilder:rbac:groups=core,resources=namespaces,verbs=list
 
 // cleanEvictedPods cleans up evicted pods in the specified namespace.
-func cleanEvictedPods(ctx context.Context, client kubernetes.Interface, namespace string) error {
+// When all namespaces are targeted with a parallelism greater than 1, the namespaces are
+// processed concurrently while the deletion cap is shared across them.
+// With allNamespaces, the namespace is ignored and pods are listed across the cluster.
+// With reportOnly, the evicted pods are only listed. With failOnFindings, an error wrapping
+// output.ErrFindings is returned when evicted pods are found.
+func cleanEvictedPods(ctx context.Context, client kubernetes.Interface, opts cleanOptions) error {
 	log := logger.FromContext(ctx)
 
-	pods, err := client.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{})
+	if opts.allNamespaces {
+		opts.namespace = metav1.NamespaceAll
+	}
+
+	namespaces := []string{opts.namespace}
+	if opts.namespace == metav1.NamespaceAll && opts.parallelism > 1 {
+		var all []string
+		err := concurrent.CallWithTimeout(ctx, opts.callTimeout, func(ctx context.Context) (err error) {
+			all, err = kube.GetAllNamespaceNames(ctx, client)
+			return err
+		})
+		if err != nil {
+			log.Error(err, "failed to list namespaces")
+			return err
+		}
+		namespaces = generics.Filter(all, opts.namespaceScope.MatchNamespace)
+	}
+
+	cp, err := checkpoint.Load(opts.checkpoint)
 	if err != nil {
-		log.Error(err, "failed to list pods")
+		log.Error(err, "failed to load checkpoint")
+		return err
+	}
+
+	budget := concurrent.NewBudget(opts.maxDeletions)
+	pacer := concurrent.NewPacer(opts.deleteInterval)
+	var evicted atomic.Int32
+	var mu sync.Mutex
+	deleted := map[string]int{}
+	var bd *breakdown
+	if opts.verbose {
+		bd = newBreakdown()
+	}
+	err = concurrent.ForEach(ctx, namespaces, opts.parallelism, func(ctx context.Context, ns string) error {
+		n, counts, err := cleanNamespace(ctx, client, ns, opts, budget, pacer, cp, bd)
+		output.RecordResult(ctx, output.Result{Namespace: ns, Operation: "clean-evicted", Err: err})
+		evicted.Add(int32(n))
+		mu.Lock()
+		defer mu.Unlock()
+		for k, v := range counts {
+			deleted[k] += v
+		}
+		return err
+	})
+
+	for ns, n := range deleted {
+		log.V(1).Info("deleted pods in namespace", "namespace", ns, "deleted", n)
+	}
+	if bd != nil {
+		bd.log(ctx)
+	}
+	log.Info("pods delete result", "deleted", budget.Used(), "evicted", evicted.Load(), "namespaces", len(deleted),
+		"reportOnly", opts.reportOnly)
+	if err == nil && opts.failOnFindings && evicted.Load() > 0 {
+		return output.Findings(int(evicted.Load()), "evicted pods")
+	}
+	return err
+}
+
+// isEvicted checks if the pod failed with one of the reasons, or was evicted if reasons are empty.
+func isEvicted(pod *corev1.Pod, reasons []string) bool {
+	if len(reasons) == 0 {
+		return kube.IsEvictedPod(pod)
+	}
+	return kube.IsFailedPodWithReason(pod, reasons)
+}
+
+// cleanNamespace deletes evicted pods in a namespace while the budget allows.
+// Successive deletions, also in other namespaces, are spaced out by the pacer.
+// Pods recorded in the checkpoint are skipped and the deleted pods are saved to it after
+// every checkpointBatchSize deletions and once the namespace is done or the context is canceled. The evicted pods found, except the ones
+// skipped by skipPod, are added to the breakdown when not nil. It returns the number of those pods
+// and the numbers of deleted pods keyed by their namespaces.
+func cleanNamespace(ctx context.Context, client kubernetes.Interface, namespace string, opts cleanOptions, budget *concurrent.Budget, pacer *concurrent.Pacer, cp *checkpoint.Checkpoint, bd *breakdown) (int, map[string]int, error) {
+	log := logger.FromContext(ctx)
+
+	var pods []corev1.Pod
+	err := concurrent.CallWithTimeout(ctx, opts.callTimeout, func(ctx context.Context) (err error) {
+		pods, err = kube.ListAllPods(ctx, client, namespace, metav1.ListOptions{})
 		return err
+	})
+	if err != nil {
+		log.Error(err, "failed to list pods", "namespace", namespace)
+		return 0, nil, err
+	}
+
+	evictedPods := kube.FilterPods(&corev1.PodList{Items: pods}, func(pod *corev1.Pod) bool {
+		return isEvicted(pod, opts.reasons) && opts.priorityClass.Match(pod) &&
+			!(opts.skipDaemonSet && kube.IsOwnedByDaemonSet(pod))
+	})
+
+	deleted := map[string]int{}
+	var skipped []*corev1.Pod
+	evictedPods, skipped = generics.Partition(evictedPods, func(pod *corev1.Pod) bool {
+		return !opts.namespaceScope.Exclude.Has(pod.Namespace)
+	})
+	for ns, n := range generics.MakeMap(skipped, func(pod *corev1.Pod) string { return pod.Namespace },
+		func(_ *corev1.Pod, v int) int { return v + 1 }, nil) {
+		log.Info("skip evicted pods in excluded namespace", "namespace", ns, "pods", n)
+	}
+	evictedPods = generics.Filter(evictedPods, func(pod *corev1.Pod) bool {
+		return opts.namespaceScope.Match(pod) && !skipPod(ctx, pod, opts, cp)
+	})
+
+	if bd != nil {
+		bd.add(evictedPods)
 	}
 
-	evictedPods := kube.FilterPods(pods, kube.IsEvictedPod)
+	if opts.reportOnly {
+		for _, pod := range evictedPods {
+			log.Info("found evicted pod", "pod", fmt.Sprintf("%s/%s", pod.Namespace, pod.Name),
+				"reason", pod.Status.Reason, "age", now().Sub(kube.PodStartTime(pod)).Truncate(time.Second))
+		}
+		return len(evictedPods), deleted, ctx.Err()
+	}
 
-	deleted := 0
+	processed := 0
 	for _, pod := range evictedPods {
-		if err := kube.DeletePod(ctx, client, *pod); err != nil {
+		if ctx.Err() != nil {
+			break
+		}
+		if !budget.Take() {
+			log.Info("reached the deletion cap", "max", opts.maxDeletions)
+			break
+		}
+		if err := pacer.Wait(ctx); err != nil {
+			budget.Release()
+			break
+		}
+		kube.AuditPodDeletion(ctx, pod, "evicted", "clean-evicted")
+		err := concurrent.CallWithTimeout(ctx, opts.callTimeout, func(ctx context.Context) error {
+			return kube.DeletePodWithPolicyAndRetry(ctx, client, *pod, opts.propagation, opts.retries+1, kube.DefaultDeleteRetryBackoff)
+		})
+		if err != nil {
 			log.Error(err, "failed to delete pod", "pod", fmt.Sprintf("%s/%s", pod.Namespace, pod.Name))
-		} else {
-			deleted++
+			budget.Release()
+			continue
+		}
+		if opts.verbose {
+			log.Info("deleted evicted pod", "pod", fmt.Sprintf("%s/%s", pod.Namespace, pod.Name),
+				"node", pod.Spec.NodeName, "owner", ownerOf(pod), "age", now().Sub(kube.PodStartTime(pod)).Truncate(time.Second))
 		}
+		cp.Add(pod.UID)
+		deleted[pod.Namespace]++
+		if processed++; processed%checkpointBatchSize == 0 {
+			if err := cp.Save(); err != nil {
+				log.Error(err, "failed to save checkpoint", "namespace", namespace)
+				return len(evictedPods), deleted, err
+			}
+		}
+	}
+	if err := cp.Save(); err != nil {
+		log.Error(err, "failed to save checkpoint", "namespace", namespace)
+		return len(evictedPods), deleted, err
 	}
+	if err := ctx.Err(); err != nil {
+		log.Info("interrupted", "namespace", namespace)
+		return len(evictedPods), deleted, err
+	}
+	return len(evictedPods), deleted, nil
+}
 
-	log.Info("pods delete result", "deleted", deleted, "evicted", len(evictedPods))
-	return nil
+// skipPod checks if the evicted pod is skipped because it is recorded in the checkpoint, or it is
+// younger than minAge or failed more recently than since, and logs the reason.
+// Skipped pods are neither deleted nor reported as findings.
+func skipPod(ctx context.Context, pod *corev1.Pod, opts cleanOptions, cp *checkpoint.Checkpoint) bool {
+	log := logger.FromContext(ctx)
+
+	if cp.Has(pod.UID) {
+		log.V(1).Info("skip processed pod", "pod", fmt.Sprintf("%s/%s", pod.Namespace, pod.Name))
+		return true
+	}
+	if age := now().Sub(kube.PodStartTime(pod)); age < opts.minAge {
+		log.V(1).Info("skip young evicted pod", "pod", fmt.Sprintf("%s/%s", pod.Namespace, pod.Name),
+			"age", age.Truncate(time.Second), "minAge", opts.minAge)
+		return true
+	}
+	if failed := now().Sub(kube.PodLastTransitionTime(pod)); failed < opts.since {
+		log.V(1).Info("skip recently evicted pod", "pod", fmt.Sprintf("%s/%s", pod.Namespace, pod.Name),
+			"since", failed.Truncate(time.Second), "minSince", opts.since)
+		return true
+	}
+	return false
+}
+
+// ownerOf returns the controller of the pod in the form of kind/name, or empty if not controlled.
+func ownerOf(pod *corev1.Pod) string {
+	if ref := metav1.GetControllerOf(pod); ref != nil {
+		return ref.Kind + "/" + ref.Name
+	}
+	return ""
+}
+
+// noneKey is the breakdown key of the pods without a node or a controller.
+const noneKey = "<none>"
+
+// breakdown counts the evicted pods by node and by owner kind, shared across namespaces.
+type breakdown struct {
+	mu          sync.Mutex
+	byNode      map[string]int
+	byOwnerKind map[string]int
+}
+
+// newBreakdown returns a new empty breakdown.
+func newBreakdown() *breakdown {
+	return &breakdown{byNode: map[string]int{}, byOwnerKind: map[string]int{}}
+}
+
+// add counts the pods.
+func (b *breakdown) add(pods []*corev1.Pod) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, pod := range pods {
+		node, kind := pod.Spec.NodeName, noneKey
+		if node == "" {
+			node = noneKey
+		}
+		if ref := metav1.GetControllerOf(pod); ref != nil {
+			kind = ref.Kind
+		}
+		b.byNode[node]++
+		b.byOwnerKind[kind]++
+	}
+}
+
+// log logs the counts as key=count in descending order of the count so that a node producing
+// most evictions stands out.
+func (b *breakdown) log(ctx context.Context) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	logger.FromContext(ctx).Info("evicted pods breakdown",
+		"byNode", sortedCounts(b.byNode), "byOwnerKind", sortedCounts(b.byOwnerKind))
+}
+
+// sortedCounts returns the counts as key=count in descending order of the count, breaking ties by key.
+func sortedCounts(counts map[string]int) []string {
+	keys := make([]string, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if counts[keys[i]] != counts[keys[j]] {
+			return counts[keys[i]] > counts[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	return generics.Convert(keys, func(k string) string { return fmt.Sprintf("%s=%d", k, counts[k]) }, nil)
 }
@@ -27,19 +27,32 @@ package cleanevicted
 import (
 	"context"
 	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"k8s.io/client-go/kubernetes/fake"
 
+	"github.com/go-logr/logr/funcr"
+	"github.com/norseto/k8s-watchdogs/internal/output"
+	"github.com/norseto/k8s-watchdogs/pkg/kube"
+	"github.com/norseto/k8s-watchdogs/pkg/logger"
 	"github.com/stretchr/testify/assert"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	k8stesting "k8s.io/client-go/testing"
 )
 
 func TestCleanEvictedPods(t *testing.T) {
 	tests := []struct {
 		name        string
 		pods        []v1.Pod
+		opts        cleanOptions
 		wantErr     bool
 		wantDeleted int
 	}{
@@ -90,6 +103,34 @@ func TestCleanEvictedPods(t *testing.T) {
 			wantErr:     false,
 			wantDeleted: 1,
 		},
+		{
+			name: "PriorityClassFilter",
+			pods: []v1.Pod{
+				evictedPod("pod1", "high"),
+				evictedPod("pod2", "low"),
+				evictedPod("pod3", "system-cluster-critical"),
+				evictedPod("pod4", ""),
+			},
+			opts: cleanOptions{priorityClass: kube.PriorityClassFilter{
+				Include: []string{"high", "system-cluster-critical"},
+				Exclude: []string{"system-cluster-critical"},
+			}},
+			wantErr:     false,
+			wantDeleted: 1,
+		},
+		{
+			name: "ExcludePriorityClass",
+			pods: []v1.Pod{
+				evictedPod("pod1", "high"),
+				evictedPod("pod2", "low"),
+				evictedPod("pod3", ""),
+			},
+			opts: cleanOptions{priorityClass: kube.PriorityClassFilter{
+				Exclude: []string{"high"},
+			}},
+			wantErr:     false,
+			wantDeleted: 2,
+		},
 	}
 
 	for _, tt := range tests {
@@ -98,7 +139,9 @@ func TestCleanEvictedPods(t *testing.T) {
 			for _, pod := range tt.pods {
 				fmt.Println(client.CoreV1().Pods("test").Create(context.Background(), &pod, metav1.CreateOptions{}))
 			}
-			err := cleanEvictedPods(context.Background(), client, "test")
+			opts := tt.opts
+			opts.namespace = "test"
+			err := cleanEvictedPods(context.Background(), client, opts)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("cleanEvictedPods() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -112,6 +155,475 @@ func TestCleanEvictedPods(t *testing.T) {
 	}
 }
 
+func TestCleanEvictedPods_NamespaceParallelism(t *testing.T) {
+	ctx := context.Background()
+	client := fake.NewSimpleClientset()
+	namespaces := []string{"ns-1", "ns-2", "ns-3", "ns-4"}
+	for _, ns := range namespaces {
+		_, err := client.CoreV1().Namespaces().Create(ctx,
+			&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: ns}}, metav1.CreateOptions{})
+		assert.NoError(t, err)
+		for _, name := range []string{"pod1", "pod2", "pod3"} {
+			pod := evictedPod(name, "")
+			pod.Namespace = ns
+			_, err := client.CoreV1().Pods(ns).Create(ctx, &pod, metav1.CreateOptions{})
+			assert.NoError(t, err)
+		}
+	}
+
+	err := cleanEvictedPods(ctx, client, cleanOptions{namespace: metav1.NamespaceAll, maxDeletions: 5, parallelism: 3})
+	assert.NoError(t, err)
+
+	listed := map[string]bool{}
+	for _, a := range client.Actions() {
+		if a.GetVerb() == "list" && a.GetResource().Resource == "pods" {
+			listed[a.GetNamespace()] = true
+		}
+	}
+	assert.Len(t, listed, len(namespaces))
+
+	pods, err := client.CoreV1().Pods(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, 12-5, len(pods.Items))
+
+	scope := kube.NamespaceScope{Exclude: kube.NewNamespaceSet("ns-4")}
+	err = cleanEvictedPods(ctx, client, cleanOptions{namespace: metav1.NamespaceAll, parallelism: 2, namespaceScope: scope})
+	assert.NoError(t, err)
+	pods, err = client.CoreV1().Pods(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+	assert.NoError(t, err)
+	for _, p := range pods.Items {
+		assert.Equal(t, "ns-4", p.Namespace)
+	}
+
+	err = cleanEvictedPods(ctx, client, cleanOptions{namespace: metav1.NamespaceAll, parallelism: 2})
+	assert.NoError(t, err)
+	pods, err = client.CoreV1().Pods(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+	assert.NoError(t, err)
+	assert.Empty(t, pods.Items)
+}
+
+func TestCleanEvictedPods_AllNamespaces(t *testing.T) {
+	ctx := context.Background()
+	client := fake.NewSimpleClientset()
+	for _, ns := range []string{"ns-1", "ns-2"} {
+		for _, name := range []string{"pod1", "pod2"} {
+			pod := evictedPod(name, "")
+			pod.Namespace = ns
+			_, err := client.CoreV1().Pods(ns).Create(ctx, &pod, metav1.CreateOptions{})
+			assert.NoError(t, err)
+		}
+	}
+
+	// The namespace is ignored and not validated.
+	err := cleanEvictedPods(ctx, client, cleanOptions{namespace: "Invalid_NS", allNamespaces: true, maxDeletions: 3})
+	assert.NoError(t, err)
+	pods, err := client.CoreV1().Pods(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+	assert.NoError(t, err)
+	assert.Len(t, pods.Items, 1)
+
+	assert.NoError(t, cleanOptions{namespace: "Invalid_NS", allNamespaces: true}.validate())
+	assert.Error(t, cleanOptions{namespace: "Invalid_NS"}.validate())
+}
+
+func TestCleanEvictedPods_ExcludeNamespaces(t *testing.T) {
+	ctx := context.Background()
+	newClient := func() *fake.Clientset {
+		client := fake.NewSimpleClientset()
+		for _, ns := range []string{"kube-system", "kube-public", "apps"} {
+			pod := evictedPod("pod1", "")
+			pod.Namespace = ns
+			_, err := client.CoreV1().Pods(ns).Create(ctx, &pod, metav1.CreateOptions{})
+			assert.NoError(t, err)
+		}
+		return client
+	}
+	remaining := func(client *fake.Clientset) []string {
+		pods, err := client.CoreV1().Pods(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+		assert.NoError(t, err)
+		var ret []string
+		for _, p := range pods.Items {
+			ret = append(ret, p.Namespace)
+		}
+		return ret
+	}
+	system := kube.NamespaceScope{Exclude: kube.NewNamespaceSet(systemNamespaces...)}
+
+	assert.Error(t, cleanOptions{allNamespaces: true,
+		namespaceScope: kube.NamespaceScope{Exclude: kube.NewNamespaceSet("Invalid_NS")}}.validate())
+
+	client := newClient()
+	err := cleanEvictedPods(ctx, client, cleanOptions{allNamespaces: true, namespaceScope: system})
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"kube-system", "kube-public"}, remaining(client))
+
+	// The default namespace is all namespaces, so the exclusion applies without --all-namespaces.
+	client = newClient()
+	err = cleanEvictedPods(ctx, client, cleanOptions{namespace: metav1.NamespaceAll, namespaceScope: system})
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"kube-system", "kube-public"}, remaining(client))
+}
+
+func TestCleanEvictedPods_DeleteInterval(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	for _, name := range []string{"pod1", "pod2"} {
+		pod := evictedPod(name, "")
+		_, err := client.CoreV1().Pods("test").Create(context.Background(), &pod, metav1.CreateOptions{})
+		assert.NoError(t, err)
+	}
+
+	// The second deletion waits for the interval and is canceled.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := cleanEvictedPods(ctx, client, cleanOptions{namespace: "test", deleteInterval: time.Hour})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	pods, err := client.CoreV1().Pods("test").List(context.Background(), metav1.ListOptions{})
+	assert.NoError(t, err)
+	assert.Len(t, pods.Items, 1)
+}
+
+func TestCleanEvictedPods_Reasons(t *testing.T) {
+	newClient := func() *fake.Clientset {
+		client := fake.NewSimpleClientset()
+		for name, reason := range map[string]string{"pod1": "Evicted", "pod2": "Preempted", "pod3": "Shutdown"} {
+			pod := evictedPod(name, "")
+			pod.Status.Reason = reason
+			_, err := client.CoreV1().Pods("test").Create(context.Background(), &pod, metav1.CreateOptions{})
+			assert.NoError(t, err)
+		}
+		return client
+	}
+	remaining := func(client *fake.Clientset) []string {
+		pods, _ := client.CoreV1().Pods("test").List(context.Background(), metav1.ListOptions{})
+		var names []string
+		for _, p := range pods.Items {
+			names = append(names, p.Name)
+		}
+		return names
+	}
+
+	client := newClient()
+	assert.NoError(t, cleanEvictedPods(context.Background(), client, cleanOptions{namespace: "test"}))
+	assert.ElementsMatch(t, []string{"pod2", "pod3"}, remaining(client))
+
+	client = newClient()
+	assert.NoError(t, cleanEvictedPods(context.Background(), client,
+		cleanOptions{namespace: "test", reasons: []string{"Evicted", "Preempted"}}))
+	assert.ElementsMatch(t, []string{"pod3"}, remaining(client))
+}
+
+func TestCleanEvictedPods_ReportOnly(t *testing.T) {
+	ctx := context.Background()
+	client := fake.NewSimpleClientset()
+	for _, name := range []string{"pod1", "pod2"} {
+		pod := evictedPod(name, "")
+		_, err := client.CoreV1().Pods("test").Create(ctx, &pod, metav1.CreateOptions{})
+		assert.NoError(t, err)
+	}
+	count := func() int {
+		pods, err := client.CoreV1().Pods("test").List(ctx, metav1.ListOptions{})
+		assert.NoError(t, err)
+		return len(pods.Items)
+	}
+
+	assert.NoError(t, cleanEvictedPods(ctx, client, cleanOptions{namespace: "test", reportOnly: true}))
+	assert.Equal(t, 2, count())
+
+	err := cleanEvictedPods(ctx, client, cleanOptions{namespace: "test", reportOnly: true, failOnFindings: true})
+	assert.ErrorIs(t, err, output.ErrFindings)
+	assert.Equal(t, output.ExitFindings, output.ExitCode(err))
+	assert.Equal(t, 2, count())
+
+	// Found pods are findings even when they are deleted.
+	err = cleanEvictedPods(ctx, client, cleanOptions{namespace: "test", failOnFindings: true})
+	assert.ErrorIs(t, err, output.ErrFindings)
+	assert.Equal(t, 0, count())
+
+	assert.NoError(t, cleanEvictedPods(ctx, client, cleanOptions{namespace: "test", failOnFindings: true}))
+}
+
+func TestCleanEvictedPods_ReportOnlyMinAge(t *testing.T) {
+	ctx := context.Background()
+	fixed := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	now = func() time.Time { return fixed }
+	defer func() { now = time.Now }()
+
+	young := evictedPod("young", "")
+	young.Status.StartTime = &metav1.Time{Time: fixed.Add(-time.Minute)}
+	client := fake.NewSimpleClientset(&young)
+
+	// A pod that a real run would skip is not a finding.
+	err := cleanEvictedPods(ctx, client, cleanOptions{namespace: "test", minAge: 10 * time.Minute,
+		reportOnly: true, failOnFindings: true})
+	assert.NoError(t, err)
+
+	err = cleanEvictedPods(ctx, client, cleanOptions{namespace: "test", minAge: 30 * time.Second,
+		reportOnly: true, failOnFindings: true})
+	assert.ErrorIs(t, err, output.ErrFindings)
+	assert.ErrorContains(t, err, "1 evicted pods")
+
+	pods, err := client.CoreV1().Pods("test").List(ctx, metav1.ListOptions{})
+	assert.NoError(t, err)
+	assert.Len(t, pods.Items, 1)
+}
+
+func TestCleanEvictedPods_MinAge(t *testing.T) {
+	ctx := context.Background()
+	fixed := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	now = func() time.Time { return fixed }
+	defer func() { now = time.Now }()
+
+	old := evictedPod("old", "")
+	startTime := metav1.NewTime(fixed.Add(-time.Hour))
+	old.Status.StartTime = &startTime
+	young := evictedPod("young", "")
+	young.Status.StartTime = &metav1.Time{Time: fixed.Add(-time.Minute)}
+	created := evictedPod("created", "")
+	created.CreationTimestamp = metav1.NewTime(fixed.Add(-2 * time.Hour))
+
+	client := fake.NewSimpleClientset(&old, &young, &created)
+	err := cleanEvictedPods(ctx, client, cleanOptions{namespace: "test", minAge: 10 * time.Minute})
+	assert.NoError(t, err)
+
+	pods, err := client.CoreV1().Pods("test").List(ctx, metav1.ListOptions{})
+	assert.NoError(t, err)
+	if assert.Len(t, pods.Items, 1) {
+		assert.Equal(t, "young", pods.Items[0].Name)
+	}
+
+	err = cleanEvictedPods(ctx, client, cleanOptions{namespace: "test"})
+	assert.NoError(t, err)
+	pods, err = client.CoreV1().Pods("test").List(ctx, metav1.ListOptions{})
+	assert.NoError(t, err)
+	assert.Empty(t, pods.Items)
+}
+
+func TestCleanEvictedPods_Since(t *testing.T) {
+	ctx := context.Background()
+	fixed := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	now = func() time.Time { return fixed }
+	defer func() { now = time.Now }()
+
+	settled := evictedPod("settled", "")
+	settled.Status.StartTime = &metav1.Time{Time: fixed.Add(-time.Minute)}
+	settled.Status.Conditions = []v1.PodCondition{
+		{Type: v1.PodReady, LastTransitionTime: metav1.NewTime(fixed.Add(-time.Hour))},
+	}
+	settling := evictedPod("settling", "")
+	settling.Status.StartTime = &metav1.Time{Time: fixed.Add(-time.Hour)}
+	settling.Status.Conditions = []v1.PodCondition{
+		{Type: v1.PodScheduled, LastTransitionTime: metav1.NewTime(fixed.Add(-2 * time.Hour))},
+		{Type: v1.PodReady, LastTransitionTime: metav1.NewTime(fixed.Add(-time.Minute))},
+	}
+	created := evictedPod("created", "")
+	created.CreationTimestamp = metav1.NewTime(fixed.Add(-2 * time.Hour))
+
+	client := fake.NewSimpleClientset(&settled, &settling, &created)
+	err := cleanEvictedPods(ctx, client, cleanOptions{namespace: "test", since: 10 * time.Minute})
+	assert.NoError(t, err)
+
+	pods, err := client.CoreV1().Pods("test").List(ctx, metav1.ListOptions{})
+	assert.NoError(t, err)
+	if assert.Len(t, pods.Items, 1) {
+		assert.Equal(t, "settling", pods.Items[0].Name)
+	}
+}
+
+func TestCleanEvictedPods_CallTimeout(t *testing.T) {
+	ctx := context.Background()
+	a, b := evictedPod("a", ""), evictedPod("b", "")
+	client := fake.NewSimpleClientset(&a, &b)
+
+	err := cleanEvictedPods(ctx, client, cleanOptions{namespace: "test", callTimeout: time.Minute})
+	assert.NoError(t, err)
+	pods, err := client.CoreV1().Pods("test").List(ctx, metav1.ListOptions{})
+	assert.NoError(t, err)
+	assert.Empty(t, pods.Items)
+
+	// The cancellation of the run still propagates through the per-call contexts.
+	client = fake.NewSimpleClientset(&a, &b)
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	err = cleanEvictedPods(canceled, client, cleanOptions{namespace: "test", callTimeout: time.Minute})
+	assert.ErrorIs(t, err, context.Canceled)
+	pods, err = client.CoreV1().Pods("test").List(ctx, metav1.ListOptions{})
+	assert.NoError(t, err)
+	assert.Len(t, pods.Items, 2)
+}
+
+func TestCleanEvictedPods_Verbose(t *testing.T) {
+	fixed := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	now = func() time.Time { return fixed }
+	defer func() { now = time.Now }()
+
+	var lines []string
+	log := funcr.New(func(prefix, args string) { lines = append(lines, args) }, funcr.Options{})
+	ctx := logger.WithContext(context.Background(), log)
+
+	controller := true
+	pod := func(name, node, ownerKind string) *v1.Pod {
+		p := evictedPod(name, "")
+		p.Spec.NodeName = node
+		p.Status.StartTime = &metav1.Time{Time: fixed.Add(-time.Hour)}
+		if ownerKind != "" {
+			p.OwnerReferences = []metav1.OwnerReference{{Kind: ownerKind, Name: "owner", Controller: &controller}}
+		}
+		return &p
+	}
+	client := fake.NewSimpleClientset(
+		pod("a", "node-1", "ReplicaSet"),
+		pod("b", "node-1", "ReplicaSet"),
+		pod("c", "node-2", ""),
+	)
+
+	err := cleanEvictedPods(ctx, client, cleanOptions{namespace: "test", verbose: true, maxDeletions: 1})
+	assert.NoError(t, err)
+	assert.Contains(t, lines, `"level"=0 "msg"="evicted pods breakdown" `+
+		`"byNode"=["node-1=2" "node-2=1"] "byOwnerKind"=["ReplicaSet=2" "<none>=1"]`)
+	deleted := 0
+	for _, l := range lines {
+		if strings.Contains(l, `"msg"="deleted evicted pod"`) {
+			deleted++
+			assert.Contains(t, l, `"age"="1h0m0s"`)
+		}
+	}
+	assert.Equal(t, 1, deleted, "the breakdown counts the pods before the deletion cap")
+}
+
+func TestSortedCounts(t *testing.T) {
+	assert.Equal(t, []string{"b=3", "a=1", "c=1"}, sortedCounts(map[string]int{"a": 1, "b": 3, "c": 1}))
+	assert.Empty(t, sortedCounts(nil))
+}
+
+func TestCleanEvictedPods_SkipDaemonSet(t *testing.T) {
+	ctx := context.Background()
+	ds := evictedPod("ds", "")
+	ds.OwnerReferences = []metav1.OwnerReference{{Kind: "DaemonSet", Name: "agent"}}
+	plain := evictedPod("plain", "")
+
+	client := fake.NewSimpleClientset(&ds, &plain)
+	err := cleanEvictedPods(ctx, client, cleanOptions{namespace: "test", skipDaemonSet: true})
+	assert.NoError(t, err)
+
+	pods, err := client.CoreV1().Pods("test").List(ctx, metav1.ListOptions{})
+	assert.NoError(t, err)
+	if assert.Len(t, pods.Items, 1) {
+		assert.Equal(t, "ds", pods.Items[0].Name)
+	}
+}
+
+func TestCleanEvictedPods_Checkpoint(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "checkpoint.json")
+
+	var objs []runtime.Object
+	for _, name := range []string{"pod1", "pod2", "pod3", "pod4"} {
+		pod := evictedPod(name, "")
+		pod.UID = types.UID("uid-" + name)
+		objs = append(objs, &pod)
+	}
+	client := fake.NewSimpleClientset(objs...)
+	// Deleted pods stay listed as if they were still terminating when the run was interrupted.
+	var deleted []string
+	client.PrependReactor("delete", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		deleted = append(deleted, action.(k8stesting.DeleteAction).GetName())
+		return true, nil, nil
+	})
+
+	// The first run is interrupted after two deletions.
+	err := cleanEvictedPods(ctx, client, cleanOptions{namespace: "test", maxDeletions: 2, checkpoint: path})
+	assert.NoError(t, err)
+	assert.Len(t, deleted, 2)
+	first := append([]string(nil), deleted...)
+
+	// The resumed run skips the pods processed by the first run.
+	deleted = nil
+	err = cleanEvictedPods(ctx, client, cleanOptions{namespace: "test", checkpoint: path})
+	assert.NoError(t, err)
+	assert.Len(t, deleted, 2)
+	for _, name := range first {
+		assert.NotContains(t, deleted, name)
+	}
+
+	// Nothing is left once every pod is recorded.
+	deleted = nil
+	err = cleanEvictedPods(ctx, client, cleanOptions{namespace: "test", checkpoint: path})
+	assert.NoError(t, err)
+	assert.Empty(t, deleted)
+}
+
+func TestCleanEvictedPods_CheckpointBatches(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "checkpoint.json")
+	snapshot := filepath.Join(t.TempDir(), "snapshot.json")
+
+	var objs []runtime.Object
+	for i := 0; i < checkpointBatchSize+5; i++ {
+		pod := evictedPod(fmt.Sprintf("pod%02d", i), "")
+		pod.Namespace = fmt.Sprintf("ns-%d", i%3)
+		pod.UID = types.UID(fmt.Sprintf("uid-%02d", i))
+		objs = append(objs, &pod)
+	}
+	newClient := func(deleted *[]string, onDelete func()) *fake.Clientset {
+		client := fake.NewSimpleClientset(objs...)
+		client.PrependReactor("delete", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			if onDelete != nil {
+				onDelete()
+			}
+			*deleted = append(*deleted, action.(k8stesting.DeleteAction).GetName())
+			return true, nil, nil
+		})
+		return client
+	}
+
+	// The checkpoint is saved after the first batch, before the run finishes. The run is killed
+	// right after the batch, so only the checkpoint saved at that point survives.
+	var first []string
+	client := newClient(&first, func() {
+		if len(first) == checkpointBatchSize {
+			data, err := os.ReadFile(path)
+			assert.NoError(t, err)
+			assert.NoError(t, os.WriteFile(snapshot, data, 0o600))
+		}
+	})
+	err := cleanEvictedPods(ctx, client, cleanOptions{namespace: metav1.NamespaceAll, checkpoint: path})
+	assert.NoError(t, err)
+	assert.Len(t, first, checkpointBatchSize+5)
+
+	// The run resumed from the checkpoint of the killed run skips the first batch.
+	var resumed []string
+	client = newClient(&resumed, nil)
+	err = cleanEvictedPods(ctx, client, cleanOptions{namespace: metav1.NamespaceAll, checkpoint: snapshot})
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, first[checkpointBatchSize:], resumed)
+}
+
+func evictedPod(name, priorityClass string) v1.Pod {
+	return v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "test", Name: name},
+		Spec:       v1.PodSpec{PriorityClassName: priorityClass},
+		Status: v1.PodStatus{
+			Phase:   v1.PodFailed,
+			Reason:  "Evicted",
+			Message: "pod was Evicted",
+		},
+	}
+}
+
 func TestNewCommand(t *testing.T) {
-	assert.NotNil(t, NewCommand())
+	cmd := NewCommand()
+	assert.NotNil(t, cmd)
+
+	// Deletions are unlimited by default as they were before --max-deletions.
+	maxDeletions, err := cmd.Flags().GetInt("max-deletions")
+	assert.NoError(t, err)
+	assert.Zero(t, maxDeletions)
+}
+
+func TestNewCommand_InvalidNamespace(t *testing.T) {
+	// The namespace is checked before connecting to the cluster.
+	cmd := NewCommand()
+	cmd.SetArgs([]string{"--namespace", "Invalid_NS"})
+	cmd.SetOut(io.Discard)
+	cmd.SetErr(io.Discard)
+	assert.ErrorContains(t, cmd.Execute(), "Invalid_NS")
 }
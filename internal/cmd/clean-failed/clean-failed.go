@@ -0,0 +1,210 @@
+/*
+MIT License
+
+Copyright (c) 2024 Norihiro Seto
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cleanfailed
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/norseto/k8s-watchdogs/internal/concurrent"
+	"github.com/norseto/k8s-watchdogs/internal/options"
+	"github.com/norseto/k8s-watchdogs/internal/output"
+	"github.com/norseto/k8s-watchdogs/internal/preflight"
+	"github.com/norseto/k8s-watchdogs/internal/validation"
+	"github.com/norseto/k8s-watchdogs/pkg/generics"
+	"github.com/norseto/k8s-watchdogs/pkg/kube"
+	"github.com/norseto/k8s-watchdogs/pkg/kube/client"
+	"github.com/norseto/k8s-watchdogs/pkg/logger"
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// NewCommand returns a new Cobra command for cleaning failed pods.
+func NewCommand() *cobra.Command {
+	var cfOpts cleanOptions
+
+	opts := &options.Options{}
+	cmd := &cobra.Command{
+		Use:   "clean-failed",
+		Short: "Clean failed pods",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			cfOpts.namespace = opts.Namespace()
+			cfOpts.namespaceScope = opts.NamespaceScope()
+			if err := cfOpts.validate(); err != nil {
+				logger.FromContext(ctx).Error(err, "invalid options")
+				return err
+			}
+			clnt, err := client.NewClientset(client.FromContext(ctx))
+			if err != nil {
+				logger.FromContext(ctx).Error(err, "failed to create clientset")
+				return err
+			}
+			if done, err := preflight.Run(ctx, clnt); done {
+				return err
+			}
+			return cleanFailedPods(ctx, clnt, cfOpts)
+		},
+	}
+	opts.BindCommonFlags(cmd)
+	opts.BindNamespaceScopeFlags(cmd)
+
+	flg := cmd.Flags()
+	flg.StringVar(&cfOpts.reason, "reason", "",
+		"Only delete failed pods with this status reason (e.g. DeadlineExceeded). Empty matches any reason.")
+	flg.BoolVar(&cfOpts.includeSucceeded, "include-succeeded", false,
+		"Also delete succeeded pods that are not owned by a Job.")
+	flg.IntVar(&cfOpts.maxDeletions, "max-deletions", defaultMaxDeletions,
+		"Maximum number of pods to delete in a run, or in each namespace with --namespace-concurrency "+
+			"greater than 1. Zero or less means unlimited.")
+	flg.IntVar(&cfOpts.concurrency, "namespace-concurrency", 1,
+		"Number of namespaces processed concurrently when targeting all namespaces. "+
+			"Greater than 1 applies --max-deletions to each namespace. 1 processes all namespaces at once sequentially.")
+	return cmd
+}
+
+const (
+	defaultMaxDeletions = 100
+	kindJob             = "Job"
+)
+
+// cleanOptions represents options for cleaning failed pods.
+type cleanOptions struct {
+	namespace        string
+	namespaceScope   kube.NamespaceScope
+	reason           string
+	includeSucceeded bool
+	maxDeletions     int
+	concurrency      int
+}
+
+// validate checks the namespace.
+func (o cleanOptions) validate() error {
+	return validation.ValidateNamespace(o.namespace)
+}
+
+// +kubebuilder:rbac:groups=core,resources=pods,verbs=get;list;delete
+// +kubebuilder:rbac:groups=core,resources=namespaces,verbs=list
+
+// cleanFailedPods deletes failed pods in the specified namespace.
+// When all namespaces are targeted with a concurrency greater than 1, the namespaces are
+// processed concurrently and the deletion cap is applied to each namespace.
+func cleanFailedPods(ctx context.Context, client kubernetes.Interface, opts cleanOptions) error {
+	log := logger.FromContext(ctx)
+
+	if opts.namespace != metav1.NamespaceAll || opts.concurrency <= 1 {
+		deleted, targets, err := cleanNamespace(ctx, client, opts.namespace, opts)
+		if err != nil {
+			return err
+		}
+		log.Info("pods delete result", "deleted", deleted, "targets", targets)
+		return nil
+	}
+
+	all, err := kube.GetAllNamespaceNames(ctx, client)
+	if err != nil {
+		log.Error(err, "failed to list namespaces")
+		return err
+	}
+	namespaces := generics.Filter(all, opts.namespaceScope.MatchNamespace)
+
+	var mu sync.Mutex
+	totalDeleted, totalTargets := 0, 0
+	err = concurrent.ForEach(ctx, namespaces, opts.concurrency, func(ctx context.Context, ns string) error {
+		deleted, targets, err := cleanNamespace(ctx, client, ns, opts)
+		output.RecordResult(ctx, output.Result{Namespace: ns, Operation: "clean-failed", Err: err})
+		mu.Lock()
+		defer mu.Unlock()
+		totalDeleted += deleted
+		totalTargets += targets
+		log.V(1).Info("namespace delete result", "namespace", ns, "deleted", deleted, "targets", targets)
+		if err != nil {
+			return fmt.Errorf("namespace %s: %w", ns, err)
+		}
+		return nil
+	})
+
+	log.Info("pods delete result", "deleted", totalDeleted, "targets", totalTargets, "namespaces", len(namespaces))
+	return err
+}
+
+// cleanNamespace deletes failed pods in the namespace up to the deletion cap.
+// It returns the numbers of deleted and target pods.
+func cleanNamespace(ctx context.Context, client kubernetes.Interface, namespace string, opts cleanOptions) (int, int, error) {
+	log := logger.FromContext(ctx)
+
+	pods, err := kube.ListAllPods(ctx, client, namespace, metav1.ListOptions{})
+	if err != nil {
+		log.Error(err, "failed to list pods", "namespace", namespace)
+		return 0, 0, err
+	}
+
+	targets := kube.FilterPods(&corev1.PodList{Items: pods}, func(pod *corev1.Pod) bool {
+		return opts.namespaceScope.Match(pod) && isTarget(pod, opts)
+	})
+
+	deleted := 0
+	for _, pod := range targets {
+		if err := ctx.Err(); err != nil {
+			log.Info("interrupted", "deleted", deleted, "targets", len(targets))
+			return deleted, len(targets), err
+		}
+		if opts.maxDeletions > 0 && deleted >= opts.maxDeletions {
+			log.Info("reached the deletion cap", "max", opts.maxDeletions)
+			break
+		}
+		if err := kube.DeletePod(ctx, client, *pod); err != nil {
+			log.Error(err, "failed to delete pod", "pod", fmt.Sprintf("%s/%s", pod.Namespace, pod.Name))
+			continue
+		}
+		deleted++
+	}
+	return deleted, len(targets), nil
+}
+
+// isTarget checks if the pod should be deleted.
+// Failed pods match when their reason equals opts.reason or opts.reason is empty.
+// Succeeded pods match only with opts.includeSucceeded and when they are not owned by a Job.
+func isTarget(pod *corev1.Pod, opts cleanOptions) bool {
+	switch pod.Status.Phase {
+	case corev1.PodFailed:
+		return opts.reason == "" || pod.Status.Reason == opts.reason
+	case corev1.PodSucceeded:
+		if !opts.includeSucceeded {
+			return false
+		}
+		for _, o := range pod.OwnerReferences {
+			if o.Kind == kindJob {
+				return false
+			}
+		}
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,153 @@
+/*
+MIT License
+
+Copyright (c) 2024 Norihiro Seto
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cleanfailed
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	"github.com/norseto/k8s-watchdogs/internal/output"
+	"github.com/norseto/k8s-watchdogs/pkg/generics"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func testPod(name string, phase corev1.PodPhase, reason string, owner string) *corev1.Pod {
+	p := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Status:     corev1.PodStatus{Phase: phase, Reason: reason},
+	}
+	if owner != "" {
+		p.OwnerReferences = []metav1.OwnerReference{{Kind: owner, Name: "owner"}}
+	}
+	return p
+}
+
+func TestCleanFailedPods(t *testing.T) {
+	objects := func() []runtime.Object {
+		return []runtime.Object{
+			testPod("failed-deadline", corev1.PodFailed, "DeadlineExceeded", ""),
+			testPod("failed-evicted", corev1.PodFailed, "Evicted", ""),
+			testPod("running", corev1.PodRunning, "", ""),
+			testPod("succeeded", corev1.PodSucceeded, "", ""),
+			testPod("succeeded-job", corev1.PodSucceeded, "", "Job"),
+		}
+	}
+
+	tests := []struct {
+		name      string
+		opts      cleanOptions
+		remaining []string
+		wantErr   bool
+	}{
+		{"AllFailed", cleanOptions{namespace: "default"},
+			[]string{"running", "succeeded", "succeeded-job"}, false},
+		{"Reason", cleanOptions{namespace: "default", reason: "DeadlineExceeded"},
+			[]string{"failed-evicted", "running", "succeeded", "succeeded-job"}, false},
+		{"IncludeSucceeded", cleanOptions{namespace: "default", includeSucceeded: true},
+			[]string{"running", "succeeded-job"}, false},
+		{"MaxDeletions", cleanOptions{namespace: "default", maxDeletions: 1},
+			[]string{"failed-evicted", "running", "succeeded", "succeeded-job"}, false},
+		{"InvalidNamespace", cleanOptions{namespace: "Invalid_NS"},
+			[]string{"failed-deadline", "failed-evicted", "running", "succeeded", "succeeded-job"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			client := fake.NewSimpleClientset(objects()...)
+
+			err := tt.opts.validate()
+			if err == nil {
+				err = cleanFailedPods(ctx, client, tt.opts)
+			}
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			pods, err := client.CoreV1().Pods("default").List(ctx, metav1.ListOptions{})
+			assert.NoError(t, err)
+			var names []string
+			for _, p := range pods.Items {
+				names = append(names, p.Name)
+			}
+			sort.Strings(names)
+			assert.Equal(t, tt.remaining, names)
+		})
+	}
+}
+
+func TestCleanFailedPods_Canceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	client := fake.NewSimpleClientset(testPod("failed", corev1.PodFailed, "", ""))
+
+	err := cleanFailedPods(ctx, client, cleanOptions{namespace: "default"})
+	assert.ErrorIs(t, err, context.Canceled)
+
+	pods, err := client.CoreV1().Pods("default").List(context.Background(), metav1.ListOptions{})
+	assert.NoError(t, err)
+	assert.Len(t, pods.Items, 1)
+}
+
+func TestCleanFailedPods_NamespaceConcurrency(t *testing.T) {
+	ctx := context.Background()
+	var objs []runtime.Object
+	for _, ns := range []string{"ns-1", "ns-2", "ns-3"} {
+		objs = append(objs, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: ns}})
+		for _, name := range []string{"pod1", "pod2", "pod3"} {
+			p := testPod(name, corev1.PodFailed, "", "")
+			p.Namespace = ns
+			objs = append(objs, p)
+		}
+	}
+	client := fake.NewSimpleClientset(objs...)
+	countPods := func(ns string) int {
+		pods, _ := client.CoreV1().Pods(ns).List(ctx, metav1.ListOptions{})
+		return len(pods.Items)
+	}
+
+	// The cap applies to each namespace, and each namespace has its own result.
+	resultsCtx := output.WithResults(ctx)
+	err := cleanFailedPods(resultsCtx, client, cleanOptions{maxDeletions: 2, concurrency: 2})
+	assert.NoError(t, err)
+	for _, ns := range []string{"ns-1", "ns-2", "ns-3"} {
+		assert.Equal(t, 1, countPods(ns), ns)
+	}
+	results := output.CollectResults(resultsCtx, "", "clean-failed", err)
+	assert.ElementsMatch(t, []string{"ns-1", "ns-2", "ns-3"},
+		generics.Convert(results, func(r output.Result) string { return r.Namespace }, nil))
+
+	// Sequential processing shares the cap across namespaces.
+	err = cleanFailedPods(ctx, client, cleanOptions{maxDeletions: 2, concurrency: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, countPods(metav1.NamespaceAll))
+}
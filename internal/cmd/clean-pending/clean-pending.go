@@ -0,0 +1,153 @@
+/*
+MIT License
+
+Copyright (c) 2024 Norihiro Seto
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cleanpending
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/norseto/k8s-watchdogs/internal/options"
+	"github.com/norseto/k8s-watchdogs/internal/preflight"
+	"github.com/norseto/k8s-watchdogs/internal/validation"
+	"github.com/norseto/k8s-watchdogs/pkg/kube"
+	"github.com/norseto/k8s-watchdogs/pkg/kube/client"
+	"github.com/norseto/k8s-watchdogs/pkg/logger"
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// NewCommand returns a new Cobra command for cleaning unschedulable pending pods.
+func NewCommand() *cobra.Command {
+	var cpOpts cleanOptions
+
+	opts := &options.Options{}
+	cmd := &cobra.Command{
+		Use:   "clean-pending",
+		Short: "Clean unschedulable pending pods",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			cpOpts.namespace = opts.Namespace()
+			cpOpts.namespaceScope = opts.NamespaceScope()
+			if err := cpOpts.validate(); err != nil {
+				logger.FromContext(ctx).Error(err, "invalid options")
+				return err
+			}
+			clnt, err := client.NewClientset(client.FromContext(ctx))
+			if err != nil {
+				logger.FromContext(ctx).Error(err, "failed to create clientset")
+				return err
+			}
+			if done, err := preflight.Run(ctx, clnt); done {
+				return err
+			}
+			return cleanPendingPods(ctx, clnt, cpOpts)
+		},
+	}
+	opts.BindCommonFlags(cmd)
+	opts.BindNamespaceScopeFlags(cmd)
+
+	flg := cmd.Flags()
+	flg.DurationVar(&cpOpts.olderThan, "older-than", defaultOlderThan,
+		"Only delete pods pending for longer than this duration (e.g. 30m, 2h).")
+	flg.IntVar(&cpOpts.maxDeletions, "max-deletions", defaultMaxDeletions,
+		"Maximum number of pods to delete in a run. Zero or less means unlimited.")
+	return cmd
+}
+
+const (
+	defaultMaxDeletions = 100
+	defaultOlderThan    = time.Hour
+)
+
+// cleanOptions represents options for cleaning pending pods.
+type cleanOptions struct {
+	namespace      string
+	namespaceScope kube.NamespaceScope
+	olderThan      time.Duration
+	maxDeletions   int
+}
+
+// now returns the current time. It is replaced in tests.
+var now = time.Now
+
+// validate checks the namespace and the age of the pods.
+func (o cleanOptions) validate() error {
+	if err := validation.ValidateNamespace(o.namespace); err != nil {
+		return err
+	}
+	if o.olderThan < 0 {
+		return fmt.Errorf("invalid older-than %v: must not be negative", o.olderThan)
+	}
+	return nil
+}
+
+// +kubebuilder:rbac:groups=core,resources=pods,verbs=get;list;delete
+
+// cleanPendingPods deletes unschedulable pending pods in the specified namespace.
+func cleanPendingPods(ctx context.Context, client kubernetes.Interface, opts cleanOptions) error {
+	log := logger.FromContext(ctx)
+
+	pods, err := kube.ListAllPods(ctx, client, opts.namespace, metav1.ListOptions{})
+	if err != nil {
+		log.Error(err, "failed to list pods", "namespace", opts.namespace)
+		return err
+	}
+
+	targets := kube.FilterPods(&corev1.PodList{Items: pods}, func(pod *corev1.Pod) bool {
+		return opts.namespaceScope.Match(pod) && isTarget(pod, opts.olderThan)
+	})
+
+	deleted := 0
+	for _, pod := range targets {
+		if err := ctx.Err(); err != nil {
+			log.Info("interrupted", "deleted", deleted, "targets", len(targets))
+			return err
+		}
+		if opts.maxDeletions > 0 && deleted >= opts.maxDeletions {
+			log.Info("reached the deletion cap", "max", opts.maxDeletions)
+			break
+		}
+		if err := kube.DeletePod(ctx, client, *pod); err != nil {
+			log.Error(err, "failed to delete pod", "pod", fmt.Sprintf("%s/%s", pod.Namespace, pod.Name))
+			continue
+		}
+		deleted++
+	}
+
+	log.Info("pods delete result", "deleted", deleted, "targets", len(targets))
+	return nil
+}
+
+// isTarget checks if the pod is unschedulable and has been pending for longer than olderThan.
+// The pending duration is measured from the creation of the pod since it has never started.
+func isTarget(pod *corev1.Pod, olderThan time.Duration) bool {
+	if kube.IsPodTerminating(pod) || !kube.IsUnschedulablePod(pod) {
+		return false
+	}
+	return now().Sub(pod.CreationTimestamp.Time) > olderThan
+}
@@ -0,0 +1,117 @@
+/*
+MIT License
+
+Copyright (c) 2024 Norihiro Seto
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cleanpending
+
+import (
+	"context"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+var testNow = time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+func testPod(name string, phase corev1.PodPhase, reason string, age time.Duration) *corev1.Pod {
+	p := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			Namespace:         "default",
+			CreationTimestamp: metav1.NewTime(testNow.Add(-age)),
+		},
+		Status: corev1.PodStatus{Phase: phase},
+	}
+	if reason != "" {
+		p.Status.Conditions = []corev1.PodCondition{
+			{Type: corev1.PodScheduled, Status: corev1.ConditionFalse, Reason: reason},
+		}
+	}
+	return p
+}
+
+func TestCleanPendingPods(t *testing.T) {
+	defer func(f func() time.Time) { now = f }(now)
+	now = func() time.Time { return testNow }
+
+	objects := func() []runtime.Object {
+		return []runtime.Object{
+			testPod("unschedulable-old", corev1.PodPending, corev1.PodReasonUnschedulable, 2*time.Hour),
+			testPod("unschedulable-older", corev1.PodPending, corev1.PodReasonUnschedulable, 3*time.Hour),
+			testPod("unschedulable-new", corev1.PodPending, corev1.PodReasonUnschedulable, 10*time.Minute),
+			testPod("gated", corev1.PodPending, "SchedulingGated", 2*time.Hour),
+			testPod("pulling", corev1.PodPending, "", 2*time.Hour),
+			testPod("running", corev1.PodRunning, "", 2*time.Hour),
+		}
+	}
+
+	tests := []struct {
+		name      string
+		opts      cleanOptions
+		remaining []string
+		wantErr   bool
+	}{
+		{"Default", cleanOptions{namespace: "default", olderThan: time.Hour},
+			[]string{"gated", "pulling", "running", "unschedulable-new"}, false},
+		{"OlderThan", cleanOptions{namespace: "default", olderThan: 0},
+			[]string{"gated", "pulling", "running"}, false},
+		{"MaxDeletions", cleanOptions{namespace: "default", olderThan: time.Hour, maxDeletions: 1},
+			[]string{"gated", "pulling", "running", "unschedulable-new", "unschedulable-older"}, false},
+		{"NegativeOlderThan", cleanOptions{namespace: "default", olderThan: -time.Hour},
+			[]string{"gated", "pulling", "running", "unschedulable-new", "unschedulable-old", "unschedulable-older"}, true},
+		{"InvalidNamespace", cleanOptions{namespace: "Invalid_NS"},
+			[]string{"gated", "pulling", "running", "unschedulable-new", "unschedulable-old", "unschedulable-older"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			client := fake.NewSimpleClientset(objects()...)
+
+			err := tt.opts.validate()
+			if err == nil {
+				err = cleanPendingPods(ctx, client, tt.opts)
+			}
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			pods, err := client.CoreV1().Pods("default").List(ctx, metav1.ListOptions{})
+			assert.NoError(t, err)
+			var names []string
+			for _, p := range pods.Items {
+				names = append(names, p.Name)
+			}
+			sort.Strings(names)
+			assert.Equal(t, tt.remaining, names)
+		})
+	}
+}
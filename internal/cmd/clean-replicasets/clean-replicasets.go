@@ -0,0 +1,197 @@
+/*
+MIT License
+
+Copyright (c) 2024 Norihiro Seto
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cleanreplicasets
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/norseto/k8s-watchdogs/internal/options"
+	"github.com/norseto/k8s-watchdogs/internal/preflight"
+	"github.com/norseto/k8s-watchdogs/internal/validation"
+	"github.com/norseto/k8s-watchdogs/pkg/generics"
+	"github.com/norseto/k8s-watchdogs/pkg/kube"
+	"github.com/norseto/k8s-watchdogs/pkg/kube/client"
+	"github.com/norseto/k8s-watchdogs/pkg/logger"
+	"github.com/spf13/cobra"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+)
+
+// NewCommand returns a new Cobra command for cleaning scaled down replicasets.
+func NewCommand() *cobra.Command {
+	var crOpts cleanOptions
+
+	opts := &options.Options{}
+	cmd := &cobra.Command{
+		Use:   "clean-replicasets",
+		Short: "Clean replicasets scaled down to zero replicas",
+		Long: "Clean replicasets that neither want nor have any pods, e.g. old revisions left by deployments. " +
+			"The newest ones of each deployment are kept for rollbacks. " +
+			"Replicasets managed by other controllers are never deleted.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			crOpts.namespace = opts.Namespace()
+			crOpts.namespaceScope = opts.NamespaceScope()
+			if err := crOpts.validate(); err != nil {
+				logger.FromContext(ctx).Error(err, "invalid options")
+				return err
+			}
+			clnt, err := client.NewClientset(client.FromContext(ctx))
+			if err != nil {
+				logger.FromContext(ctx).Error(err, "failed to create clientset")
+				return err
+			}
+			if done, err := preflight.Run(ctx, clnt); done {
+				return err
+			}
+			return cleanReplicaSets(ctx, clnt, crOpts)
+		},
+	}
+	opts.BindCommonFlags(cmd)
+	opts.BindNamespaceScopeFlags(cmd)
+
+	flg := cmd.Flags()
+	flg.DurationVar(&crOpts.olderThan, "older-than", defaultOlderThan,
+		"Only delete replicasets created longer ago than this duration (e.g. 30m, 2h).")
+	flg.IntVar(&crOpts.keep, "keep", defaultKeep,
+		"Number of the newest scaled down replicasets kept for each deployment regardless of their age. "+
+			"Zero keeps none, which also deletes the current replicaset of a deployment scaled to zero.")
+	flg.IntVar(&crOpts.maxDeletions, "max-deletions", defaultMaxDeletions,
+		"Maximum number of replicasets to delete in a run. Zero or less means unlimited.")
+	return cmd
+}
+
+const (
+	defaultMaxDeletions = 100
+	defaultOlderThan    = 24 * time.Hour
+	defaultKeep         = 1
+)
+
+// cleanOptions represents options for cleaning scaled down replicasets.
+type cleanOptions struct {
+	namespace      string
+	namespaceScope kube.NamespaceScope
+	olderThan      time.Duration
+	keep           int
+	maxDeletions   int
+}
+
+// now returns the current time. It is replaced in tests.
+var now = time.Now
+
+// validate checks the namespace, the age and the number of the replicasets kept.
+func (o cleanOptions) validate() error {
+	if err := validation.ValidateNamespace(o.namespace); err != nil {
+		return err
+	}
+	if o.olderThan < 0 {
+		return fmt.Errorf("invalid older-than %v: must not be negative", o.olderThan)
+	}
+	if o.keep < 0 {
+		return fmt.Errorf("invalid keep %d: must not be negative", o.keep)
+	}
+	return nil
+}
+
+// +kubebuilder:rbac:groups=apps,resources=replicasets,verbs=get;list;delete
+
+// cleanReplicaSets deletes scaled down replicasets in the specified namespace, oldest first.
+func cleanReplicaSets(ctx context.Context, client kubernetes.Interface, opts cleanOptions) error {
+	log := logger.FromContext(ctx)
+
+	list, err := client.AppsV1().ReplicaSets(opts.namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		log.Error(err, "failed to list replicasets", "namespace", opts.namespace)
+		return err
+	}
+
+	candidates := generics.Convert(list.Items,
+		func(rs appsv1.ReplicaSet) *appsv1.ReplicaSet { return rs.DeepCopy() },
+		func(rs appsv1.ReplicaSet) bool {
+			return rs.DeletionTimestamp == nil && opts.namespaceScope.MatchNamespace(rs.Namespace) &&
+				kube.IsScaledDownReplicaSet(&rs)
+		})
+	targets := selectTargets(candidates, opts.olderThan, opts.keep)
+
+	deleted := 0
+	for _, rs := range targets {
+		if err := ctx.Err(); err != nil {
+			log.Info("interrupted", "deleted", deleted, "targets", len(targets))
+			return err
+		}
+		if opts.maxDeletions > 0 && deleted >= opts.maxDeletions {
+			log.Info("reached the deletion cap", "max", opts.maxDeletions)
+			break
+		}
+		target := fmt.Sprintf("%s/%s", rs.Namespace, rs.Name)
+		// The preconditions leave the replicaset untouched when it has been scaled up, e.g. by a rollback, since listed.
+		err := client.AppsV1().ReplicaSets(rs.Namespace).Delete(ctx, rs.Name, metav1.DeleteOptions{
+			Preconditions: &metav1.Preconditions{UID: &rs.UID, ResourceVersion: &rs.ResourceVersion},
+		})
+		if err != nil {
+			log.Error(err, "failed to delete replicaset", "replicaset", target)
+			continue
+		}
+		log.V(1).Info("deleted", "replicaset", target)
+		deleted++
+	}
+
+	log.Info("replicasets delete result", "deleted", deleted, "targets", len(targets))
+	return nil
+}
+
+// selectTargets returns the replicasets created longer ago than olderThan, oldest first.
+// The newest keep replicasets of each owning deployment are excluded regardless of their age.
+func selectTargets(replicaSets []*appsv1.ReplicaSet, olderThan time.Duration, keep int) []*appsv1.ReplicaSet {
+	sorted := append([]*appsv1.ReplicaSet(nil), replicaSets...)
+	sort.SliceStable(sorted, func(i, j int) bool { return isNewer(sorted[i], sorted[j]) })
+
+	kept := map[types.UID]int{}
+	var targets []*appsv1.ReplicaSet
+	for _, rs := range sorted {
+		if owner := metav1.GetControllerOf(rs); owner != nil && kept[owner.UID] < keep {
+			kept[owner.UID]++
+			continue
+		}
+		if now().Sub(rs.CreationTimestamp.Time) > olderThan {
+			targets = append(targets, rs)
+		}
+	}
+	sort.SliceStable(targets, func(i, j int) bool { return isNewer(targets[j], targets[i]) })
+	return targets
+}
+
+// isNewer reports whether replicaset a was created after b, breaking ties by name.
+func isNewer(a, b *appsv1.ReplicaSet) bool {
+	if !a.CreationTimestamp.Equal(&b.CreationTimestamp) {
+		return b.CreationTimestamp.Before(&a.CreationTimestamp)
+	}
+	return a.Name > b.Name
+}
@@ -0,0 +1,156 @@
+/*
+MIT License
+
+Copyright (c) 2024 Norihiro Seto
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cleanreplicasets
+
+import (
+	"context"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+var testNow = time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+func testReplicaSet(name string, replicas int32, owner types.UID, age time.Duration) *appsv1.ReplicaSet {
+	rs := &appsv1.ReplicaSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			Namespace:         "default",
+			UID:               types.UID(name),
+			CreationTimestamp: metav1.NewTime(testNow.Add(-age)),
+		},
+		Spec:   appsv1.ReplicaSetSpec{Replicas: &replicas},
+		Status: appsv1.ReplicaSetStatus{Replicas: replicas},
+	}
+	if owner != "" {
+		controller := true
+		rs.OwnerReferences = []metav1.OwnerReference{
+			{Kind: "Deployment", Name: string(owner), UID: owner, Controller: &controller}}
+	}
+	return rs
+}
+
+func TestCleanReplicaSets(t *testing.T) {
+	defer func(f func() time.Time) { now = f }(now)
+	now = func() time.Time { return testNow }
+
+	objects := func() []runtime.Object {
+		rollout := testReplicaSet("rollout", 0, "", 72*time.Hour)
+		controller := true
+		rollout.OwnerReferences = []metav1.OwnerReference{{Kind: "Rollout", Name: "rollout", Controller: &controller}}
+		return []runtime.Object{
+			testReplicaSet("web-1", 0, "web", 72*time.Hour),
+			testReplicaSet("web-2", 0, "web", 48*time.Hour),
+			testReplicaSet("web-3", 2, "web", 2*time.Hour),
+			testReplicaSet("api-1", 0, "api", 72*time.Hour),
+			testReplicaSet("api-2", 0, "api", 10*time.Minute),
+			testReplicaSet("orphan", 0, "", 48*time.Hour),
+			rollout,
+		}
+	}
+
+	tests := []struct {
+		name      string
+		opts      cleanOptions
+		remaining []string
+		wantErr   bool
+	}{
+		{"Default", cleanOptions{namespace: "default", olderThan: time.Hour, keep: 1},
+			[]string{"api-2", "rollout", "web-2", "web-3"}, false},
+		{"KeepNone", cleanOptions{namespace: "default", olderThan: time.Hour},
+			[]string{"api-2", "rollout", "web-3"}, false},
+		{"KeepTwo", cleanOptions{namespace: "default", olderThan: time.Hour, keep: 2},
+			[]string{"api-1", "api-2", "rollout", "web-1", "web-2", "web-3"}, false},
+		{"OlderThan", cleanOptions{namespace: "default", olderThan: 60 * time.Hour, keep: 1},
+			[]string{"api-2", "orphan", "rollout", "web-2", "web-3"}, false},
+		{"MaxDeletions", cleanOptions{namespace: "default", olderThan: time.Hour, maxDeletions: 2},
+			[]string{"api-2", "orphan", "rollout", "web-2", "web-3"}, false},
+		{"NegativeKeep", cleanOptions{namespace: "default", keep: -1},
+			[]string{"api-1", "api-2", "orphan", "rollout", "web-1", "web-2", "web-3"}, true},
+		{"NegativeOlderThan", cleanOptions{namespace: "default", olderThan: -time.Hour},
+			[]string{"api-1", "api-2", "orphan", "rollout", "web-1", "web-2", "web-3"}, true},
+		{"InvalidNamespace", cleanOptions{namespace: "Invalid_NS"},
+			[]string{"api-1", "api-2", "orphan", "rollout", "web-1", "web-2", "web-3"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			client := fake.NewSimpleClientset(objects()...)
+
+			err := tt.opts.validate()
+			if err == nil {
+				err = cleanReplicaSets(ctx, client, tt.opts)
+			}
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			list, err := client.AppsV1().ReplicaSets("default").List(ctx, metav1.ListOptions{})
+			assert.NoError(t, err)
+			var names []string
+			for _, rs := range list.Items {
+				names = append(names, rs.Name)
+			}
+			sort.Strings(names)
+			assert.Equal(t, tt.remaining, names)
+		})
+	}
+}
+
+func TestSelectTargets(t *testing.T) {
+	defer func(f func() time.Time) { now = f }(now)
+	now = func() time.Time { return testNow }
+
+	replicaSets := []*appsv1.ReplicaSet{
+		testReplicaSet("b", 0, "", 48*time.Hour),
+		testReplicaSet("c", 0, "", 72*time.Hour),
+		testReplicaSet("a", 0, "", 48*time.Hour),
+	}
+	var names []string
+	for _, rs := range selectTargets(replicaSets, time.Hour, 1) {
+		names = append(names, rs.Name)
+	}
+	assert.Equal(t, []string{"c", "a", "b"}, names, "oldest first, ties by name")
+}
+
+func TestNewCommand(t *testing.T) {
+	cmd := NewCommand()
+	assert.NotNil(t, cmd)
+	assert.Equal(t, "clean-replicasets", cmd.Use)
+
+	keep, err := cmd.Flags().GetInt("keep")
+	assert.NoError(t, err)
+	assert.Equal(t, defaultKeep, keep)
+}
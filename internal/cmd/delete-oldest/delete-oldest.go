@@ -26,9 +26,14 @@ package deleteoldest
 
 import (
 	"context"
+	"fmt"
 	"strings"
+	"time"
 
 	"github.com/norseto/k8s-watchdogs/internal/options"
+	"github.com/norseto/k8s-watchdogs/internal/preflight"
+	"github.com/norseto/k8s-watchdogs/internal/validation"
+	"github.com/norseto/k8s-watchdogs/pkg/generics"
 	"github.com/norseto/k8s-watchdogs/pkg/kube"
 	"github.com/norseto/k8s-watchdogs/pkg/kube/client"
 	"github.com/norseto/k8s-watchdogs/pkg/logger"
@@ -41,18 +46,44 @@ import (
 
 // NewCommand returns a new Cobra command for re-balancing pods.
 func NewCommand() *cobra.Command {
-	var prefix string
-	var minPods int
+	var delOpts deleteOptions
+	var propagation string
+	var maxMinPods int
+	var matchAnnotation string
 
 	opts := &options.Options{}
 	cmd := &cobra.Command{
 		Use:   "delete-oldest",
 		Short: "Delete oldest pod(s)",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			if prefix == "" || minPods < 1 {
+			if (delOpts.prefix == "" && matchAnnotation == "") || delOpts.minPods < 1 {
 				_ = cmd.Usage()
 				return nil
 			}
+			key, value, err := parseMatchAnnotation(matchAnnotation)
+			if err != nil {
+				return err
+			}
+			delOpts.annotationKey, delOpts.annotationValue = key, value
+			if err := validateMinPods(delOpts.minPods, maxMinPods); err != nil {
+				return err
+			}
+			if err := validateSortBy(delOpts.sortBy); err != nil {
+				return err
+			}
+			if err := validation.ValidateConditionType(delOpts.readyCondition); err != nil {
+				return err
+			}
+			if delOpts.node != "" {
+				if err := validation.ValidateResourceName(delOpts.node); err != nil {
+					return err
+				}
+			}
+			policy, err := kube.ParsePropagationPolicy(propagation)
+			if err != nil {
+				return err
+			}
+			delOpts.propagation = policy
 
 			ctx := cmd.Context()
 			clnt, err := client.NewClientset(client.FromContext(ctx))
@@ -60,37 +91,152 @@ func NewCommand() *cobra.Command {
 				logger.FromContext(ctx).Error(err, "failed to create clnt")
 				return err
 			}
-			return deleteOldestPods(cmd.Context(), clnt, opts.Namespace(), prefix, minPods)
+			if done, err := preflight.Run(ctx, clnt); done {
+				return err
+			}
+			delOpts.namespace = opts.Namespace()
+			delOpts.priorityClass = opts.PriorityClassFilter()
+			delOpts.namespaceScope = opts.NamespaceScope()
+			return deleteOldestPods(cmd.Context(), clnt, delOpts)
 		},
 	}
 	opts.BindCommonFlags(cmd)
+	opts.BindPriorityClassFlags(cmd)
+	opts.BindNamespaceScopeFlags(cmd)
 
 	flg := cmd.Flags()
-	flg.StringVarP(&prefix, "prefix", "p", "", "Pod name prefix to delete.")
-	flg.IntVarP(&minPods, "minPods", "m", 3, "Min pods required.")
+	flg.StringVarP(&delOpts.prefix, "prefix", "p", "", "Pod name prefix to delete.")
+	flg.StringVar(&matchAnnotation, "match-annotation", "",
+		"Only consider pods with this annotation, given as key=value. Can be used instead of or with --prefix, "+
+			"in which case both must match.")
+	flg.IntVarP(&delOpts.minPods, "minPods", "m", 3, "Min pods required.")
+	flg.IntVar(&maxMinPods, "max-minpods", defaultMaxMinPods,
+		"Upper limit of --minPods, guarding against typos. Must be positive.")
+	flg.StringVar(&delOpts.sortBy, "sort-by", sortByStartTime,
+		"Timestamp used to find the oldest pod: "+sortByStartTime+" or "+sortByCreationTime+". "+
+			"Pods without a start time fall back to the creation time. Ties are broken by pod name.")
+	flg.StringVar(&delOpts.node, "node", "",
+		"Only consider pods scheduled on this node. Empty considers pods on every node.")
+	flg.StringVar(&delOpts.readyCondition, "ready-condition", "",
+		"Pod condition type, e.g. a readiness gate, that makes a running pod eligible when True. "+
+			"Empty requires the pod to be ready and running, including its readiness gates.")
+	flg.StringVar(&propagation, "propagation", "",
+		"Propagation policy of the deletion: Background, Foreground or Orphan. Empty uses the server default.")
 
 	return cmd
 }
 
+// deleteOptions represents options for deleting the oldest pod.
+type deleteOptions struct {
+	namespace      string
+	prefix         string
+	minPods        int
+	priorityClass  kube.PriorityClassFilter
+	namespaceScope kube.NamespaceScope
+	sortBy         string
+	propagation    *metav1.DeletionPropagation
+	node           string
+	// readyCondition is the condition type that makes a pod eligible instead of the built-in readiness when not empty.
+	readyCondition string
+	// annotationKey and annotationValue select the pods by annotation when the key is not empty.
+	annotationKey   string
+	annotationValue string
+}
+
+// parseMatchAnnotation parses the annotation to match given as key=value.
+// An empty value returns an empty key, which matches every pod.
+func parseMatchAnnotation(value string) (string, string, error) {
+	if value == "" {
+		return "", "", nil
+	}
+	key, val, ok := strings.Cut(value, "=")
+	if !ok || key == "" {
+		return "", "", fmt.Errorf("invalid --match-annotation %q: must be key=value", value)
+	}
+	if err := validation.ValidateAnnotationKey(key); err != nil {
+		return "", "", err
+	}
+	return key, val, nil
+}
+
+// matches checks if the pod has the name prefix and the annotation of the options.
+func (o deleteOptions) matches(pod *corev1.Pod) bool {
+	if !strings.HasPrefix(pod.Name, o.prefix) {
+		return false
+	}
+	if o.annotationKey == "" {
+		return true
+	}
+	v, ok := pod.Annotations[o.annotationKey]
+	return ok && v == o.annotationValue
+}
+
+// ready returns the check of the pods eligible for deletion.
+func (o deleteOptions) ready() func(corev1.Pod) bool {
+	if o.readyCondition == "" {
+		return kube.IsPodReadyRunning
+	}
+	conditionType := corev1.PodConditionType(o.readyCondition)
+	return func(pod corev1.Pod) bool {
+		return kube.IsPodRunningWithCondition(pod, conditionType)
+	}
+}
+
+// defaultMaxMinPods is the default upper limit of the minimum number of pods.
+const defaultMaxMinPods = 1000
+
+// validateMinPods checks that the minimum number of pods does not exceed the limit.
+func validateMinPods(minPods, limit int) error {
+	if limit < 1 {
+		return fmt.Errorf("invalid --max-minpods %d: must be positive", limit)
+	}
+	if minPods > limit {
+		return fmt.Errorf("invalid --minPods %d: must be at most %d", minPods, limit)
+	}
+	return nil
+}
+
+const (
+	// sortByStartTime orders pods by status.startTime, falling back to the creation time.
+	sortByStartTime = "start-time"
+	// sortByCreationTime orders pods by metadata.creationTimestamp.
+	sortByCreationTime = "creation-time"
+)
+
+// validateSortBy checks that sortBy is a supported ordering.
+func validateSortBy(sortBy string) error {
+	switch sortBy {
+	case sortByStartTime, sortByCreationTime:
+		return nil
+	}
+	return fmt.Errorf("invalid --sort-by %q: must be %s or %s", sortBy, sortByStartTime, sortByCreationTime)
+}
+
 // +kubebuilder:rbac:groups=core,resources=pods,verbs=get;list;delete
 // +kubebuilder:rbac:groups=core,resources=pods/status,verbs=get
 
-func deleteOldestPods(ctx context.Context, client kubernetes.Interface, namespace, prefix string, minPods int) error {
+func deleteOldestPods(ctx context.Context, client kubernetes.Interface, opts deleteOptions) error {
 
 	log := logger.FromContext(ctx)
 
-	pods, err := client.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{})
+	pods, err := client.CoreV1().Pods(opts.namespace).List(ctx, metav1.ListOptions{})
 	if err != nil {
 		log.Error(err, "failed to list pods")
 		return err
 	}
 
-	picked, err := pickOldest(prefix, minPods, pods.Items)
+	candidates := generics.Convert(pods.Items, func(p corev1.Pod) corev1.Pod { return p },
+		func(p corev1.Pod) bool {
+			return opts.priorityClass.Match(&p) && opts.namespaceScope.Match(&p) &&
+				(opts.node == "" || p.Spec.NodeName == opts.node)
+		})
+	picked, err := pickOldest(opts.matches, opts.ready(), opts.minPods, candidates, opts.sortBy)
 	if err != nil {
 		log.Error(err, "failed to pick oldest pod")
 		return err
 	}
-	if err := kube.DeletePod(ctx, client, *picked); err != nil {
+	kube.AuditPodDeletion(ctx, picked, "oldest", "delete-oldest")
+	if err := kube.DeletePodWithPolicy(ctx, client, *picked, opts.propagation); err != nil {
 		log.Error(err, "failed to delete pod")
 		return err
 	}
@@ -100,21 +246,42 @@ func deleteOldestPods(ctx context.Context, client kubernetes.Interface, namespac
 	return nil
 }
 
-func pickOldest(prefix string, min int, pods []corev1.Pod) (*corev1.Pod, error) {
-	var oldest corev1.Pod
+// pickOldest picks the oldest ready pod that matches, e.g. whose name has the prefix and that has
+// the annotation, ordered by sortBy. Pods with the same timestamp are ordered by name.
+// ready checks if a pod is ready, e.g. kube.IsPodReadyRunning.
+// It returns an error if fewer than min pods match.
+func pickOldest(match func(*corev1.Pod) bool, ready func(corev1.Pod) bool, min int, pods []corev1.Pod, sortBy string) (*corev1.Pod, error) {
+	var oldest *corev1.Pod
 	count := 0
-	for _, p := range pods {
-		if !kube.IsPodReadyRunning(p) || !strings.HasPrefix(p.Name, prefix) {
+	for i := range pods {
+		p := &pods[i]
+		if !ready(*p) || kube.IsPodTerminating(p) || !match(p) {
 			continue
 		}
-		if oldest.Status.StartTime == nil ||
-			oldest.Status.StartTime.Time.After(p.Status.StartTime.Time) {
+		if oldest == nil || isOlder(p, oldest, sortBy) {
 			oldest = p
 		}
 		count++
 	}
-	if count >= min {
-		return &oldest, nil
+	if count >= min && oldest != nil {
+		return oldest, nil
 	}
 	return nil, errors.Errorf("Found only %v pods. Should at least %v pods running.", count, min)
 }
+
+// isOlder reports whether pod a is older than pod b, breaking ties by name.
+func isOlder(a, b *corev1.Pod, sortBy string) bool {
+	ta, tb := podTime(a, sortBy), podTime(b, sortBy)
+	if !ta.Equal(tb) {
+		return ta.Before(tb)
+	}
+	return a.Name < b.Name
+}
+
+// podTime returns the timestamp of the pod used for ordering.
+func podTime(pod *corev1.Pod, sortBy string) time.Time {
+	if sortBy == sortByCreationTime {
+		return pod.CreationTimestamp.Time
+	}
+	return kube.PodStartTime(pod)
+}
@@ -27,12 +27,19 @@ package deleteoldest
 import (
 	"context"
 	"testing"
+	"time"
 
+	"github.com/norseto/k8s-watchdogs/pkg/kube"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes/fake"
 )
 
+// prefix returns a matcher of the pod name prefix.
+func prefix(p string) func(*corev1.Pod) bool {
+	return deleteOptions{prefix: p}.matches
+}
+
 func TestDeleteOldestPods(t *testing.T) {
 	ctx := context.Background()
 	client := fake.NewSimpleClientset(&corev1.Pod{
@@ -46,20 +53,78 @@ func TestDeleteOldestPods(t *testing.T) {
 			Namespace: "test-ns",
 		},
 	})
-	err := deleteOldestPods(ctx, client, "test-ns", "test", 3)
+	err := deleteOldestPods(ctx, client, deleteOptions{namespace: "test-ns", prefix: "test", minPods: 3})
 	if err == nil {
 		t.Errorf("Expected error, but got nil")
 	}
-	err = deleteOldestPods(ctx, client, "test-ns", "test-pods", 2)
+	err = deleteOldestPods(ctx, client, deleteOptions{namespace: "test-ns", prefix: "test-pods", minPods: 2})
 	if err == nil {
 		t.Errorf("Expected error, but got nil")
 	}
-	err = deleteOldestPods(ctx, client, "test-ns", "test-pod", 1)
+	err = deleteOldestPods(ctx, client, deleteOptions{namespace: "test-ns", prefix: "test-pod", minPods: 1})
 	if err != nil {
 		t.Errorf("Expected nil, but got %v", err)
 	}
 }
 
+func TestDeleteOldestPods_PriorityClass(t *testing.T) {
+	ctx := context.Background()
+	newPod := func(name, priorityClass string) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "test-ns"},
+			Spec:       corev1.PodSpec{PriorityClassName: priorityClass},
+		}
+	}
+	client := fake.NewSimpleClientset(newPod("test-pod-1", "critical"), newPod("test-pod-2", "normal"))
+
+	err := deleteOldestPods(ctx, client, deleteOptions{namespace: "test-ns", prefix: "test-pod", minPods: 1,
+		priorityClass: kube.PriorityClassFilter{Exclude: []string{"normal"}}})
+	if err != nil {
+		t.Fatalf("Expected nil, but got %v", err)
+	}
+	pods, _ := client.CoreV1().Pods("test-ns").List(ctx, metav1.ListOptions{})
+	if len(pods.Items) != 1 || pods.Items[0].Name != "test-pod-2" {
+		t.Errorf("Expected only test-pod-2 to remain, but got %v", pods.Items)
+	}
+
+	err = deleteOldestPods(ctx, client, deleteOptions{namespace: "test-ns", prefix: "test-pod", minPods: 1,
+		priorityClass: kube.PriorityClassFilter{Include: []string{"critical"}}})
+	if err == nil {
+		t.Errorf("Expected error, but got nil")
+	}
+}
+
+func TestDeleteOldestPods_Node(t *testing.T) {
+	ctx := context.Background()
+	newPod := func(name, node string) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "test-ns"},
+			Spec:       corev1.PodSpec{NodeName: node},
+		}
+	}
+	client := fake.NewSimpleClientset(newPod("test-pod-1", "node-a"), newPod("test-pod-2", "node-b"),
+		newPod("test-pod-3", "node-b"))
+
+	err := deleteOldestPods(ctx, client, deleteOptions{namespace: "test-ns", prefix: "test-pod", minPods: 2,
+		node: "node-a"})
+	if err == nil {
+		t.Errorf("Expected error, but got nil")
+	}
+
+	err = deleteOldestPods(ctx, client, deleteOptions{namespace: "test-ns", prefix: "test-pod", minPods: 2,
+		node: "node-b"})
+	if err != nil {
+		t.Fatalf("Expected nil, but got %v", err)
+	}
+	if _, err := client.CoreV1().Pods("test-ns").Get(ctx, "test-pod-2", metav1.GetOptions{}); err == nil {
+		t.Errorf("Expected test-pod-2 on node-b to be deleted")
+	}
+	pods, _ := client.CoreV1().Pods("test-ns").List(ctx, metav1.ListOptions{})
+	if len(pods.Items) != 2 {
+		t.Errorf("Expected 2 pods to remain, but got %v", pods.Items)
+	}
+}
+
 func TestPickOldest(t *testing.T) {
 	pods := []corev1.Pod{
 		{
@@ -87,22 +152,208 @@ func TestPickOldest(t *testing.T) {
 			},
 		},
 	}
-	pod, err := pickOldest("test", 3, pods)
+	pod, err := pickOldest(prefix("test"), kube.IsPodReadyRunning, 3, pods, sortByStartTime)
 	if pod == nil || err != nil {
 		t.Errorf("Expected pod, but got nil or error %v", err)
 	}
-	pod, err = pickOldest("test", 4, pods)
+	pod, err = pickOldest(prefix("test"), kube.IsPodReadyRunning, 4, pods, sortByStartTime)
 	if pod != nil || err == nil {
 		t.Errorf("Expected error, but got nil or pod %v", pod)
 	}
-	pod, err = pickOldest("test-pod", 2, pods)
+	pod, err = pickOldest(prefix("test-pod"), kube.IsPodReadyRunning, 2, pods, sortByStartTime)
 	if pod == nil || err != nil {
 		t.Errorf("Expected pod, but got nil or error %v", err)
 	}
-	pod, err = pickOldest("test-pod", 4, pods)
+	pod, err = pickOldest(prefix("test-pod"), kube.IsPodReadyRunning, 4, pods, sortByStartTime)
+	if pod != nil || err == nil {
+		t.Errorf("Expected error, but got nil or pod %v", pod)
+	}
+
+	// Terminating pods are neither counted nor picked
+	pods[0].DeletionTimestamp = &metav1.Time{}
+	pod, err = pickOldest(prefix("test-pod"), kube.IsPodReadyRunning, 3, pods, sortByStartTime)
 	if pod != nil || err == nil {
 		t.Errorf("Expected error, but got nil or pod %v", pod)
 	}
+	pod, err = pickOldest(prefix("test-pod"), kube.IsPodReadyRunning, 2, pods, sortByStartTime)
+	if pod == nil || err != nil || pod.Name == "test-pod-1" {
+		t.Errorf("Expected a non terminating pod, but got %v or error %v", pod, err)
+	}
+}
+
+func TestPickOldest_SortBy(t *testing.T) {
+	base := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	newPod := func(name string, created, started time.Duration) corev1.Pod {
+		p := corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+			Name: name, CreationTimestamp: metav1.NewTime(base.Add(created)),
+		}}
+		if started != 0 {
+			p.Status.StartTime = &metav1.Time{Time: base.Add(started)}
+		}
+		return p
+	}
+	pods := []corev1.Pod{
+		newPod("pod-b", 0, time.Hour),
+		newPod("pod-c", time.Minute, 0),
+		newPod("pod-a", 0, time.Hour),
+	}
+
+	tests := []struct {
+		name   string
+		sortBy string
+		want   string
+	}{
+		{name: "start time with nil fallback", sortBy: sortByStartTime, want: "pod-c"},
+		{name: "creation time with name tiebreak", sortBy: sortByCreationTime, want: "pod-a"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pod, err := pickOldest(prefix("pod"), kube.IsPodReadyRunning, 3, pods, tt.sortBy)
+			if err != nil {
+				t.Fatalf("Expected nil, but got %v", err)
+			}
+			if pod.Name != tt.want {
+				t.Errorf("Expected %s, but got %s", tt.want, pod.Name)
+			}
+		})
+	}
+
+	if err := validateSortBy("name"); err == nil {
+		t.Errorf("Expected error, but got nil")
+	}
+}
+
+func TestPickOldest_EqualStartTimes(t *testing.T) {
+	started := metav1.NewTime(time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC))
+	newPod := func(name string) corev1.Pod {
+		return corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Status:     corev1.PodStatus{StartTime: &started},
+		}
+	}
+	orders := [][]string{
+		{"pod-a", "pod-b"},
+		{"pod-b", "pod-a"},
+	}
+	for i := 0; i < 10; i++ {
+		for _, order := range orders {
+			pods := []corev1.Pod{newPod(order[0]), newPod(order[1])}
+			pod, err := pickOldest(prefix("pod"), kube.IsPodReadyRunning, 2, pods, sortByStartTime)
+			if err != nil {
+				t.Fatalf("Expected nil, but got %v", err)
+			}
+			if pod.Name != "pod-a" {
+				t.Errorf("Expected pod-a for order %v, but got %s", order, pod.Name)
+			}
+		}
+	}
+}
+
+func TestPickOldest_MatchAnnotation(t *testing.T) {
+	newPod := func(name, team string) corev1.Pod {
+		p := corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: name}}
+		if team != "" {
+			p.Annotations = map[string]string{"example.com/team": team}
+		}
+		return p
+	}
+	pods := []corev1.Pod{newPod("api-a", "web"), newPod("api-b", "db"), newPod("batch-a", "web"), newPod("batch-b", "")}
+
+	tests := []struct {
+		name    string
+		opts    deleteOptions
+		min     int
+		want    string
+		wantErr bool
+	}{
+		{name: "annotation only", opts: deleteOptions{annotationKey: "example.com/team", annotationValue: "web"},
+			min: 2, want: "api-a"},
+		{name: "prefix and annotation", opts: deleteOptions{prefix: "batch", annotationKey: "example.com/team",
+			annotationValue: "web"}, min: 1, want: "batch-a"},
+		{name: "both must match", opts: deleteOptions{prefix: "batch", annotationKey: "example.com/team",
+			annotationValue: "web"}, min: 2, wantErr: true},
+		{name: "empty value", opts: deleteOptions{annotationKey: "example.com/team"}, min: 1, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pod, err := pickOldest(tt.opts.matches, kube.IsPodReadyRunning, tt.min, pods, sortByCreationTime)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Expected error, but got %v", pod)
+				}
+				return
+			}
+			if err != nil || pod.Name != tt.want {
+				t.Errorf("Expected %s, but got %v or error %v", tt.want, pod, err)
+			}
+		})
+	}
+}
+
+func TestPickOldest_ReadyCondition(t *testing.T) {
+	const gate = "example.com/warmed-up"
+	newPod := func(name string, created int, ready bool, warmed corev1.ConditionStatus) corev1.Pod {
+		return corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name,
+				CreationTimestamp: metav1.NewTime(time.Date(2024, 1, created, 0, 0, 0, 0, time.UTC))},
+			Status: corev1.PodStatus{
+				Phase:             corev1.PodRunning,
+				ContainerStatuses: []corev1.ContainerStatus{{Ready: ready}},
+				Conditions:        []corev1.PodCondition{{Type: gate, Status: warmed}},
+			},
+		}
+	}
+	pods := []corev1.Pod{
+		newPod("pod-a", 1, false, corev1.ConditionTrue),
+		newPod("pod-b", 2, true, corev1.ConditionFalse),
+		newPod("pod-c", 3, true, corev1.ConditionTrue),
+	}
+
+	pod, err := pickOldest(prefix("pod"), deleteOptions{}.ready(), 2, pods, sortByCreationTime)
+	if err != nil || pod.Name != "pod-b" {
+		t.Errorf("Expected pod-b, but got %v or error %v", pod, err)
+	}
+	pod, err = pickOldest(prefix("pod"), deleteOptions{readyCondition: gate}.ready(), 2, pods, sortByCreationTime)
+	if err != nil || pod.Name != "pod-a" {
+		t.Errorf("Expected pod-a, but got %v or error %v", pod, err)
+	}
+	if _, err := pickOldest(prefix("pod"), deleteOptions{readyCondition: gate}.ready(), 3, pods, sortByCreationTime); err == nil {
+		t.Errorf("Expected error with only 2 pods of the condition")
+	}
+}
+
+func TestParseMatchAnnotation(t *testing.T) {
+	key, value, err := parseMatchAnnotation("example.com/team=web")
+	if err != nil || key != "example.com/team" || value != "web" {
+		t.Errorf("Unexpected %q=%q or error %v", key, value, err)
+	}
+	key, value, err = parseMatchAnnotation("")
+	if err != nil || key != "" || value != "" {
+		t.Errorf("Unexpected %q=%q or error %v", key, value, err)
+	}
+	for _, v := range []string{"team", "=web", "bad key=web"} {
+		if _, _, err := parseMatchAnnotation(v); err == nil {
+			t.Errorf("Expected error for %q, but got nil", v)
+		}
+	}
+}
+
+func TestValidateMinPods(t *testing.T) {
+	tests := []struct {
+		minPods, limit int
+		wantErr        bool
+	}{
+		{3, defaultMaxMinPods, false},
+		{defaultMaxMinPods, defaultMaxMinPods, false},
+		{defaultMaxMinPods + 1, defaultMaxMinPods, true},
+		{5000, 5000, false},
+		{1, 0, true},
+	}
+	for _, tt := range tests {
+		if err := validateMinPods(tt.minPods, tt.limit); (err != nil) != tt.wantErr {
+			t.Errorf("validateMinPods(%d, %d) error = %v, wantErr %v", tt.minPods, tt.limit, err, tt.wantErr)
+		}
+	}
 }
 
 func TestNewCommand(t *testing.T) {
@@ -0,0 +1,139 @@
+/*
+MIT License
+
+Copyright (c) 2024 Norihiro Seto
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package drainnode
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/norseto/k8s-watchdogs/internal/preflight"
+	"github.com/norseto/k8s-watchdogs/internal/validation"
+	"github.com/norseto/k8s-watchdogs/pkg/kube"
+	"github.com/norseto/k8s-watchdogs/pkg/kube/client"
+	"github.com/norseto/k8s-watchdogs/pkg/logger"
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	mirrorPodAnnotation = "kubernetes.io/config.mirror"
+)
+
+// NewCommand returns a new Cobra command for draining a node.
+func NewCommand() *cobra.Command {
+	var gracePeriod int
+
+	cmd := &cobra.Command{
+		Use:   "drain-node NODE",
+		Short: "Cordon a node and evict its pods",
+		Long: "Cordon a node and evict its pods, skipping DaemonSet and mirror pods. " +
+			"Use the global --timeout flag to bound the whole operation.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := kube.WithEvictionCache(cmd.Context())
+			if err := validation.ValidateResourceName(args[0]); err != nil {
+				logger.FromContext(ctx).Error(err, "invalid node name")
+				return err
+			}
+			clnt, err := client.NewClientset(client.FromContext(ctx))
+			if err != nil {
+				logger.FromContext(ctx).Error(err, "failed to create client")
+				return err
+			}
+			if done, err := preflight.Run(ctx, clnt); done {
+				return err
+			}
+			return drainNode(ctx, clnt, args[0], gracePeriod)
+		},
+		Args: cobra.ExactArgs(1),
+	}
+
+	flg := cmd.Flags()
+	flg.IntVar(&gracePeriod, "grace-period", -1,
+		"Period of time in seconds given to each pod to terminate gracefully. Negative uses the pod's default.")
+
+	return cmd
+}
+
+// +kubebuilder:rbac:groups=core,resources=nodes,verbs=get;list;patch
+// +kubebuilder:rbac:groups=core,resources=pods,verbs=get;list
+// +kubebuilder:rbac:groups=core,resources=pods/eviction,verbs=create
+
+// drainNode cordons the node and evicts the pods running on it.
+func drainNode(ctx context.Context, client kubernetes.Interface, nodeName string, gracePeriod int) error {
+	log := logger.FromContext(ctx, "node", nodeName)
+
+	if err := kube.CordonNode(ctx, client, nodeName); err != nil {
+		log.Error(err, "failed to cordon node")
+		return err
+	}
+	log.Info("cordoned")
+
+	pods, err := client.CoreV1().Pods(metav1.NamespaceAll).List(ctx, metav1.ListOptions{
+		FieldSelector: fields.OneTermEqualSelector("spec.nodeName", nodeName).String(),
+	})
+	if err != nil {
+		log.Error(err, "failed to list pods")
+		return err
+	}
+
+	var grace *int64
+	if gracePeriod >= 0 {
+		g := int64(gracePeriod)
+		grace = &g
+	}
+
+	evicted, failed := 0, 0
+	for _, pod := range kube.FilterPods(pods, func(pod *corev1.Pod) bool {
+		return pod.Spec.NodeName == nodeName && isEvictable(pod)
+	}) {
+		if err := kube.EvictPod(ctx, client, *pod, grace); err != nil {
+			log.Error(err, "failed to evict pod", "pod", fmt.Sprintf("%s/%s", pod.Namespace, pod.Name))
+			failed++
+			continue
+		}
+		evicted++
+	}
+
+	log.Info("pods evict result", "evicted", evicted, "failed", failed)
+	if failed > 0 {
+		return fmt.Errorf("failed to evict %d pods from node %s", failed, nodeName)
+	}
+	return nil
+}
+
+// isEvictable checks if the pod should be evicted from the node.
+// DaemonSet pods and mirror pods are skipped since they are not rescheduled by eviction.
+func isEvictable(pod *corev1.Pod) bool {
+	if _, ok := pod.Annotations[mirrorPodAnnotation]; ok {
+		return false
+	}
+	if kube.IsOwnedByDaemonSet(pod) {
+		return false
+	}
+	return kube.CanBeRebalanced(pod)
+}
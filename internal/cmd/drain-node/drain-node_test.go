@@ -0,0 +1,111 @@
+/*
+MIT License
+
+Copyright (c) 2024 Norihiro Seto
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package drainnode
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestNewCommand(t *testing.T) {
+	cmd := NewCommand()
+	assert.Equal(t, "drain-node NODE", cmd.Use)
+	assert.NotNil(t, cmd.Flag("grace-period"))
+}
+
+func nodePod(name, node string, opt ...func(p *corev1.Pod)) *corev1.Pod {
+	p := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Spec:       corev1.PodSpec{NodeName: node},
+	}
+	for _, o := range opt {
+		o(p)
+	}
+	return p
+}
+
+func evictedNames(client *fake.Clientset) []string {
+	var names []string
+	for _, a := range client.Actions() {
+		if a.GetVerb() == "create" && a.GetSubresource() == "eviction" {
+			names = append(names, a.(k8stesting.CreateAction).GetObject().(*policyv1.Eviction).Name)
+		}
+	}
+	return names
+}
+
+func TestDrainNode(t *testing.T) {
+	ctx := context.Background()
+	node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}}
+	client := fake.NewSimpleClientset(node,
+		nodePod("app", "node-1"),
+		nodePod("other-node", "node-2"),
+		nodePod("ds", "node-1", func(p *corev1.Pod) {
+			p.OwnerReferences = []metav1.OwnerReference{{Kind: "DaemonSet", Name: "ds"}}
+		}),
+		nodePod("mirror", "node-1", func(p *corev1.Pod) {
+			p.Annotations = map[string]string{mirrorPodAnnotation: "hash"}
+		}),
+		nodePod("protected", "node-1", func(p *corev1.Pod) {
+			p.Annotations = map[string]string{"cluster-autoscaler.kubernetes.io/safe-to-evict": "false"}
+		}),
+		nodePod("terminating", "node-1", func(p *corev1.Pod) {
+			p.DeletionTimestamp = &metav1.Time{Time: time.Now().Add(30 * time.Second)}
+			p.Finalizers = []string{"example.com/hold"}
+		}),
+	)
+
+	err := drainNode(ctx, client, "node-1", 5)
+	assert.NoError(t, err)
+
+	updated, err := client.CoreV1().Nodes().Get(ctx, "node-1", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.True(t, updated.Spec.Unschedulable)
+	assert.Equal(t, []string{"app"}, evictedNames(client))
+}
+
+func TestNewCommand_InvalidName(t *testing.T) {
+	// The name is checked before connecting to the cluster.
+	cmd := NewCommand()
+	cmd.SetArgs([]string{"Invalid_Node"})
+	cmd.SetOut(io.Discard)
+	cmd.SetErr(io.Discard)
+	assert.ErrorContains(t, cmd.Execute(), "Invalid_Node")
+}
+
+func TestDrainNode_NodeNotFound(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	err := drainNode(context.Background(), client, "node-1", -1)
+	assert.Error(t, err)
+}
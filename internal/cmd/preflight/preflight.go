@@ -0,0 +1,239 @@
+/*
+MIT License
+
+Copyright (c) 2024 Norihiro Seto
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package preflight
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/norseto/k8s-watchdogs/internal/options"
+	"github.com/norseto/k8s-watchdogs/internal/output"
+	"github.com/norseto/k8s-watchdogs/pkg/kube/client"
+	"github.com/norseto/k8s-watchdogs/pkg/logger"
+	"github.com/spf13/cobra"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// NewCommand returns a new Cobra command for checking the connectivity and permissions.
+func NewCommand() *cobra.Command {
+	opts := &options.Options{}
+	cmd := &cobra.Command{
+		Use:   "preflight [command...]",
+		Short: "Check the connection to the API server and the permissions of commands",
+		Long: "Check the connection to the API server and whether the permissions that the commands " +
+			"need are allowed. All commands are checked when none is given.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			clnt, err := client.NewClientset(client.FromContext(ctx))
+			if err != nil {
+				logger.FromContext(ctx).Error(err, "failed to create client")
+				return err
+			}
+			return runPreflight(ctx, clnt, opts.Namespace(), args, cmd.OutOrStdout(), output.FromContext(ctx).Format())
+		},
+	}
+	opts.BindCommonFlags(cmd)
+	return cmd
+}
+
+// permission represents an API access that a command needs.
+type permission struct {
+	group       string
+	resource    string
+	subresource string
+	verb        string
+	// cluster is true for cluster scoped resources, which are checked without a namespace.
+	cluster bool
+}
+
+var (
+	listPods      = permission{resource: "pods", verb: "list"}
+	deletePods    = permission{resource: "pods", verb: "delete"}
+	listNodes     = permission{resource: "nodes", verb: "list", cluster: true}
+	patchNodes    = permission{resource: "nodes", verb: "patch", cluster: true}
+	evictPods     = permission{resource: "pods", subresource: "eviction", verb: "create"}
+	listRS        = permission{group: "apps", resource: "replicasets", verb: "list"}
+	patchRS       = permission{group: "apps", resource: "replicasets", verb: "patch"}
+	deleteRS      = permission{group: "apps", resource: "replicasets", verb: "delete"}
+	getDeploy     = permission{group: "apps", resource: "deployments", verb: "get"}
+	listDeploy    = permission{group: "apps", resource: "deployments", verb: "list"}
+	patchDeploy   = permission{group: "apps", resource: "deployments", verb: "patch"}
+	getSts        = permission{group: "apps", resource: "statefulsets", verb: "get"}
+	listSts       = permission{group: "apps", resource: "statefulsets", verb: "list"}
+	patchSts      = permission{group: "apps", resource: "statefulsets", verb: "patch"}
+	listDS        = permission{group: "apps", resource: "daemonsets", verb: "list"}
+	patchDS       = permission{group: "apps", resource: "daemonsets", verb: "patch"}
+	listNamespace = permission{resource: "namespaces", verb: "list", cluster: true}
+	listPDB       = permission{group: "policy", resource: "poddisruptionbudgets", verb: "list"}
+)
+
+// commandPermissions maps the commands to the permissions they need.
+var commandPermissions = map[string][]permission{
+	"clean-completed":   {listPods, deletePods},
+	"clean-evicted":     {listPods, deletePods, listNamespace},
+	"clean-failed":      {listPods, deletePods, listNamespace},
+	"clean-pending":     {listPods, deletePods},
+	"clean-replicasets": {listRS, deleteRS},
+	"delete-oldest":     {listPods, deletePods},
+	"drain-node":        {listNodes, patchNodes, listPods, evictPods},
+	"rebalance-pods":    {listPods, deletePods, listNodes, listRS, patchRS, getDeploy, listPDB},
+	"restart-all":       {listDeploy, patchDeploy, listSts, patchSts, listDS, patchDS},
+	"restart-deploy":    {getDeploy, listDeploy, patchDeploy},
+	"restart-sts":       {getSts, listSts, patchSts},
+	"scale":             {listDeploy, patchDeploy, listSts, patchSts},
+}
+
+// Result represents whether a permission of a command is allowed.
+type Result struct {
+	Command   string `json:"command"`
+	Verb      string `json:"verb"`
+	Resource  string `json:"resource"`
+	Namespace string `json:"namespace,omitempty"`
+	Allowed   bool   `json:"allowed"`
+	Reason    string `json:"reason,omitempty"`
+}
+
+// resourceName returns the resource in the form of kubectl auth can-i, e.g. deployments.apps.
+func (p permission) resourceName() string {
+	name := p.resource
+	if p.group != "" {
+		name += "." + p.group
+	}
+	if p.subresource != "" {
+		name += "/" + p.subresource
+	}
+	return name
+}
+
+// +kubebuilder:rbac:groups=authorization.k8s.io,resources=selfsubjectaccessreviews,verbs=create
+
+// runPreflight checks the server version and the permissions of the commands, then writes the results.
+// It returns an error if the server cannot be reached or any permission is not allowed.
+func runPreflight(ctx context.Context, client kubernetes.Interface, namespace string, commands []string, w io.Writer, format string) error {
+	log := logger.FromContext(ctx)
+
+	ver, err := client.Discovery().ServerVersion()
+	if err != nil {
+		err = fmt.Errorf("failed to get server version: %w", err)
+		log.Error(err, "failed to connect to the API server")
+		return err
+	}
+	log.Info("connected to the API server", "serverVersion", ver.GitVersion)
+
+	results, err := checkPermissions(ctx, client, namespace, commands)
+	if err != nil {
+		log.Error(err, "failed to check permissions")
+		return err
+	}
+	if err := writeResults(w, format, results); err != nil {
+		log.Error(err, "failed to write results")
+		return err
+	}
+
+	denied := 0
+	for _, r := range results {
+		if !r.Allowed {
+			denied++
+		}
+	}
+	if denied > 0 {
+		return fmt.Errorf("%d of %d permissions are not allowed", denied, len(results))
+	}
+	log.Info("all permissions are allowed", "checked", len(results))
+	return nil
+}
+
+// checkPermissions reviews the permissions of the commands with SelfSubjectAccessReviews.
+// All commands are checked when commands is empty.
+func checkPermissions(ctx context.Context, client kubernetes.Interface, namespace string, commands []string) ([]Result, error) {
+	if len(commands) == 0 {
+		for name := range commandPermissions {
+			commands = append(commands, name)
+		}
+		sort.Strings(commands)
+	}
+
+	var results []Result
+	for _, command := range commands {
+		perms, ok := commandPermissions[command]
+		if !ok {
+			return nil, fmt.Errorf("unknown command %q", command)
+		}
+		for _, p := range perms {
+			ns := namespace
+			if p.cluster {
+				ns = ""
+			}
+			review := &authorizationv1.SelfSubjectAccessReview{
+				Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+					ResourceAttributes: &authorizationv1.ResourceAttributes{
+						Namespace:   ns,
+						Verb:        p.verb,
+						Group:       p.group,
+						Resource:    p.resource,
+						Subresource: p.subresource,
+					},
+				},
+			}
+			res, err := client.AuthorizationV1().SelfSubjectAccessReviews().Create(ctx, review, metav1.CreateOptions{})
+			if err != nil {
+				return nil, fmt.Errorf("failed to review %s %s: %w", p.verb, p.resourceName(), err)
+			}
+			results = append(results, Result{
+				Command:   command,
+				Verb:      p.verb,
+				Resource:  p.resourceName(),
+				Namespace: ns,
+				Allowed:   res.Status.Allowed,
+				Reason:    res.Status.Reason,
+			})
+		}
+	}
+	return results, nil
+}
+
+// writeResults writes the results as JSON or as aligned columns.
+func writeResults(w io.Writer, format string, results []Result) error {
+	if format == output.FormatJSON {
+		return json.NewEncoder(w).Encode(results)
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "COMMAND\tVERB\tRESOURCE\tNAMESPACE\tALLOWED")
+	for _, r := range results {
+		ns := r.Namespace
+		if ns == "" {
+			ns = "*"
+		}
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", r.Command, r.Verb, r.Resource, ns, strconv.FormatBool(r.Allowed))
+	}
+	return tw.Flush()
+}
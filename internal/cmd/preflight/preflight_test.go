@@ -0,0 +1,88 @@
+/*
+MIT License
+
+Copyright (c) 2024 Norihiro Seto
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package preflight
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/norseto/k8s-watchdogs/internal/output"
+	"github.com/stretchr/testify/assert"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// newClient returns a client that allows everything except deleting pods.
+func newClient() *fake.Clientset {
+	client := fake.NewSimpleClientset()
+	client.PrependReactor("create", "selfsubjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		review := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SelfSubjectAccessReview)
+		attrs := review.Spec.ResourceAttributes
+		review.Status.Allowed = !(attrs.Resource == "pods" && attrs.Verb == "delete")
+		return true, review, nil
+	})
+	return client
+}
+
+func TestCheckPermissions(t *testing.T) {
+	ctx := context.Background()
+
+	results, err := checkPermissions(ctx, newClient(), "apps", []string{"clean-evicted"})
+	assert.NoError(t, err)
+	assert.Equal(t, []Result{
+		{Command: "clean-evicted", Verb: "list", Resource: "pods", Namespace: "apps", Allowed: true},
+		{Command: "clean-evicted", Verb: "delete", Resource: "pods", Namespace: "apps", Allowed: false},
+		{Command: "clean-evicted", Verb: "list", Resource: "namespaces", Allowed: true},
+	}, results)
+
+	results, err = checkPermissions(ctx, newClient(), "", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "clean-completed", results[0].Command)
+	assert.Contains(t, results, Result{Command: "drain-node", Verb: "create", Resource: "pods/eviction", Allowed: true})
+	assert.Contains(t, results, Result{Command: "restart-deploy", Verb: "patch", Resource: "deployments.apps", Allowed: true})
+
+	_, err = checkPermissions(ctx, newClient(), "", []string{"unknown"})
+	assert.Error(t, err)
+}
+
+func TestRunPreflight(t *testing.T) {
+	ctx := context.Background()
+
+	buf := &bytes.Buffer{}
+	assert.NoError(t, runPreflight(ctx, newClient(), "default", []string{"restart-sts"}, buf, output.FormatText))
+	assert.Contains(t, buf.String(), "COMMAND")
+	assert.Regexp(t, `restart-sts\s+patch\s+statefulsets.apps\s+default\s+true`, buf.String())
+
+	buf.Reset()
+	err := runPreflight(ctx, newClient(), "default", []string{"restart-sts", "clean-failed"}, buf, output.FormatJSON)
+	assert.ErrorContains(t, err, "1 of 6 permissions are not allowed")
+	var results []Result
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &results))
+	assert.Len(t, results, 6)
+}
@@ -29,10 +29,19 @@ package rebalancepods
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"time"
 
+	"github.com/norseto/k8s-watchdogs/internal/concurrent"
 	"github.com/norseto/k8s-watchdogs/internal/options"
+	"github.com/norseto/k8s-watchdogs/internal/output"
+	"github.com/norseto/k8s-watchdogs/internal/preflight"
 	"github.com/norseto/k8s-watchdogs/internal/rebalancer"
+	"github.com/norseto/k8s-watchdogs/internal/validation"
 	"github.com/norseto/k8s-watchdogs/pkg/generics"
 	"github.com/norseto/k8s-watchdogs/pkg/kube"
 	"github.com/norseto/k8s-watchdogs/pkg/kube/client"
@@ -41,50 +50,332 @@ import (
 
 	appsv1 "k8s.io/api/apps/v1"
 	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/client-go/kubernetes"
 )
 
 // NewCommand returns a new Cobra command for re-balancing pods.
 func NewCommand() *cobra.Command {
+	var rbOpts rebalanceOptions
+	var defaultRequest map[string]string
+	var weightTable map[string]string
+	var threshold float32
+
 	opts := &options.Options{}
 	cmd := &cobra.Command{
 		Use:   "rebalance-pods",
 		Short: "Delete bias scheduled pods",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			ctx := cmd.Context()
+			ctx := kube.WithNodeCache(cmd.Context())
+			request, err := parseDefaultRequest(defaultRequest)
+			if err != nil {
+				logger.FromContext(ctx).Error(err, "invalid default request")
+				return err
+			}
+			if threshold < 0 {
+				err := fmt.Errorf("invalid threshold %v: must not be negative", threshold)
+				logger.FromContext(ctx).Error(err, "invalid threshold")
+				return err
+			}
+			rbOpts.threshold = &threshold
+			if rbOpts.tolerance < 0 {
+				err := fmt.Errorf("invalid replica tolerance %d: must not be negative", rbOpts.tolerance)
+				logger.FromContext(ctx).Error(err, "invalid replica tolerance")
+				return err
+			}
+			if rbOpts.verify && rbOpts.verifyTimeout <= 0 {
+				err := fmt.Errorf("invalid verify timeout %v: must be positive", rbOpts.verifyTimeout)
+				logger.FromContext(ctx).Error(err, "invalid verify timeout")
+				return err
+			}
+			if err := validation.ValidateLabelKey(rbOpts.weightLabel); err != nil {
+				logger.FromContext(ctx).Error(err, "invalid balance weight label")
+				return err
+			}
+			if rbOpts.weights, err = parseWeights(weightTable); err != nil {
+				logger.FromContext(ctx).Error(err, "invalid balance weights")
+				return err
+			}
+			if rbOpts.weights != nil && rbOpts.weightLabel == "" {
+				err := fmt.Errorf("--balance-weights requires --balance-weight-label")
+				logger.FromContext(ctx).Error(err, "invalid balance weights")
+				return err
+			}
+			if _, err := labels.Parse(rbOpts.selector); err != nil {
+				err = fmt.Errorf("invalid selector %q: %w", rbOpts.selector, err)
+				logger.FromContext(ctx).Error(err, "invalid selector")
+				return err
+			}
+			if _, err := labels.Parse(rbOpts.nodeSelector); err != nil {
+				err = fmt.Errorf("invalid node selector %q: %w", rbOpts.nodeSelector, err)
+				logger.FromContext(ctx).Error(err, "invalid node selector")
+				return err
+			}
+			if rbOpts.callTimeout, err = opts.CallTimeout(); err != nil {
+				logger.FromContext(ctx).Error(err, "invalid call timeout")
+				return err
+			}
 			clnt, err := client.NewClientset(client.FromContext(ctx))
 			if err != nil {
 				logger.FromContext(ctx).Error(err, "failed to create client")
 				return err
 			}
-			return rebalancePods(cmd.Context(), clnt, opts.Namespace())
+			if done, err := preflight.Run(ctx, clnt); done {
+				return err
+			}
+			rbOpts.namespace = opts.Namespace()
+			rbOpts.priorityClass = opts.PriorityClassFilter()
+			rbOpts.namespaceScope = opts.NamespaceScope()
+			rbOpts.defaultRequest = request
+			rbOpts.reportOnly = opts.ReportOnly()
+			rbOpts.failOnFindings = opts.FailOnFindings()
+			if output.FromContext(ctx).Format() == output.FormatTable {
+				rbOpts.table = cmd.OutOrStdout()
+			}
+			return rebalancePods(ctx, clnt, rbOpts)
 		},
 	}
 	opts.BindCommonFlags(cmd)
+	opts.BindPriorityClassFlags(cmd)
+	opts.BindNamespaceScopeFlags(cmd)
+	opts.BindReportFlags(cmd)
+	opts.BindCallTimeoutFlags(cmd)
+
+	flg := cmd.Flags()
+	flg.StringVarP(&rbOpts.selector, "selector", "l", "",
+		"Label selector of the replicasets to rebalance (e.g. app=web). Empty means all replicasets.")
+	flg.StringVar(&rbOpts.nodeSelector, "node-selector", "",
+		"Label selector of the nodes to balance across (e.g. node-role.kubernetes.io/worker). "+
+			"Pods on other nodes are neither counted nor deleted. Empty means all nodes.")
+	flg.StringVar(&rbOpts.rebalance.AnnotationKey, "do-not-evict-annotation", "",
+		"Additional annotation key that excludes pods from rebalancing when set to \"false\". "+
+			kube.SafeToEvictAnnotation+" is always honored.")
+	flg.IntVar(&rbOpts.maxReplicaSets, "max-replicasets", 0,
+		"Maximum number of replicasets to rebalance per run. Candidates are picked round-robin "+
+			"across namespaces and owners. 0 means no limit.")
+	flg.IntVar(&rbOpts.maxPerNode, "max-per-node", 0,
+		"Maximum number of pods of a replicaset on a single node. Overflow pods are deleted "+
+			"within the rebalance rate. 0 means no limit.")
+	flg.BoolVar(&rbOpts.includeNotReady, "include-not-ready", false,
+		"Count running but not ready pods in the distribution. Only ready pods are deleted.")
+	flg.BoolVar(&rbOpts.respectAntiAffinity, "respect-anti-affinity", false,
+		"Skip deleting a pod when no other node satisfies its required pod anti-affinity.")
+	flg.BoolVar(&rbOpts.preferPressuredNodes, "prefer-pressured-nodes", false,
+		"Delete pods on nodes under memory or disk pressure first, even if the nodes are not the most populated.")
+	flg.Float32Var(&threshold, "threshold", rebalancer.DefaultThreshold,
+		"Slack over the average pods per node. Pods are deleted from the most populated node while "+
+			"its pod count >= replicas / nodes + threshold. Must not be negative.")
+	flg.IntVar(&rbOpts.tolerance, "replica-tolerance", 1,
+		"Only trim pods over the average when the difference between the most and the least pods per node "+
+			"exceeds this tolerance. With --balance-weight-label, the pods per node are measured from the weighted "+
+			"shares of the nodes. Must not be negative.")
+	flg.BoolVar(&rbOpts.onlyIfImbalanced, "only-if-imbalanced", false,
+		"Skip replicasets whose pods per node spread is within --replica-tolerance before evaluating them in detail. "+
+			"Replicasets over --max-per-node are still evaluated, and it has no effect with --prefer-pressured-nodes.")
+	flg.BoolVar(&rbOpts.respectPDB, "respect-pdb", false,
+		"Skip deleting a pod when a PodDisruptionBudget matching it currently allows no disruption.")
+	flg.BoolVar(&rbOpts.checkHeadroom, "check-headroom", false,
+		"Skip deleting a pod when no other node has enough free allocatable cpu and memory for it.")
+	flg.DurationVar(&rbOpts.cooldown, "cooldown", 0,
+		"Skip replicasets rebalanced within this duration (e.g. 30m), recorded in the "+
+			kube.LastRebalancedAnnotation+" annotation. Zero disables the cooldown and the annotation.")
+	flg.DurationVar(&rbOpts.deleteInterval, "delete-interval", 0,
+		"Wait this duration plus a small jitter between successive pod deletions (e.g. 2s). Zero deletes without waiting.")
+	flg.BoolVar(&rbOpts.verify, "verify", false,
+		"After each deletion, wait for a new pod of the replicaset to become ready before the next deletion. "+
+			"The run is aborted when no replacement becomes ready within --verify-timeout.")
+	flg.DurationVar(&rbOpts.verifyTimeout, "verify-timeout", defaultVerifyTimeout,
+		"Maximum duration to wait for a replacement pod with --verify. Must be positive.")
+	flg.IntVar(&rbOpts.retries, "retries", 0,
+		"Number of retries of a pod deletion failed with a conflict or server error. Zero makes a single attempt.")
+	flg.StringVar(&rbOpts.weightLabel, "balance-weight-label", "",
+		"Node label (e.g. node.kubernetes.io/instance-type) whose values are weighted with --balance-weights "+
+			"to normalize pod counts by node size. Nodes whose label value has no weight are weighted by their "+
+			"allocatable cpu cores. Empty balances the raw pod counts.")
+	flg.StringToStringVar(&weightTable, "balance-weights", nil,
+		"Weights of the --balance-weight-label values (e.g. m5.xlarge=4,m5.large=2). Must be positive and "+
+			"in the scale of cpu cores when some nodes fall back to their allocatable cpu.")
+	flg.StringToStringVar(&defaultRequest, "default-request", nil,
+		"Requests assumed for pods that request no cpu or memory when checking node capacity (e.g. cpu=100m,memory=128Mi).")
 	return cmd
 }
 
+// rebalanceOptions represents options for rebalancing pods.
+type rebalanceOptions struct {
+	namespace string
+	// selector is the label selector of the target replicasets. Empty means all.
+	selector string
+	// nodeSelector is the label selector of the nodes to balance across. Empty means all.
+	nodeSelector  string
+	rebalance     kube.RebalanceOpts
+	priorityClass kube.PriorityClassFilter
+	// namespaceScope restricts the namespaces targeted across all namespaces.
+	namespaceScope kube.NamespaceScope
+	// maxReplicaSets caps the number of replicasets rebalanced per run. 0 means no limit.
+	maxReplicaSets int
+	// maxPerNode caps the number of pods of a replicaset on a node. 0 means no limit.
+	maxPerNode int
+	// includeNotReady counts running but not ready pods in the distribution.
+	includeNotReady bool
+	// respectAntiAffinity skips pods that could not be rescheduled due to pod anti-affinity.
+	respectAntiAffinity bool
+	// defaultRequest is assumed for pods that request no cpu or memory.
+	defaultRequest v1.ResourceList
+	// retries is the number of retries of a failed pod deletion.
+	retries int
+	// deleteInterval is the wait between successive pod deletions. 0 disables it.
+	deleteInterval time.Duration
+	// preferPressuredNodes evacuates pods on nodes under pressure first.
+	preferPressuredNodes bool
+	// threshold is the slack over the average pods per node. nil uses rebalancer.DefaultThreshold.
+	threshold *float32
+	// tolerance is the tolerated spread of pods per node.
+	tolerance int
+	// onlyIfImbalanced skips replicasets within the tolerance without evaluating them in detail.
+	onlyIfImbalanced bool
+	// respectPDB skips pods whose PodDisruptionBudgets allow no disruption.
+	respectPDB bool
+	// checkHeadroom skips pods that no other node has free capacity for.
+	checkHeadroom bool
+	// cooldown skips replicasets rebalanced within the duration. 0 disables it.
+	cooldown time.Duration
+	// weightLabel is the node label whose values are weighted. Empty disables the weighting.
+	weightLabel string
+	// weights maps the values of the weight label to the node weights.
+	weights map[string]float64
+	// verify waits for a ready replacement after each deletion.
+	verify bool
+	// verifyTimeout is the maximum wait for a replacement with verify.
+	verifyTimeout time.Duration
+	// reportOnly lists the imbalanced replicasets without deleting pods.
+	reportOnly bool
+	// failOnFindings fails the run when imbalanced replicasets are found.
+	failOnFindings bool
+	// table receives the rebalance report as a table when not nil.
+	table io.Writer
+	// callTimeout bounds each list and delete call. 0 disables it.
+	callTimeout time.Duration
+}
+
+// defaultVerifyTimeout is the default maximum wait for a replacement pod.
+const defaultVerifyTimeout = 2 * time.Minute
+
+// now returns the current time. It is replaced in tests.
+var now = time.Now
+
+// parseDefaultRequest parses the cpu and memory quantities of the default request.
+func parseDefaultRequest(values map[string]string) (v1.ResourceList, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+	ret := v1.ResourceList{}
+	for name, value := range values {
+		rn := v1.ResourceName(name)
+		if rn != v1.ResourceCPU && rn != v1.ResourceMemory {
+			return nil, fmt.Errorf("unsupported default request resource %q, must be cpu or memory", name)
+		}
+		q, err := resource.ParseQuantity(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid default request %s=%s: %w", name, value, err)
+		}
+		if q.Sign() < 0 {
+			return nil, fmt.Errorf("invalid default request %s=%s: must not be negative", name, value)
+		}
+		ret[rn] = q
+	}
+	return ret, nil
+}
+
+// parseWeights parses the weights of the label values, which must be positive.
+func parseWeights(values map[string]string) (map[string]float64, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+	ret := make(map[string]float64, len(values))
+	for name, value := range values {
+		w, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid balance weight %s=%s: %w", name, value, err)
+		}
+		if !(w > 0) || math.IsInf(w, 1) {
+			return nil, fmt.Errorf("invalid balance weight %s=%s: must be positive", name, value)
+		}
+		ret[name] = w
+	}
+	return ret, nil
+}
+
+// nodeWeights returns the weights of the nodes keyed by the node names. A node is weighted by
+// the weight of its label value, falling back to its allocatable cpu cores.
+func nodeWeights(nodes []*v1.Node, label string, weights map[string]float64) (map[string]float64, error) {
+	ret := make(map[string]float64, len(nodes))
+	for _, n := range nodes {
+		if n == nil {
+			continue
+		}
+		if w, ok := weights[n.Labels[label]]; ok {
+			ret[n.Name] = w
+			continue
+		}
+		capacity, err := kube.GetNodeResourceCapacity(n)
+		if err != nil {
+			return nil, err
+		}
+		ret[n.Name] = capacity.Cpu().AsApproximateFloat64()
+	}
+	return ret, nil
+}
+
+// isNodeListUnavailable checks if listing nodes failed because the nodes are forbidden to the
+// service account or only partially listed, e.g. the continuation of a paged list has expired.
+func isNodeListUnavailable(err error) bool {
+	return apierrors.IsForbidden(err) || apierrors.IsResourceExpired(err)
+}
+
 // +kubebuilder:rbac:groups=core,resources=pods,verbs=get;list;delete
 // +kubebuilder:rbac:groups=core,resources=pods/status,verbs=get
 // +kubebuilder:rbac:groups=core,resources=nodes,verbs=get;list
-// +kubebuilder:rbac:groups=apps,resources=replicasets,verbs=get;list
+// +kubebuilder:rbac:groups=apps,resources=replicasets,verbs=get;list;patch
+// +kubebuilder:rbac:groups=apps,resources=deployments,verbs=get
+// +kubebuilder:rbac:groups=policy,resources=poddisruptionbudgets,verbs=list
 
-func rebalancePods(ctx context.Context, client kubernetes.Interface, namespace string) error {
+func rebalancePods(ctx context.Context, client kubernetes.Interface, opts rebalanceOptions) error {
 	log := logger.FromContext(ctx)
-	nodes, err := kube.GetAllNodes(ctx, client)
+	var nodes []*v1.Node
+	err := concurrent.CallWithTimeout(ctx, opts.callTimeout, func(ctx context.Context) (err error) {
+		nodes, err = kube.GetNodesBySelector(ctx, client, opts.nodeSelector)
+		return err
+	})
+	if err != nil && isNodeListUnavailable(err) {
+		// Balancing across an unknown set of nodes is not safe, but it is not worth failing the run either.
+		log.Error(err, "nodes unavailable, skip rebalancing")
+		return nil
+	}
 	if err != nil {
 		log.Error(err, "failed to list nodes")
 		return err
 	}
 
-	replicas, err := getTargetReplicaSets(ctx, client, namespace)
+	var replicas []*appsv1.ReplicaSet
+	err = concurrent.CallWithTimeout(ctx, opts.callTimeout, func(ctx context.Context) (err error) {
+		replicas, err = getTargetReplicaSets(ctx, client, opts.namespace, opts.selector)
+		return err
+	})
 	if err != nil {
 		log.Error(err, "failed to get replicaset")
 		return err
 	}
-	rs, err := getCandidatePods(ctx, client, namespace, nodes, replicas)
+	var weights map[string]float64
+	if opts.weightLabel != "" {
+		if weights, err = nodeWeights(nodes, opts.weightLabel, opts.weights); err != nil {
+			log.Error(err, "failed to weight nodes")
+			return err
+		}
+	}
+	rs, err := getCandidatePods(ctx, client, nodes, replicas, opts)
 	if err != nil {
 		log.Error(err, "failed to list pods")
 		return err
@@ -94,32 +385,194 @@ func rebalancePods(ctx context.Context, client kubernetes.Interface, namespace s
 		log.Info("No rs. Do nothing.")
 		return nil
 	}
+	if opts.maxReplicaSets > 0 && len(rs) > opts.maxReplicaSets {
+		log.Info("too many candidates, selecting a subset", "candidates", len(rs), "max", opts.maxReplicaSets)
+		rs = selectCandidates(rs, opts.maxReplicaSets)
+	}
 
-	rsStat := kube.NewReplicaSetStatus(replicas)
+	rsStat, err := kube.NewReplicaSetStatusByDeployment(ctx, client, replicas)
+	if err != nil {
+		log.Error(err, "failed to get deployments, falling back to owner count")
+		rsStat = kube.NewReplicaSetStatus(replicas)
+	}
+	threshold := float32(rebalancer.DefaultThreshold)
+	if opts.threshold != nil {
+		threshold = *opts.threshold
+	}
+	pacer := concurrent.NewPacer(opts.deleteInterval)
+	var pdbs *kube.PDBCache
+	if opts.respectPDB {
+		pdbs = kube.NewPDBCache(client)
+	}
+	report := &rebalancer.RebalanceReport{}
 	numRebalanced := 0
+	numImbalanced := 0
 	for _, r := range rs {
+		if err := ctx.Err(); err != nil {
+			log.Info("interrupted", "rebalanced", numRebalanced)
+			return err
+		}
 		name := r.Replicaset.Name
 		if rsStat.IsRollingUpdating(ctx, r.Replicaset) {
 			log.Info("May under rolling update. Leave untouched", "rs", name)
 			continue
 		}
-		result, err := rebalancer.NewRebalancer(ctx, r).Rebalance(ctx, client)
-		if err != nil {
+		if inCooldown(r.Replicaset, opts.cooldown) {
+			log.V(1).Info("Rebalanced recently. Leave untouched", "rs", name, "cooldown", opts.cooldown)
+			continue
+		}
+		log.V(1).Info("busiest nodes", "rs", name, "nodes", busiestNodes(r, busiestNodesToLog))
+		imbalanced := isImbalanced(r, opts, weights)
+		if imbalanced {
+			numImbalanced++
+		}
+		if opts.reportOnly {
+			if imbalanced {
+				log.Info("Imbalanced", "rs", name, "namespace", r.Replicaset.Namespace,
+					"spread", r.WeightedPodSpread(weights), "nodes", busiestNodes(r, busiestNodesToLog))
+			}
+			continue
+		}
+		if opts.onlyIfImbalanced && !imbalanced {
+			log.V(1).Info("Within tolerance. Leave untouched", "rs", name, "spread", r.WeightedPodSpread(weights))
+			continue
+		}
+		result, err := rebalancer.NewRebalancer(ctx, r,
+			rebalancer.WithMaxPerNode(opts.maxPerNode),
+			rebalancer.WithRespectAntiAffinity(opts.respectAntiAffinity),
+			rebalancer.WithDefaultRequest(opts.defaultRequest),
+			rebalancer.WithDeleteRetries(opts.retries),
+			rebalancer.WithPreferPressuredNodes(opts.preferPressuredNodes),
+			rebalancer.WithCheckHeadroom(opts.checkHeadroom),
+			rebalancer.WithThreshold(threshold),
+			rebalancer.WithReplicaTolerance(opts.tolerance),
+			rebalancer.WithPacer(pacer),
+			rebalancer.WithPDBCache(pdbs),
+			rebalancer.WithNodeWeights(weights),
+			rebalancer.WithVerify(verifyTimeout(opts)),
+			rebalancer.WithCallTimeout(opts.callTimeout),
+			// The pods on the nodes out of the selector are not counted, so neither are they in the average.
+			rebalancer.WithCountedReplicas(opts.nodeSelector != ""),
+			rebalancer.WithCommand("rebalance-pods"),
+		).RebalanceWithReport(ctx, client, report)
+		logReport(ctx, report.Last())
+		if errors.Is(err, rebalancer.ErrReplacementNotReady) {
+			log.Error(err, "aborting further deletions", "rs", name, "rebalanced", numRebalanced)
+			return err
+		} else if err != nil {
 			log.Error(err, "failed to rebalance", "rs", name)
 		} else if result {
 			log.Info("Rebalanced", "rs", name)
 			numRebalanced++
+			if opts.cooldown > 0 {
+				if err := kube.MarkRebalanced(ctx, client, r.Replicaset, now()); err != nil {
+					log.Error(err, "failed to record the rebalance time", "rs", name)
+				}
+			}
 		} else {
 			log.V(1).Info("No need to rebalance", "rs", name)
 		}
 	}
 
+	if opts.table != nil {
+		if err := report.WriteTable(opts.table); err != nil {
+			log.Error(err, "failed to write rebalance report")
+			return err
+		}
+	}
+	if opts.reportOnly {
+		log.Info("Rebalance findings", "imbalanced", numImbalanced, "replicasets", len(rs))
+	} else {
+		log.Info("Rebalance result", "rebalanced", numRebalanced, "replicasets", len(rs))
+	}
+	if opts.failOnFindings && numImbalanced > 0 {
+		return output.Findings(numImbalanced, "imbalanced replicasets")
+	}
 	return nil
 }
 
-// getTargetReplicaSets gets target replica sets in a namespace.
-func getTargetReplicaSets(ctx context.Context, client kubernetes.Interface, ns string) ([]*appsv1.ReplicaSet, error) {
-	all, err := client.AppsV1().ReplicaSets(ns).List(ctx, metav1.ListOptions{})
+// verifyTimeout returns the timeout of the replacement verification, which is 0 when disabled.
+func verifyTimeout(opts rebalanceOptions) time.Duration {
+	if !opts.verify {
+		return 0
+	}
+	return opts.verifyTimeout
+}
+
+// busiestNodesToLog is the number of the busiest nodes logged for each replicaset.
+const busiestNodesToLog = 3
+
+// busiestNodes returns up to n nodes with the most pods of the replica set as node=count,
+// in descending order of the count.
+func busiestNodes(state *rebalancer.ReplicaState, n int) []string {
+	counts := state.PodsPerNode()
+	var ret []string
+	for _, node := range kube.SortNodesByPodCount(state.Nodes, counts) {
+		if len(ret) >= n || counts[node.Name] == 0 {
+			break
+		}
+		ret = append(ret, fmt.Sprintf("%s=%d", node.Name, counts[node.Name]))
+	}
+	return ret
+}
+
+// isImbalanced checks if the replica set may need rebalancing, that is its pods per node spread
+// exceeds the tolerance or a node has pods over the per node cap. The spread is measured from the
+// shares of the nodes proportional to the weights if any. Nodes that are not schedulable are counted
+// as well, which only widens the spread. It is always true when pressured nodes are preferred.
+func isImbalanced(state *rebalancer.ReplicaState, opts rebalanceOptions, weights map[string]float64) bool {
+	if opts.preferPressuredNodes || state.WeightedPodSpread(weights) > float64(opts.tolerance) {
+		return true
+	}
+	if opts.maxPerNode > 0 {
+		for _, c := range state.PodsPerNode() {
+			if c > opts.maxPerNode {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// inCooldown checks if the replica set was rebalanced within the cooldown.
+func inCooldown(rs *appsv1.ReplicaSet, cooldown time.Duration) bool {
+	if cooldown <= 0 {
+		return false
+	}
+	last, ok := kube.GetLastRebalanced(rs)
+	return ok && now().Sub(last) < cooldown
+}
+
+// selectCandidates picks up to limit replica states round-robin across their
+// namespaces and owners so that later owners are not starved by the list order.
+func selectCandidates(rs []*rebalancer.ReplicaState, limit int) []*rebalancer.ReplicaState {
+	return generics.RoundRobin(rs, limit, func(s *rebalancer.ReplicaState) string {
+		owner := s.Replicaset.Name
+		if ref := metav1.GetControllerOf(s.Replicaset); ref != nil {
+			owner = ref.Kind + "/" + ref.Name
+		}
+		return s.Replicaset.Namespace + "/" + owner
+	})
+}
+
+// logReport logs the rebalance decision for a replica set as structured fields.
+func logReport(ctx context.Context, rep *rebalancer.ReplicaSetReport) {
+	if rep == nil {
+		return
+	}
+	log := logger.FromContext(ctx)
+	if len(rep.Deleted) == 0 {
+		log = log.V(1)
+	}
+	log.Info("Rebalance report", "rs", rep.Name, "namespace", rep.Namespace,
+		"before", rep.Before, "after", rep.After, "deleted", rep.Deleted,
+		"beforeCV", fmt.Sprintf("%.3f", rep.BeforeCV), "afterCV", fmt.Sprintf("%.3f", rep.AfterCV))
+}
+
+// getTargetReplicaSets gets target replica sets in a namespace that match the label selector.
+// An empty selector matches every replica set.
+func getTargetReplicaSets(ctx context.Context, client kubernetes.Interface, ns, selector string) ([]*appsv1.ReplicaSet, error) {
+	all, err := client.AppsV1().ReplicaSets(ns).List(ctx, metav1.ListOptions{LabelSelector: selector})
 	if err != nil {
 		return nil, fmt.Errorf("failed to list replicaset: %w", err)
 	}
@@ -135,39 +588,46 @@ func getTargetReplicaSets(ctx context.Context, client kubernetes.Interface, ns s
 	return replicas, nil
 }
 
-// getCandidatePods gets pod candidate.
-func getCandidatePods(ctx context.Context, client kubernetes.Interface, ns string, nodes []*v1.Node, replicas []*appsv1.ReplicaSet) ([]*rebalancer.ReplicaState, error) {
-	nodeMap := make(map[string]*v1.Node)
-	var stats []*rebalancer.ReplicaState
-	rsMap := make(map[types.UID]*rebalancer.ReplicaState)
-
-	for _, n := range nodes {
-		nodeMap[n.Name] = n
+// isCountable checks if a pod is counted in the distribution of its replicaset.
+// Running but not ready pods are counted only when includeNotReady is true.
+// Terminating pods are never counted.
+func isCountable(po v1.Pod, includeNotReady bool) bool {
+	if kube.IsPodTerminating(&po) {
+		return false
 	}
+	if includeNotReady && po.Status.Phase == v1.PodRunning {
+		return true
+	}
+	return kube.IsPodReadyRunning(po)
+}
 
-	pods, err := client.CoreV1().Pods(ns).List(ctx, metav1.ListOptions{})
+// getCandidatePods gets pod candidate.
+func getCandidatePods(ctx context.Context, client kubernetes.Interface, nodes []*v1.Node, replicas []*appsv1.ReplicaSet, opts rebalanceOptions) ([]*rebalancer.ReplicaState, error) {
+	ns := opts.namespace
+	var pods []v1.Pod
+	err := concurrent.CallWithTimeout(ctx, opts.callTimeout, func(ctx context.Context) (err error) {
+		pods, err = kube.ListAllPods(ctx, client, ns, metav1.ListOptions{})
+		return err
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to list pod for: %s, error: %w", ns, err)
 	}
-	for _, po := range pods.Items {
-		if !kube.IsPodReadyRunning(po) {
-			continue
-		}
-		for _, rs := range replicas {
-			if !kube.IsPodOwnedBy(rs, &po) {
-				continue
-			}
-			poStat := rebalancer.PodStatus{Pod: po.DeepCopy()}
-			rStat, ok := rsMap[rs.UID]
-			if !ok {
-				rStat = &rebalancer.ReplicaState{Replicaset: rs, Nodes: nodes}
-				rsMap[rs.UID] = rStat
-				stats = append(stats, rStat)
-			}
-
-			rStat.PodStatus = append(rStat.PodStatus, &poStat)
-			break
+	selected := func(v1.Pod) bool { return true }
+	if opts.nodeSelector != "" {
+		names := generics.MakItemMap(nodes, func(n *v1.Node) string { return n.Name })
+		selected = func(po v1.Pod) bool { return names[po.Spec.NodeName] != nil }
+	}
+	counted, ignored := generics.Partition(pods, func(po v1.Pod) bool {
+		return isCountable(po, opts.includeNotReady) && opts.priorityClass.Match(&po) && opts.namespaceScope.Match(&po) &&
+			selected(po)
+	})
+	logger.FromContext(ctx).V(1).Info("counted pods", "counted", len(counted), "ignored", len(ignored))
+	filter := func(ctx context.Context, po *v1.Pod) bool {
+		if ok, reason := kube.CanBeRebalancedReasonWithOpts(po, opts.rebalance); !ok {
+			logger.FromContext(ctx).V(1).Info("skip pod", "pod", fmt.Sprintf("%s/%s", po.Namespace, po.Name), "reason", reason)
+			return false
 		}
+		return true
 	}
-	return stats, nil
+	return rebalancer.BuildReplicaStates(ctx, counted, nodes, replicas, filter), nil
 }
@@ -25,24 +25,53 @@ SOFTWARE.
 package rebalancepods
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"testing"
+	"time"
 
+	"github.com/norseto/k8s-watchdogs/internal/output"
+	"github.com/norseto/k8s-watchdogs/internal/rebalancer"
+	"github.com/norseto/k8s-watchdogs/pkg/kube"
 	"github.com/stretchr/testify/assert"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
 )
 
 func TestRebalancePods_NoNodes(t *testing.T) {
 	ctx := context.Background()
 	client := fake.NewSimpleClientset()
 
-	err := rebalancePods(ctx, client, "default")
+	err := rebalancePods(ctx, client, rebalanceOptions{namespace: "default"})
 	assert.NoError(t, err)
 }
 
+func TestRebalancePods_NodesForbidden(t *testing.T) {
+	ctx := context.Background()
+	client := fake.NewSimpleClientset()
+	client.PrependReactor("list", "nodes", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierrors.NewForbidden(corev1.Resource("nodes"), "", errors.New("rbac"))
+	})
+
+	assert.NoError(t, rebalancePods(ctx, client, rebalanceOptions{namespace: "default"}))
+	for _, action := range client.Actions() {
+		assert.Equal(t, "nodes", action.GetResource().Resource, "nothing but nodes should be touched")
+	}
+
+	client.PrependReactor("list", "nodes", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierrors.NewInternalError(errors.New("etcd"))
+	})
+	assert.Error(t, rebalancePods(ctx, client, rebalanceOptions{namespace: "default"}))
+}
+
 func TestRebalancePods_NoReplicaSets(t *testing.T) {
 	ctx := context.Background()
 	testNode := &corev1.Node{
@@ -52,7 +81,7 @@ func TestRebalancePods_NoReplicaSets(t *testing.T) {
 	}
 	client := fake.NewSimpleClientset(testNode)
 
-	err := rebalancePods(ctx, client, "default")
+	err := rebalancePods(ctx, client, rebalanceOptions{namespace: "default"})
 	assert.NoError(t, err)
 }
 
@@ -117,7 +146,7 @@ func TestRebalancePods_ReplicaSetUnderRollingUpdate(t *testing.T) {
 	}
 	client := fake.NewSimpleClientset(testNode, testRS, testPod)
 
-	err := rebalancePods(ctx, client, "default")
+	err := rebalancePods(ctx, client, rebalanceOptions{namespace: "default"})
 	assert.NoError(t, err)
 }
 
@@ -149,7 +178,7 @@ func TestRebalancePods_PodNotReadyRunning(t *testing.T) {
 	}
 	client := fake.NewSimpleClientset(testNode, testRS, testPod)
 
-	err := rebalancePods(ctx, client, "default")
+	err := rebalancePods(ctx, client, rebalanceOptions{namespace: "default"})
 	assert.NoError(t, err)
 }
 
@@ -191,6 +220,430 @@ func TestRebalancePods_PodNotOwnedByReplicaSet(t *testing.T) {
 	}
 	client := fake.NewSimpleClientset(testNode, testRS, testPod)
 
-	err := rebalancePods(ctx, client, "default")
+	err := rebalancePods(ctx, client, rebalanceOptions{namespace: "default"})
+	assert.NoError(t, err)
+}
+
+func testReplicaSet(name string, replicas int32) *appsv1.ReplicaSet {
+	return &appsv1.ReplicaSet{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", UID: types.UID(name)},
+		Spec:       appsv1.ReplicaSetSpec{Replicas: &replicas},
+		Status:     appsv1.ReplicaSetStatus{Replicas: replicas},
+	}
+}
+
+func testNode(name string) *corev1.Node {
+	res := corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1"), corev1.ResourceMemory: resource.MustParse("1Gi")}
+	return &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: name},
+		Status: corev1.NodeStatus{Capacity: res, Allocatable: res}}
+}
+
+func testPod(name, node string, rs *appsv1.ReplicaSet, opt ...func(p *corev1.Pod)) *corev1.Pod {
+	p := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            name,
+			Namespace:       "default",
+			OwnerReferences: []metav1.OwnerReference{{Kind: "ReplicaSet", Name: rs.Name, UID: rs.UID}},
+		},
+		Spec: corev1.PodSpec{NodeName: node},
+		Status: corev1.PodStatus{
+			Phase:             corev1.PodRunning,
+			ContainerStatuses: []corev1.ContainerStatus{{Ready: true}},
+		},
+	}
+	for _, o := range opt {
+		o(p)
+	}
+	return p
+}
+
+func TestGetTargetReplicaSets_Selector(t *testing.T) {
+	web := testReplicaSet("web", 2)
+	web.Labels = map[string]string{"app": "web"}
+	api := testReplicaSet("api", 2)
+	api.Labels = map[string]string{"app": "api"}
+	client := fake.NewSimpleClientset(web, api)
+
+	all, err := getTargetReplicaSets(context.Background(), client, "default", "")
+	assert.NoError(t, err)
+	assert.Len(t, all, 2)
+
+	selected, err := getTargetReplicaSets(context.Background(), client, "default", "app=web")
+	assert.NoError(t, err)
+	if assert.Len(t, selected, 1) {
+		assert.Equal(t, "web", selected[0].Name)
+	}
+}
+
+func TestGetCandidatePods_PriorityClass(t *testing.T) {
+	ctx := context.Background()
+	rs := testReplicaSet("test-rs", 3)
+	nodes := []*corev1.Node{{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}}}
+	client := fake.NewSimpleClientset(
+		testPod("pod-1", "node-1", rs),
+		testPod("pod-2", "node-1", rs, func(p *corev1.Pod) { p.Spec.PriorityClassName = "critical" }),
+		testPod("pod-3", "node-1", rs, func(p *corev1.Pod) { p.Spec.PriorityClassName = "normal" }),
+	)
+
+	opts := rebalanceOptions{namespace: "default",
+		priorityClass: kube.PriorityClassFilter{Exclude: []string{"critical"}}}
+	states, err := getCandidatePods(ctx, client, nodes, []*appsv1.ReplicaSet{rs}, opts)
 	assert.NoError(t, err)
+	if assert.Len(t, states, 1) {
+		assert.Len(t, states[0].PodStatus, 2)
+	}
+
+	opts.priorityClass = kube.PriorityClassFilter{Include: []string{"normal"}}
+	states, err = getCandidatePods(ctx, client, nodes, []*appsv1.ReplicaSet{rs}, opts)
+	assert.NoError(t, err)
+	if assert.Len(t, states, 1) && assert.Len(t, states[0].PodStatus, 1) {
+		assert.Equal(t, "pod-3", states[0].PodStatus[0].Pod.Name)
+	}
+}
+
+func TestGetCandidatePods_NodeSelector(t *testing.T) {
+	ctx := context.Background()
+	rs := testReplicaSet("test-rs", 3)
+	nodes := []*corev1.Node{{ObjectMeta: metav1.ObjectMeta{Name: "worker-1"}}}
+	client := fake.NewSimpleClientset(
+		testPod("pod-1", "worker-1", rs),
+		testPod("pod-2", "worker-1", rs),
+		testPod("pod-3", "infra-1", rs),
+	)
+
+	opts := rebalanceOptions{namespace: "default", nodeSelector: "role=worker"}
+	states, err := getCandidatePods(ctx, client, nodes, []*appsv1.ReplicaSet{rs}, opts)
+	assert.NoError(t, err)
+	if assert.Len(t, states, 1) {
+		assert.Len(t, states[0].PodStatus, 2)
+		for _, s := range states[0].PodStatus {
+			assert.Equal(t, "worker-1", s.Pod.Spec.NodeName)
+		}
+	}
+}
+
+func TestRebalancePods_NodeSelector(t *testing.T) {
+	ctx := context.Background()
+	client := fake.NewSimpleClientset(
+		&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "worker-1", Labels: map[string]string{"role": "worker"}}},
+		&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "infra-1", Labels: map[string]string{"role": "infra"}}},
+	)
+
+	assert.NoError(t, rebalancePods(ctx, client, rebalanceOptions{namespace: "default", nodeSelector: "role=worker"}))
+	for _, action := range client.Actions() {
+		if list, ok := action.(k8stesting.ListAction); ok && action.GetResource().Resource == "nodes" {
+			assert.Equal(t, "role=worker", list.GetListRestrictions().Labels.String())
+		}
+	}
+}
+
+func TestSelectCandidates(t *testing.T) {
+	ownedBy := func(ns, name, deploy string) *rebalancer.ReplicaState {
+		rs := testReplicaSet(name, 2)
+		rs.Namespace = ns
+		isController := true
+		rs.OwnerReferences = []metav1.OwnerReference{{Kind: "Deployment", Name: deploy, Controller: &isController}}
+		return &rebalancer.ReplicaState{Replicaset: rs}
+	}
+	rs := []*rebalancer.ReplicaState{
+		ownedBy("a", "web-1", "web"),
+		ownedBy("a", "web-2", "web"),
+		ownedBy("a", "web-3", "web"),
+		ownedBy("a", "api-1", "api"),
+		ownedBy("b", "web-1", "web"),
+		ownedBy("c", "db-1", "db"),
+	}
+
+	selected := selectCandidates(rs, 4)
+	names := make([]string, 0, len(selected))
+	for _, s := range selected {
+		names = append(names, s.Replicaset.Namespace+"/"+s.Replicaset.Name)
+	}
+	assert.Equal(t, []string{"a/api-1", "a/web-1", "b/web-1", "c/db-1"}, names)
+	assert.Equal(t, selected, selectCandidates(rs, 4))
+	assert.Len(t, selectCandidates(rs, 0), len(rs))
+}
+
+func TestRebalancePods_IncludeNotReady(t *testing.T) {
+	notReady := func(p *corev1.Pod) { p.Status.ContainerStatuses[0].Ready = false }
+	newClient := func() (*fake.Clientset, *appsv1.ReplicaSet) {
+		rs := testReplicaSet("test-rs", 4)
+		client := fake.NewSimpleClientset(
+			testNode("node-1"),
+			testNode("node-2"),
+			rs,
+			testPod("pod-1", "node-1", rs, notReady),
+			testPod("pod-2", "node-1", rs),
+			testPod("pod-3", "node-1", rs),
+			testPod("pod-4", "node-2", rs),
+		)
+		return client, rs
+	}
+	ctx := context.Background()
+
+	// Without not ready pods, node-1 is within the limit.
+	client, _ := newClient()
+	err := rebalancePods(ctx, client, rebalanceOptions{namespace: "default"})
+	assert.NoError(t, err)
+	pods, _ := client.CoreV1().Pods("default").List(ctx, metav1.ListOptions{})
+	assert.Len(t, pods.Items, 4)
+
+	// With not ready pods, node-1 is the hot node and a ready pod is deleted.
+	client, _ = newClient()
+	err = rebalancePods(ctx, client, rebalanceOptions{namespace: "default", includeNotReady: true})
+	assert.NoError(t, err)
+	_, err = client.CoreV1().Pods("default").Get(ctx, "pod-1", metav1.GetOptions{})
+	assert.NoError(t, err)
+	pods, _ = client.CoreV1().Pods("default").List(ctx, metav1.ListOptions{})
+	assert.Len(t, pods.Items, 3)
+
+	// The decision is written as a table.
+	client, _ = newClient()
+	buf := &bytes.Buffer{}
+	err = rebalancePods(ctx, client, rebalanceOptions{namespace: "default", includeNotReady: true, table: buf})
+	assert.NoError(t, err)
+	assert.Contains(t, buf.String(), "REPLICASET")
+	assert.Regexp(t, `default\s+test-rs\s+node-1=3,node-2=1\s+node-1=2,node-2=1\s+deleted pod-[23]`, buf.String())
+}
+
+func TestRebalancePods_Cooldown(t *testing.T) {
+	fixed := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	now = func() time.Time { return fixed }
+	defer func() { now = time.Now }()
+
+	testNode := func(name string) *corev1.Node {
+		res := corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1"), corev1.ResourceMemory: resource.MustParse("1Gi")}
+		return &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: name},
+			Status: corev1.NodeStatus{Capacity: res, Allocatable: res}}
+	}
+	newClient := func(lastRebalanced string) *fake.Clientset {
+		rs := testReplicaSet("test-rs", 4)
+		if lastRebalanced != "" {
+			rs.Annotations = map[string]string{kube.LastRebalancedAnnotation: lastRebalanced}
+		}
+		return fake.NewSimpleClientset(
+			testNode("node-1"),
+			testNode("node-2"),
+			rs,
+			testPod("pod-1", "node-1", rs),
+			testPod("pod-2", "node-1", rs),
+			testPod("pod-3", "node-1", rs),
+			testPod("pod-4", "node-1", rs),
+		)
+	}
+	ctx := context.Background()
+	countPods := func(client *fake.Clientset) int {
+		pods, _ := client.CoreV1().Pods("default").List(ctx, metav1.ListOptions{})
+		return len(pods.Items)
+	}
+	annotation := func(client *fake.Clientset) string {
+		rs, _ := client.AppsV1().ReplicaSets("default").Get(ctx, "test-rs", metav1.GetOptions{})
+		return rs.Annotations[kube.LastRebalancedAnnotation]
+	}
+
+	// Without a cooldown, the replicaset is rebalanced and no annotation is written.
+	client := newClient(fixed.Add(-time.Minute).Format(time.RFC3339))
+	assert.NoError(t, rebalancePods(ctx, client, rebalanceOptions{namespace: "default"}))
+	assert.Less(t, countPods(client), 4)
+	assert.Equal(t, fixed.Add(-time.Minute).Format(time.RFC3339), annotation(client))
+
+	// Within the cooldown, the replicaset is left untouched.
+	client = newClient(fixed.Add(-time.Minute).Format(time.RFC3339))
+	assert.NoError(t, rebalancePods(ctx, client, rebalanceOptions{namespace: "default", cooldown: time.Hour}))
+	assert.Equal(t, 4, countPods(client))
+
+	// After the cooldown, the replicaset is rebalanced and the time is recorded.
+	client = newClient(fixed.Add(-2 * time.Hour).Format(time.RFC3339))
+	assert.NoError(t, rebalancePods(ctx, client, rebalanceOptions{namespace: "default", cooldown: time.Hour}))
+	assert.Less(t, countPods(client), 4)
+	assert.Equal(t, "2024-01-02T03:04:05Z", annotation(client))
+}
+
+func TestIsImbalanced(t *testing.T) {
+	state := &rebalancer.ReplicaState{
+		Nodes: []*corev1.Node{
+			{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}},
+			{ObjectMeta: metav1.ObjectMeta{Name: "node-2"}},
+		},
+	}
+	for _, n := range []string{"node-1", "node-1", "node-1", "node-2"} {
+		po := &corev1.Pod{Spec: corev1.PodSpec{NodeName: n}}
+		state.PodStatus = append(state.PodStatus, &rebalancer.PodStatus{Pod: po})
+	}
+
+	assert.True(t, isImbalanced(state, rebalanceOptions{tolerance: 1}, nil))
+	assert.False(t, isImbalanced(state, rebalanceOptions{tolerance: 2}, nil))
+	assert.True(t, isImbalanced(state, rebalanceOptions{tolerance: 2, maxPerNode: 2}, nil))
+	assert.True(t, isImbalanced(state, rebalanceOptions{tolerance: 2, preferPressuredNodes: true}, nil))
+
+	// 3 and 1 pods are the exact shares of the nodes weighted 3 and 1.
+	weights := map[string]float64{"node-1": 3, "node-2": 1}
+	assert.False(t, isImbalanced(state, rebalanceOptions{tolerance: 0}, weights))
+	// Even counts put node-2 over its share of a quarter.
+	state.PodStatus[0].Pod.Spec.NodeName = "node-2"
+	assert.False(t, isImbalanced(state, rebalanceOptions{tolerance: 0}, nil))
+	assert.True(t, isImbalanced(state, rebalanceOptions{tolerance: 1}, weights))
+}
+
+func TestBusiestNodes(t *testing.T) {
+	state := &rebalancer.ReplicaState{
+		Nodes: []*corev1.Node{testNode("node-1"), testNode("node-2"), testNode("node-3")},
+	}
+	for _, n := range []string{"node-2", "node-1", "node-2", "node-1", "node-2"} {
+		state.PodStatus = append(state.PodStatus, &rebalancer.PodStatus{Pod: &corev1.Pod{Spec: corev1.PodSpec{NodeName: n}}})
+	}
+	assert.Equal(t, []string{"node-2=3", "node-1=2"}, busiestNodes(state, 3))
+	assert.Equal(t, []string{"node-2=3"}, busiestNodes(state, 1))
+}
+
+func TestRebalancePods_OnlyIfImbalanced(t *testing.T) {
+	ctx := context.Background()
+	newClient := func() *fake.Clientset {
+		rs := testReplicaSet("test-rs", 4)
+		return fake.NewSimpleClientset(
+			testNode("node-1"),
+			testNode("node-2"),
+			rs,
+			testPod("pod-1", "node-1", rs),
+			testPod("pod-2", "node-1", rs),
+			testPod("pod-3", "node-1", rs),
+			testPod("pod-4", "node-2", rs),
+		)
+	}
+	deletes := func(client *fake.Clientset) int {
+		n := 0
+		for _, a := range client.Actions() {
+			if a.GetVerb() == "delete" {
+				n++
+			}
+		}
+		return n
+	}
+
+	// The spread of 2 is within the tolerance and the nodes are never inspected in detail.
+	client := newClient()
+	assert.NoError(t, rebalancePods(ctx, client,
+		rebalanceOptions{namespace: "default", tolerance: 2, onlyIfImbalanced: true}))
+	assert.Zero(t, deletes(client))
+
+	// Imbalanced replicasets are rebalanced as without the flag.
+	zero := float32(0)
+	client = newClient()
+	assert.NoError(t, rebalancePods(ctx, client,
+		rebalanceOptions{namespace: "default", tolerance: 1, threshold: &zero, onlyIfImbalanced: true}))
+	assert.Equal(t, 1, deletes(client))
+}
+
+func TestRebalancePods_ReportOnly(t *testing.T) {
+	ctx := context.Background()
+	newClient := func() *fake.Clientset {
+		rs := testReplicaSet("test-rs", 4)
+		return fake.NewSimpleClientset(
+			testNode("node-1"),
+			testNode("node-2"),
+			rs,
+			testPod("pod-1", "node-1", rs),
+			testPod("pod-2", "node-1", rs),
+			testPod("pod-3", "node-1", rs),
+			testPod("pod-4", "node-1", rs),
+		)
+	}
+	countPods := func(client *fake.Clientset) int {
+		pods, _ := client.CoreV1().Pods("default").List(ctx, metav1.ListOptions{})
+		return len(pods.Items)
+	}
+
+	client := newClient()
+	assert.NoError(t, rebalancePods(ctx, client, rebalanceOptions{namespace: "default", tolerance: 1, reportOnly: true}))
+	assert.Equal(t, 4, countPods(client))
+
+	err := rebalancePods(ctx, client,
+		rebalanceOptions{namespace: "default", tolerance: 1, reportOnly: true, failOnFindings: true})
+	assert.ErrorIs(t, err, output.ErrFindings)
+	assert.Equal(t, 4, countPods(client))
+
+	// Within the tolerance, there is nothing to report.
+	assert.NoError(t, rebalancePods(ctx, client,
+		rebalanceOptions{namespace: "default", tolerance: 4, reportOnly: true, failOnFindings: true}))
+}
+
+func TestParseDefaultRequest(t *testing.T) {
+	got, err := parseDefaultRequest(nil)
+	assert.NoError(t, err)
+	assert.Nil(t, got)
+
+	got, err = parseDefaultRequest(map[string]string{"cpu": "100m", "memory": "128Mi"})
+	assert.NoError(t, err)
+	assert.Equal(t, "100m", got.Cpu().String())
+	assert.Equal(t, "128Mi", got.Memory().String())
+
+	for _, values := range []map[string]string{
+		{"nvidia.com/gpu": "1"},
+		{"cpu": "lots"},
+		{"memory": "-1Gi"},
+	} {
+		_, err := parseDefaultRequest(values)
+		assert.Error(t, err, values)
+	}
+}
+
+func TestParseWeights(t *testing.T) {
+	got, err := parseWeights(nil)
+	assert.NoError(t, err)
+	assert.Nil(t, got)
+
+	got, err = parseWeights(map[string]string{"m5.xlarge": "4", "m5.large": "1.5"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]float64{"m5.xlarge": 4, "m5.large": 1.5}, got)
+
+	for _, values := range []map[string]string{
+		{"m5.large": "big"},
+		{"m5.large": "0"},
+		{"m5.large": "-1"},
+		{"m5.large": "NaN"},
+	} {
+		_, err := parseWeights(values)
+		assert.Error(t, err, values)
+	}
+}
+
+func TestNodeWeights(t *testing.T) {
+	const label = "node.kubernetes.io/instance-type"
+	large := testNode("node-1")
+	large.Labels = map[string]string{label: "m5.xlarge"}
+	unknown := testNode("node-2")
+	unknown.Labels = map[string]string{label: "m5.metal"}
+	unlabeled := testNode("node-3")
+	unlabeled.Status.Allocatable[corev1.ResourceCPU] = resource.MustParse("2500m")
+
+	got, err := nodeWeights([]*corev1.Node{large, nil, unknown, unlabeled}, label, map[string]float64{"m5.xlarge": 4})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]float64{"node-1": 4, "node-2": 1, "node-3": 2.5}, got)
+
+	_, err = nodeWeights([]*corev1.Node{{ObjectMeta: metav1.ObjectMeta{Name: "node-4"}}}, label, nil)
+	assert.Error(t, err)
+}
+
+func TestRebalancePods_Verify(t *testing.T) {
+	ctx := context.Background()
+	rs := testReplicaSet("test-rs", 4)
+	client := fake.NewSimpleClientset(
+		testNode("node-1"),
+		testNode("node-2"),
+		rs,
+		testPod("pod-1", "node-1", rs),
+		testPod("pod-2", "node-1", rs),
+		testPod("pod-3", "node-1", rs),
+		testPod("pod-4", "node-1", rs),
+	)
+
+	// No replacement comes up in the fake cluster, so the run is aborted.
+	err := rebalancePods(ctx, client,
+		rebalanceOptions{namespace: "default", verify: true, verifyTimeout: 20 * time.Millisecond})
+	assert.ErrorIs(t, err, rebalancer.ErrReplacementNotReady)
+	pods, _ := client.CoreV1().Pods("default").List(ctx, metav1.ListOptions{})
+	assert.Len(t, pods.Items, 3)
+
+	assert.Zero(t, verifyTimeout(rebalanceOptions{verifyTimeout: time.Minute}))
+	assert.Equal(t, time.Minute, verifyTimeout(rebalanceOptions{verify: true, verifyTimeout: time.Minute}))
 }
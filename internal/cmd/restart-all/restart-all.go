@@ -0,0 +1,152 @@
+/*
+MIT License
+
+Copyright (c) 2024 Norihiro Seto
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package restartall
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/norseto/k8s-watchdogs/internal/options"
+	"github.com/norseto/k8s-watchdogs/internal/preflight"
+	"github.com/norseto/k8s-watchdogs/internal/validation"
+	"github.com/norseto/k8s-watchdogs/pkg/kube"
+	"github.com/norseto/k8s-watchdogs/pkg/kube/client"
+	"github.com/norseto/k8s-watchdogs/pkg/logger"
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// errNamespaceRequired is returned when no namespace is given,
+// so that a run never restarts the workloads of every namespace.
+var errNamespaceRequired = errors.New("restart-all requires --namespace")
+
+// NewCommand returns a new Cobra command for restarting all workloads in a namespace.
+func NewCommand() *cobra.Command {
+	var kinds []string
+	var reason string
+
+	opts := &options.Options{}
+	cmd := &cobra.Command{
+		Use:   "restart-all",
+		Short: "Restart all workloads in a namespace",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			if opts.Namespace() == metav1.NamespaceAll {
+				logger.FromContext(ctx).Error(errNamespaceRequired, "invalid namespace")
+				return errNamespaceRequired
+			}
+			if err := validation.ValidateReason(reason); err != nil {
+				logger.FromContext(ctx).Error(err, "invalid reason")
+				return err
+			}
+			clnt, err := client.NewClientset(client.FromContext(ctx))
+			if err != nil {
+				logger.FromContext(ctx).Error(err, "failed to create client")
+				return err
+			}
+			if done, err := preflight.Run(ctx, clnt); done {
+				return err
+			}
+			limit, err := opts.MaxTargets()
+			if err != nil {
+				logger.FromContext(ctx).Error(err, "invalid max targets")
+				return err
+			}
+			return restartAll(ctx, clnt, opts.Namespace(), kinds, limit, kube.WithRestartReason(reason))
+		},
+	}
+	opts.BindCommonFlags(cmd)
+	opts.BindMaxTargetsFlags(cmd)
+	cmd.Flags().StringSliceVar(&kinds, "kinds", kube.RestartableKinds,
+		"Kinds of workloads to restart")
+	cmd.Flags().StringVar(&reason, "reason", "",
+		"Reason of the restart recorded in the "+kube.RestartReasonAnnotation+" annotation")
+
+	return cmd
+}
+
+// +kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;list;patch
+// +kubebuilder:rbac:groups=apps,resources=statefulsets,verbs=get;list;patch
+// +kubebuilder:rbac:groups=apps,resources=daemonsets,verbs=get;list;patch
+
+// restartAll restarts the workloads of the kinds in the namespace, which must not be empty.
+// It restarts nothing when there are more than limit workloads.
+func restartAll(ctx context.Context, client kubernetes.Interface, namespace string, kinds []string, limit int, opts ...kube.RestartOption) error {
+	log := logger.FromContext(ctx)
+
+	if namespace == metav1.NamespaceAll {
+		log.Error(errNamespaceRequired, "invalid namespace")
+		return errNamespaceRequired
+	}
+	count, err := countWorkloads(ctx, client, namespace, kinds)
+	if err != nil {
+		log.Error(err, "failed to count workloads", "namespace", namespace, "kinds", kinds)
+		return err
+	}
+	if count > limit {
+		err := fmt.Errorf("%d workloads match, must be at most %d", count, limit)
+		log.Error(err, "too many targets", "namespace", namespace, "kinds", kinds)
+		return err
+	}
+	if err := kube.RestartResourcesInNamespace(ctx, client, namespace, kinds, opts...); err != nil {
+		log.Error(err, "failed to restart workloads", "namespace", namespace, "kinds", kinds)
+		return err
+	}
+	log.V(1).Info("restarted", "namespace", namespace, "kinds", kinds)
+	return nil
+}
+
+// countWorkloads returns the number of the workloads of the kinds in the namespace.
+func countWorkloads(ctx context.Context, client kubernetes.Interface, namespace string, kinds []string) (int, error) {
+	count := 0
+	for _, kind := range kinds {
+		switch strings.ToLower(kind) {
+		case kube.RestartKindDeployment:
+			list, err := client.AppsV1().Deployments(namespace).List(ctx, metav1.ListOptions{})
+			if err != nil {
+				return 0, fmt.Errorf("failed to list deployments: %w", err)
+			}
+			count += len(list.Items)
+		case kube.RestartKindStatefulSet:
+			list, err := client.AppsV1().StatefulSets(namespace).List(ctx, metav1.ListOptions{})
+			if err != nil {
+				return 0, fmt.Errorf("failed to list statefulsets: %w", err)
+			}
+			count += len(list.Items)
+		case kube.RestartKindDaemonSet:
+			list, err := client.AppsV1().DaemonSets(namespace).List(ctx, metav1.ListOptions{})
+			if err != nil {
+				return 0, fmt.Errorf("failed to list daemonsets: %w", err)
+			}
+			count += len(list.Items)
+		default:
+			return 0, fmt.Errorf("unsupported kind %q, must be one of %v", kind, kube.RestartableKinds)
+		}
+	}
+	return count, nil
+}
@@ -0,0 +1,115 @@
+/*
+MIT License
+
+Copyright (c) 2024 Norihiro Seto
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package restartall
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/norseto/k8s-watchdogs/internal/options"
+	"github.com/norseto/k8s-watchdogs/pkg/kube"
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestNewCommand(t *testing.T) {
+	cmd := NewCommand()
+	assert.Equal(t, "restart-all", cmd.Use)
+
+	kinds, err := cmd.Flags().GetStringSlice("kinds")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"deployment", "statefulset", "daemonset"}, kinds)
+}
+
+func TestRestartAll(t *testing.T) {
+	ctx := context.Background()
+	meta := metav1.ObjectMeta{Name: "test", Namespace: "default"}
+	client := fake.NewSimpleClientset(
+		&appsv1.Deployment{ObjectMeta: meta},
+		&appsv1.StatefulSet{ObjectMeta: meta},
+		&appsv1.DaemonSet{ObjectMeta: meta},
+	)
+
+	err := restartAll(ctx, client, "default", []string{"deployment", "daemonset"}, options.DefaultMaxTargets)
+	assert.NoError(t, err)
+
+	dep, _ := client.AppsV1().Deployments("default").Get(ctx, "test", metav1.GetOptions{})
+	assert.Contains(t, dep.Spec.Template.Annotations, "kubectl.kubernetes.io/restartedAt")
+	sts, _ := client.AppsV1().StatefulSets("default").Get(ctx, "test", metav1.GetOptions{})
+	assert.NotContains(t, sts.Spec.Template.Annotations, "kubectl.kubernetes.io/restartedAt")
+	ds, _ := client.AppsV1().DaemonSets("default").Get(ctx, "test", metav1.GetOptions{})
+	assert.Contains(t, ds.Spec.Template.Annotations, "kubectl.kubernetes.io/restartedAt")
+
+	err = restartAll(ctx, client, "default", []string{"unknown"}, options.DefaultMaxTargets)
+	assert.Error(t, err)
+}
+
+func TestRestartAll_Guards(t *testing.T) {
+	ctx := context.Background()
+	restarted := func(client *fake.Clientset) int {
+		count := 0
+		for _, ns := range []string{"default", "kube-system"} {
+			list, err := client.AppsV1().Deployments(ns).List(ctx, metav1.ListOptions{})
+			assert.NoError(t, err)
+			for _, d := range list.Items {
+				if _, ok := d.Spec.Template.Annotations[kube.DefaultRestartAnnotationKey]; ok {
+					count++
+				}
+			}
+		}
+		return count
+	}
+	newClient := func() *fake.Clientset {
+		return fake.NewSimpleClientset(
+			&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"}},
+			&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "default"}},
+			&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "coredns", Namespace: "kube-system"}},
+		)
+	}
+
+	// A run never spans every namespace.
+	client := newClient()
+	assert.ErrorContains(t, restartAll(ctx, client, "", kube.RestartableKinds, options.DefaultMaxTargets), "--namespace")
+	assert.Zero(t, restarted(client))
+
+	// More workloads than the limit restart nothing.
+	client = newClient()
+	assert.ErrorContains(t, restartAll(ctx, client, "default", kube.RestartableKinds, 1), "at most 1")
+	assert.Zero(t, restarted(client))
+
+	assert.NoError(t, restartAll(ctx, client, "default", kube.RestartableKinds, 2))
+	assert.Equal(t, 2, restarted(client))
+}
+
+func TestNewCommand_NamespaceRequired(t *testing.T) {
+	cmd := NewCommand()
+	cmd.SetArgs([]string{})
+	cmd.SetOut(io.Discard)
+	cmd.SetErr(io.Discard)
+	assert.ErrorContains(t, cmd.Execute(), "requires --namespace")
+}
@@ -26,46 +26,176 @@ package restartdeploy
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 
 	"github.com/norseto/k8s-watchdogs/internal/options"
+	"github.com/norseto/k8s-watchdogs/internal/preflight"
+	"github.com/norseto/k8s-watchdogs/internal/validation"
 	"github.com/norseto/k8s-watchdogs/pkg/kube"
 	"github.com/norseto/k8s-watchdogs/pkg/kube/client"
 	"github.com/norseto/k8s-watchdogs/pkg/logger"
 	"github.com/spf13/cobra"
+	appsv1 "k8s.io/api/apps/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/client-go/kubernetes"
 )
 
 // NewCommand returns a new Cobra command for re-balancing pods.
 func NewCommand() *cobra.Command {
+	var reason string
+	var annotationKey string
+	var dryRun bool
+	var fieldManager string
+	var all bool
+	var selector string
+	var maxUnavailable string
+	var listOnly bool
+
 	opts := &options.Options{}
 	cmd := &cobra.Command{
 		Use:   "restart-deploy",
 		Short: "Restart deployment",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			if len(args) < 1 {
+			ctx := cmd.Context()
+			args, err := opts.Targets(args)
+			if err != nil {
+				logger.FromContext(ctx).Error(err, "invalid targets")
+				return err
+			}
+			if len(args) < 1 && !all && selector == "" {
 				_ = cmd.Usage()
 				return nil
 			}
-			ctx := cmd.Context()
+			if err := validateTargets(args, all, selector, opts.Namespace()); err != nil {
+				logger.FromContext(ctx).Error(err, "invalid targets")
+				return err
+			}
+			unavailable, err := parseMaxUnavailable(maxUnavailable)
+			if err == nil && unavailable != nil && len(args) > 0 {
+				err = errors.New("--max-unavailable can only be used with --all or --selector")
+			}
+			if err != nil {
+				logger.FromContext(ctx).Error(err, "invalid max unavailable")
+				return err
+			}
+			if err := validation.ValidateReason(reason); err != nil {
+				logger.FromContext(ctx).Error(err, "invalid reason")
+				return err
+			}
+			if err := validation.ValidateAnnotationKey(annotationKey); err != nil {
+				logger.FromContext(ctx).Error(err, "invalid annotation key")
+				return err
+			}
+			if err := validation.ValidateFieldManager(fieldManager); err != nil {
+				logger.FromContext(ctx).Error(err, "invalid field manager")
+				return err
+			}
 			clnt, err := client.NewClientset(client.FromContext(ctx))
 			if err != nil {
 				logger.FromContext(ctx).Error(err, "failed to create client")
 				return err
 			}
-			return restartDeployment(cmd.Context(), clnt, opts.Namespace(), args)
+			if done, err := preflight.Run(ctx, clnt); done {
+				return err
+			}
+			limit, err := opts.MaxTargets()
+			if err != nil {
+				logger.FromContext(ctx).Error(err, "invalid max targets")
+				return err
+			}
+			if listOnly {
+				return listDeployments(ctx, clnt, opts.Namespace(), args, selector, limit, cmd.OutOrStdout())
+			}
+			restartOpts := []kube.RestartOption{
+				kube.WithRestartReason(reason), kube.WithRestartAnnotationKey(annotationKey), kube.WithRestartDryRun(dryRun),
+				kube.WithRestartFieldManager(fieldManager),
+			}
+			if len(args) < 1 {
+				return restartAllDeployments(ctx, clnt, opts.Namespace(), selector, limit, unavailable, restartOpts...)
+			}
+			return restartDeployment(ctx, clnt, opts.Namespace(), args, restartOpts...)
 		},
-		Args: cobra.MinimumNArgs(1),
 	}
 	opts.BindCommonFlags(cmd)
+	opts.BindFromFileFlags(cmd)
+	opts.BindMaxTargetsFlags(cmd)
+	cmd.Flags().StringVar(&reason, "reason", "",
+		"Reason of the restart recorded in the "+kube.RestartReasonAnnotation+" annotation")
+	cmd.Flags().StringVar(&annotationKey, "annotation-key", kube.DefaultRestartAnnotationKey,
+		"Pod template annotation key that records the restart time")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false,
+		"Report the targets to restart and send the patches as server-side dry runs without persisting them")
+	cmd.Flags().StringVar(&fieldManager, "field-manager", "",
+		"Field manager of the restart annotations. When set, the restart is sent as a server-side apply "+
+			"owned by this manager instead of a strategic merge patch")
+	cmd.Flags().BoolVar(&all, "all", false, "Restart every deployment in the namespace. Requires --namespace")
+	cmd.Flags().StringVarP(&selector, "selector", "l", "",
+		"Label selector of the deployments to restart (e.g. app=web). Narrows --all, requires it and cannot be used with names")
+	cmd.Flags().StringVar(&maxUnavailable, "max-unavailable", "",
+		"Maximum number (e.g. 2) or percentage (e.g. 25%) of deployments rolling out at once with --all or --selector. "+
+			"Deployments are restarted in waves, waiting for each wave to complete. Empty restarts all at once")
+	cmd.Flags().BoolVar(&listOnly, "list", false,
+		"Print the namespace/name of the deployments that would be restarted, one per line, and exit without restarting")
 
 	return cmd
 }
 
+// validateTargets checks that the deployments are given either by names or by --all and --selector.
+// --selector only narrows --all, and --all requires a namespace so that a run never restarts
+// the deployments of every namespace.
+func validateTargets(names []string, all bool, selector, namespace string) error {
+	if len(names) > 0 && (all || selector != "") {
+		return errors.New("deployment names cannot be used with --all or --selector")
+	}
+	if selector != "" && !all {
+		return errors.New("--selector can only be used with --all")
+	}
+	if all && namespace == metav1.NamespaceAll {
+		return errors.New("--all requires --namespace")
+	}
+	if _, err := labels.Parse(selector); err != nil {
+		return fmt.Errorf("invalid selector %q: %w", selector, err)
+	}
+	return nil
+}
+
+// parseMaxUnavailable parses the number or percentage of deployments rolling out at once.
+// An empty value returns nil, which restarts every deployment at once.
+func parseMaxUnavailable(value string) (*intstr.IntOrString, error) {
+	if value == "" {
+		return nil, nil
+	}
+	v := intstr.Parse(value)
+	n, err := intstr.GetScaledValueFromIntOrPercent(&v, 100, false)
+	if err != nil {
+		return nil, fmt.Errorf("invalid max unavailable %q: %w", value, err)
+	}
+	if n < 1 {
+		return nil, fmt.Errorf("invalid max unavailable %q: must be positive", value)
+	}
+	return &v, nil
+}
+
+// waveSize returns the number of deployments restarted at once out of total.
+// A percentage is rounded down but at least one deployment is restarted at once.
+func waveSize(maxUnavailable *intstr.IntOrString, total int) int {
+	if maxUnavailable == nil {
+		return max(total, 1)
+	}
+	n, err := intstr.GetScaledValueFromIntOrPercent(maxUnavailable, total, false)
+	if err != nil {
+		return 1
+	}
+	return max(n, 1)
+}
+
 // +kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;list;update
 
-func restartDeployment(ctx context.Context, client kubernetes.Interface, namespace string, targets []string) error {
+func restartDeployment(ctx context.Context, client kubernetes.Interface, namespace string, targets []string, opts ...kube.RestartOption) error {
 	log := logger.FromContext(ctx)
 
 	for _, target := range targets {
@@ -75,13 +205,128 @@ func restartDeployment(ctx context.Context, client kubernetes.Interface, namespa
 				fmt.Sprintf("%s/%s", namespace, target))
 			return err
 		}
+		if _, err := restartTarget(ctx, client, dep, opts...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// restartAllDeployments restarts every deployment in the namespace that matches the label selector.
+// An empty selector matches every deployment. It restarts nothing when more than limit deployments match.
+// With maxUnavailable, the deployments are restarted in waves and the rollouts of a wave must
+// complete before the next wave starts. nil restarts every deployment at once without waiting.
+// It keeps going when a deployment fails to restart and returns all errors joined, but stops
+// when a rollout does not complete.
+func restartAllDeployments(ctx context.Context, client kubernetes.Interface, namespace, selector string, limit int,
+	maxUnavailable *intstr.IntOrString, opts ...kube.RestartOption) error {
+	log := logger.FromContext(ctx)
+
+	list, err := matchingDeployments(ctx, client, namespace, selector, limit)
+	if err != nil {
+		return err
+	}
+	summary := &kube.RestartSummary{}
+	defer summary.Log(ctx, opts...)
+	size := waveSize(maxUnavailable, len(list.Items))
+	wait := maxUnavailable != nil && !kube.IsRestartDryRun(opts...)
+	var errs []error
+	for start := 0; start < len(list.Items); start += size {
+		wave := list.Items[start:min(start+size, len(list.Items))]
+		var rolling []*appsv1.Deployment
+		for i := range wave {
+			restarted, err := restartTarget(ctx, client, &wave[i], opts...)
+			summary.Add(restarted, err)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			if restarted && wait {
+				rolling = append(rolling, &wave[i])
+			}
+		}
+		for _, dep := range rolling {
+			if err := kube.WaitForDeploymentRollout(ctx, client, dep.Namespace, dep.Name, rolloutPollInterval); err != nil {
+				log.Error(err, "failed to wait for rollout", "target", fmt.Sprintf("%s/%s", dep.Namespace, dep.Name))
+				return errors.Join(append(errs, err)...)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// matchingDeployments returns the deployments in the namespace that match the label selector.
+// It returns an error when more than limit deployments match.
+func matchingDeployments(ctx context.Context, client kubernetes.Interface, namespace, selector string,
+	limit int) (*appsv1.DeploymentList, error) {
+	log := logger.FromContext(ctx)
+
+	list, err := client.AppsV1().Deployments(namespace).List(ctx, metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		log.Error(err, "failed to list deployments", "namespace", namespace, "selector", selector)
+		return nil, err
+	}
+	if len(list.Items) > limit {
+		err := fmt.Errorf("%d deployments match, must be at most %d", len(list.Items), limit)
+		log.Error(err, "too many targets", "namespace", namespace, "selector", selector)
+		return nil, err
+	}
+	return list, nil
+}
 
-		err = kube.RestartDeployment(ctx, client, dep)
+// listDeployments writes the namespace/name of the deployments that would be restarted to w,
+// one per line, without restarting them. The deployments are given by names, or by the label
+// selector when no names are given.
+func listDeployments(ctx context.Context, client kubernetes.Interface, namespace string, names []string,
+	selector string, limit int, w io.Writer) error {
+	log := logger.FromContext(ctx)
+
+	var targets []appsv1.Deployment
+	if len(names) < 1 {
+		list, err := matchingDeployments(ctx, client, namespace, selector, limit)
 		if err != nil {
-			log.Error(err, "failed to restart deployment", "target",
-				fmt.Sprintf("%s/%s", namespace, target))
+			return err
+		}
+		targets = list.Items
+	}
+	for _, name := range names {
+		dep, err := client.AppsV1().Deployments(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			log.Error(err, "failed to get deployment", "target", fmt.Sprintf("%s/%s", namespace, name))
+			return err
+		}
+		targets = append(targets, *dep)
+	}
+	for _, dep := range targets {
+		if _, err := fmt.Fprintf(w, "%s/%s\n", dep.Namespace, dep.Name); err != nil {
 			return err
 		}
 	}
 	return nil
 }
+
+// rolloutPollInterval is the interval of polling the rollout status. It is replaced in tests.
+var rolloutPollInterval = kube.DefaultRolloutPollInterval
+
+// restartTarget restarts the deployment and logs the result.
+// It returns false when the deployment was skipped, logging the reason.
+func restartTarget(ctx context.Context, client kubernetes.Interface, dep *appsv1.Deployment, opts ...kube.RestartOption) (bool, error) {
+	log := logger.FromContext(ctx)
+	target := fmt.Sprintf("%s/%s", dep.Namespace, dep.Name)
+
+	restarted, err := kube.RestartDeployment(ctx, client, dep, opts...)
+	if err != nil {
+		log.Error(err, "failed to restart deployment", "target", target)
+		return false, err
+	}
+	if !restarted {
+		log.Info("skipped", "target", target, "reason", kube.SkipAlreadyRestarted)
+		return false, nil
+	}
+	if kube.IsRestartDryRun(opts...) {
+		log.Info("would restart (dry run)", "target", target)
+		return true, nil
+	}
+	log.V(1).Info("restarted", "target", target)
+	return true, nil
+}
@@ -25,13 +25,23 @@ SOFTWARE.
 package restartdeploy
 
 import (
+	"bytes"
 	"context"
+	"errors"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/go-logr/logr/funcr"
+	"github.com/norseto/k8s-watchdogs/pkg/kube"
+	"github.com/norseto/k8s-watchdogs/pkg/logger"
 	"github.com/stretchr/testify/assert"
 	"k8s.io/api/apps/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
 )
 
 // TestNewCommand validates the NewCommand function
@@ -45,6 +55,11 @@ func TestNewCommand(t *testing.T) {
 
 		// Check subcommand
 		assert.Equal(t, "Restart deployment", cmd.Short)
+
+		// Check the restart is a strategic merge patch unless a field manager is given
+		manager, err := cmd.Flags().GetString("field-manager")
+		assert.NoError(t, err)
+		assert.Empty(t, manager)
 	})
 }
 
@@ -76,3 +91,228 @@ func TestRestartDeployment(t *testing.T) {
 		assert.NotNil(t, err)
 	})
 }
+
+func TestRestartDeployment_DryRun(t *testing.T) {
+	ctx := context.Background()
+	client := fake.NewSimpleClientset(&v1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-deployment", Namespace: "default"},
+	})
+	// Like the API server, dry run patches are not persisted.
+	dryRuns := 0
+	client.PrependReactor("patch", "deployments", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if len(action.(k8stesting.PatchActionImpl).GetPatchOptions().DryRun) == 0 {
+			return false, nil, nil
+		}
+		dryRuns++
+		return true, &v1.Deployment{}, nil
+	})
+
+	err := restartDeployment(ctx, client, "default", []string{"test-deployment"}, kube.WithRestartDryRun(true))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, dryRuns)
+
+	dep, err := client.AppsV1().Deployments("default").Get(ctx, "test-deployment", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Empty(t, dep.Spec.Template.Annotations)
+}
+
+func TestValidateTargets(t *testing.T) {
+	assert.NoError(t, validateTargets([]string{"web"}, false, "", ""))
+	assert.NoError(t, validateTargets(nil, true, "", "default"))
+	assert.NoError(t, validateTargets(nil, true, "app=web,tier!=db", "default"))
+	assert.Error(t, validateTargets([]string{"web"}, false, "app=web", "default"))
+	assert.Error(t, validateTargets([]string{"web"}, true, "", "default"))
+	assert.Error(t, validateTargets(nil, true, "app=(web", "default"))
+	// A selector alone must not become a bulk restart.
+	assert.Error(t, validateTargets(nil, false, "app=web", "default"))
+	// A bulk restart never spans every namespace.
+	assert.Error(t, validateTargets(nil, true, "", ""))
+	assert.Error(t, validateTargets(nil, true, "app=web", ""))
+}
+
+func TestRestartAllDeployments(t *testing.T) {
+	ctx := context.Background()
+	deployment := func(name string, labels map[string]string) *v1.Deployment {
+		return &v1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", Labels: labels}}
+	}
+	restarted := func(client *fake.Clientset) []string {
+		list, err := client.AppsV1().Deployments("default").List(ctx, metav1.ListOptions{})
+		assert.NoError(t, err)
+		var names []string
+		for _, d := range list.Items {
+			if _, ok := d.Spec.Template.Annotations[kube.DefaultRestartAnnotationKey]; ok {
+				names = append(names, d.Name)
+			}
+		}
+		return names
+	}
+	newClient := func() *fake.Clientset {
+		return fake.NewSimpleClientset(
+			deployment("web", map[string]string{"app": "web"}),
+			deployment("api", map[string]string{"app": "api"}),
+			deployment("db", nil),
+		)
+	}
+
+	client := newClient()
+	assert.NoError(t, restartAllDeployments(ctx, client, "default", "app=web", 10, nil))
+	assert.Equal(t, []string{"web"}, restarted(client))
+
+	client = newClient()
+	assert.NoError(t, restartAllDeployments(ctx, client, "default", "", 10, nil))
+	assert.ElementsMatch(t, []string{"web", "api", "db"}, restarted(client))
+
+	// More matches than the limit restart nothing.
+	client = newClient()
+	assert.Error(t, restartAllDeployments(ctx, client, "default", "", 2, nil))
+	assert.Empty(t, restarted(client))
+}
+
+func TestRestartAllDeployments_Summary(t *testing.T) {
+	var lines []string
+	log := funcr.New(func(prefix, args string) { lines = append(lines, args) }, funcr.Options{})
+	ctx := logger.WithContext(context.Background(), log)
+	summaries := func() []string {
+		var ret []string
+		for _, l := range lines {
+			if strings.Contains(l, `"msg"="restart summary"`) {
+				ret = append(ret, l)
+			}
+		}
+		lines = nil
+		return ret
+	}
+	newClient := func() *fake.Clientset {
+		return fake.NewSimpleClientset(
+			&v1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"}},
+			&v1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "default"}},
+		)
+	}
+
+	assert.NoError(t, restartAllDeployments(ctx, newClient(), "default", "", 10, nil))
+	assert.Equal(t, []string{`"level"=0 "msg"="restart summary" "restarted"=2 "skipped"=0 "errored"=0 "dryRun"=false`},
+		summaries())
+
+	client := newClient()
+	client.PrependReactor("patch", "deployments", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.New("conflict")
+	})
+	// Every deployment is attempted and counted even when some fail.
+	assert.Error(t, restartAllDeployments(ctx, client, "default", "", 10, nil))
+	assert.Equal(t, []string{`"level"=0 "msg"="restart summary" "restarted"=0 "skipped"=0 "errored"=2 "dryRun"=false`},
+		summaries())
+}
+
+func TestParseMaxUnavailable(t *testing.T) {
+	v, err := parseMaxUnavailable("")
+	assert.NoError(t, err)
+	assert.Nil(t, v)
+
+	v, err = parseMaxUnavailable("2")
+	assert.NoError(t, err)
+	assert.Equal(t, intstr.FromInt32(2), *v)
+
+	v, err = parseMaxUnavailable("25%")
+	assert.NoError(t, err)
+	assert.Equal(t, intstr.FromString("25%"), *v)
+
+	for _, invalid := range []string{"0", "-1", "0%", "abc", "10x%"} {
+		_, err = parseMaxUnavailable(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestWaveSize(t *testing.T) {
+	two := intstr.FromInt32(2)
+	quarter := intstr.FromString("25%")
+	assert.Equal(t, 5, waveSize(nil, 5))
+	assert.Equal(t, 1, waveSize(nil, 0))
+	assert.Equal(t, 2, waveSize(&two, 5))
+	assert.Equal(t, 2, waveSize(&quarter, 8))
+	assert.Equal(t, 1, waveSize(&quarter, 3))
+}
+
+func TestRestartAllDeployments_MaxUnavailable(t *testing.T) {
+	defer func(d time.Duration) { rolloutPollInterval = d }(rolloutPollInterval)
+	rolloutPollInterval = time.Millisecond
+
+	ctx := context.Background()
+	replicas := int32(1)
+	deployment := func(name string) *v1.Deployment {
+		return &v1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec:       v1.DeploymentSpec{Replicas: &replicas},
+			Status:     v1.DeploymentStatus{Replicas: 1, UpdatedReplicas: 1, AvailableReplicas: 1},
+		}
+	}
+	// sequence returns the patch (p) and get (g) calls on deployments in order.
+	sequence := func(client *fake.Clientset) string {
+		var b strings.Builder
+		for _, a := range client.Actions() {
+			if a.GetResource().Resource != "deployments" {
+				continue
+			}
+			switch a.GetVerb() {
+			case "patch":
+				b.WriteString("p")
+			case "get":
+				b.WriteString("g")
+			}
+		}
+		return b.String()
+	}
+	newClient := func() *fake.Clientset {
+		return fake.NewSimpleClientset(deployment("web"), deployment("api"), deployment("db"))
+	}
+
+	client := newClient()
+	one := intstr.FromInt32(1)
+	assert.NoError(t, restartAllDeployments(ctx, client, "default", "", 10, &one))
+	assert.Equal(t, "pgpgpg", sequence(client))
+
+	client = newClient()
+	two := intstr.FromInt32(2)
+	assert.NoError(t, restartAllDeployments(ctx, client, "default", "", 10, &two))
+	assert.Equal(t, "ppggpg", sequence(client))
+
+	client = newClient()
+	assert.NoError(t, restartAllDeployments(ctx, client, "default", "", 10, nil))
+	assert.Equal(t, "ppp", sequence(client))
+
+	client = newClient()
+	client.PrependReactor("patch", "deployments", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, nil
+	})
+	assert.NoError(t, restartAllDeployments(ctx, client, "default", "", 10, &one, kube.WithRestartDryRun(true)))
+	assert.Equal(t, "ppp", sequence(client))
+}
+
+func TestListDeployments(t *testing.T) {
+	ctx := context.Background()
+	deployment := func(name string, labels map[string]string) *v1.Deployment {
+		return &v1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", Labels: labels}}
+	}
+	client := fake.NewSimpleClientset(
+		deployment("web", map[string]string{"app": "web"}),
+		deployment("api", map[string]string{"app": "api"}),
+	)
+
+	var buf bytes.Buffer
+	assert.NoError(t, listDeployments(ctx, client, "default", nil, "", 10, &buf))
+	assert.ElementsMatch(t, []string{"default/web", "default/api"}, strings.Fields(buf.String()))
+
+	buf.Reset()
+	assert.NoError(t, listDeployments(ctx, client, "default", nil, "app=web", 10, &buf))
+	assert.Equal(t, "default/web\n", buf.String())
+
+	buf.Reset()
+	assert.NoError(t, listDeployments(ctx, client, "default", []string{"api"}, "", 10, &buf))
+	assert.Equal(t, "default/api\n", buf.String())
+
+	assert.Error(t, listDeployments(ctx, client, "default", []string{"missing"}, "", 10, &buf))
+	assert.Error(t, listDeployments(ctx, client, "default", nil, "", 1, &buf))
+
+	for _, action := range client.Actions() {
+		assert.Contains(t, []string{"get", "list"}, action.GetVerb())
+	}
+}
@@ -0,0 +1,303 @@
+/*
+MIT License
+
+Copyright (c) 2024 Norihiro Seto
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package restartsts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+
+	"github.com/norseto/k8s-watchdogs/internal/options"
+	"github.com/norseto/k8s-watchdogs/internal/preflight"
+	"github.com/norseto/k8s-watchdogs/internal/validation"
+	"github.com/norseto/k8s-watchdogs/pkg/generics"
+	"github.com/norseto/k8s-watchdogs/pkg/kube"
+	"github.com/norseto/k8s-watchdogs/pkg/kube/client"
+	"github.com/norseto/k8s-watchdogs/pkg/logger"
+	"github.com/spf13/cobra"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// errPatternNamespace is returned when --pattern is used without a namespace,
+// so that a pattern never restarts the statefulsets of every namespace.
+var errPatternNamespace = errors.New("--pattern requires --namespace")
+
+// NewCommand returns a new Cobra command for restarting statefulsets.
+func NewCommand() *cobra.Command {
+	var reason string
+	var annotationKey string
+	var dryRun bool
+	var fieldManager string
+	var pattern string
+	var useRegexp bool
+	var listOnly bool
+
+	opts := &options.Options{}
+	cmd := &cobra.Command{
+		Use:   "restart-sts",
+		Short: "Restart statefulset",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			args, err := opts.Targets(args)
+			if err != nil {
+				logger.FromContext(ctx).Error(err, "invalid targets")
+				return err
+			}
+			if len(args) < 1 && pattern == "" {
+				_ = cmd.Usage()
+				return nil
+			}
+			match, err := compilePattern(pattern, useRegexp)
+			if err == nil && match != nil && len(args) > 0 {
+				err = errors.New("statefulset names cannot be used with --pattern")
+			}
+			if err == nil && match != nil && opts.Namespace() == metav1.NamespaceAll {
+				err = errPatternNamespace
+			}
+			if err != nil {
+				logger.FromContext(ctx).Error(err, "invalid targets")
+				return err
+			}
+			if err := validation.ValidateReason(reason); err != nil {
+				logger.FromContext(ctx).Error(err, "invalid reason")
+				return err
+			}
+			if err := validation.ValidateAnnotationKey(annotationKey); err != nil {
+				logger.FromContext(ctx).Error(err, "invalid annotation key")
+				return err
+			}
+			if err := validation.ValidateFieldManager(fieldManager); err != nil {
+				logger.FromContext(ctx).Error(err, "invalid field manager")
+				return err
+			}
+			clnt, err := client.NewClientset(client.FromContext(ctx))
+			if err != nil {
+				logger.FromContext(ctx).Error(err, "failed to create client")
+				return err
+			}
+			if done, err := preflight.Run(ctx, clnt); done {
+				return err
+			}
+			limit, err := opts.MaxTargets()
+			if err != nil {
+				logger.FromContext(ctx).Error(err, "invalid max targets")
+				return err
+			}
+			if listOnly {
+				return listStatefulSets(ctx, clnt, opts.Namespace(), args, match, limit, cmd.OutOrStdout())
+			}
+			restartOpts := []kube.RestartOption{
+				kube.WithRestartReason(reason), kube.WithRestartAnnotationKey(annotationKey), kube.WithRestartDryRun(dryRun),
+				kube.WithRestartFieldManager(fieldManager),
+			}
+			if match != nil {
+				return restartMatchingStatefulSets(ctx, clnt, opts.Namespace(), match, limit, restartOpts...)
+			}
+			return restartStatefulSet(ctx, clnt, opts.Namespace(), args, restartOpts...)
+		},
+	}
+	opts.BindCommonFlags(cmd)
+	opts.BindFromFileFlags(cmd)
+	opts.BindMaxTargetsFlags(cmd)
+	cmd.Flags().StringVar(&reason, "reason", "",
+		"Reason of the restart recorded in the "+kube.RestartReasonAnnotation+" annotation")
+	cmd.Flags().StringVar(&annotationKey, "annotation-key", kube.DefaultRestartAnnotationKey,
+		"Pod template annotation key that records the restart time")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false,
+		"Report the targets to restart and send the patches as server-side dry runs without persisting them")
+	cmd.Flags().StringVar(&fieldManager, "field-manager", "",
+		"Field manager of the restart annotations. When set, the restart is sent as a server-side apply "+
+			"owned by this manager instead of a strategic merge patch")
+	cmd.Flags().StringVar(&pattern, "pattern", "",
+		"Restart the statefulsets whose names match this shell-style glob (e.g. 'web-*'). "+
+			"Requires --namespace and cannot be used with names")
+	cmd.Flags().BoolVar(&useRegexp, "regexp", false,
+		"Interpret --pattern as an RE2 regular expression that must match the whole name")
+	cmd.Flags().BoolVar(&listOnly, "list", false,
+		"Print the namespace/name of the statefulsets that would be restarted, one per line, and exit without restarting")
+
+	return cmd
+}
+
+// compilePattern compiles the pattern into a name matcher. The pattern is a shell-style glob
+// as in path.Match, or an RE2 regular expression that must match the whole name with useRegexp.
+// An empty pattern returns nil.
+func compilePattern(pattern string, useRegexp bool) (func(string) bool, error) {
+	if pattern == "" {
+		return nil, nil
+	}
+	if useRegexp {
+		re, err := regexp.Compile("^(?:" + pattern + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+		return re.MatchString, nil
+	}
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+	}
+	return func(name string) bool {
+		ok, _ := path.Match(pattern, name)
+		return ok
+	}, nil
+}
+
+// +kubebuilder:rbac:groups=apps,resources=statefulsets,verbs=get;list;patch
+
+func restartStatefulSet(ctx context.Context, client kubernetes.Interface, namespace string, targets []string, opts ...kube.RestartOption) error {
+	log := logger.FromContext(ctx)
+
+	for _, target := range targets {
+		sts, err := client.AppsV1().StatefulSets(namespace).Get(ctx, target, metav1.GetOptions{})
+		if err != nil || sts == nil {
+			log.Error(err, "failed to get statefulset", "target",
+				fmt.Sprintf("%s/%s", namespace, target))
+			return err
+		}
+		if _, err := restartTarget(ctx, client, sts, opts...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// restartMatchingStatefulSets restarts the statefulsets in the namespace whose names match.
+// It restarts nothing when more than limit statefulsets match. Every statefulset that is not
+// restarted is logged with the reason and the outcomes are summarized at the end.
+// It keeps going when a statefulset fails to restart and returns all errors joined.
+// The namespace must not be empty.
+func restartMatchingStatefulSets(ctx context.Context, client kubernetes.Interface, namespace string, match func(string) bool, limit int, opts ...kube.RestartOption) error {
+	log := logger.FromContext(ctx)
+
+	if namespace == metav1.NamespaceAll {
+		log.Error(errPatternNamespace, "invalid namespace")
+		return errPatternNamespace
+	}
+	targets, mismatched, err := matchingStatefulSets(ctx, client, namespace, match, limit)
+	if err != nil {
+		return err
+	}
+	summary := &kube.RestartSummary{Skipped: len(mismatched)}
+	defer summary.Log(ctx, opts...)
+	for _, sts := range mismatched {
+		log.V(1).Info("skipped", "target", fmt.Sprintf("%s/%s", sts.Namespace, sts.Name), "reason", skipPatternMismatch)
+	}
+	if len(targets) == 0 {
+		log.Info("no statefulset matches the pattern", "namespace", namespace)
+		return nil
+	}
+	var errs []error
+	for _, sts := range targets {
+		restarted, err := restartTarget(ctx, client, sts, opts...)
+		summary.Add(restarted, err)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// matchingStatefulSets returns the statefulsets in the namespace whose names match and the others.
+// It returns an error when more than limit statefulsets match.
+func matchingStatefulSets(ctx context.Context, client kubernetes.Interface, namespace string, match func(string) bool,
+	limit int) ([]*appsv1.StatefulSet, []*appsv1.StatefulSet, error) {
+	log := logger.FromContext(ctx)
+
+	list, err := client.AppsV1().StatefulSets(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		log.Error(err, "failed to list statefulsets", "namespace", namespace)
+		return nil, nil, err
+	}
+	targets, mismatched := generics.Partition(
+		generics.Convert(list.Items, func(sts appsv1.StatefulSet) *appsv1.StatefulSet { return sts.DeepCopy() }, nil),
+		func(sts *appsv1.StatefulSet) bool { return match(sts.Name) })
+	if len(targets) > limit {
+		err := fmt.Errorf("%d statefulsets match the pattern, must be at most %d", len(targets), limit)
+		log.Error(err, "too many targets", "namespace", namespace)
+		return nil, nil, err
+	}
+	return targets, mismatched, nil
+}
+
+// listStatefulSets writes the namespace/name of the statefulsets that would be restarted to w,
+// one per line, without restarting them. The statefulsets are given by names, or by match when not nil.
+func listStatefulSets(ctx context.Context, client kubernetes.Interface, namespace string, names []string,
+	match func(string) bool, limit int, w io.Writer) error {
+	log := logger.FromContext(ctx)
+
+	var targets []*appsv1.StatefulSet
+	if match != nil {
+		matched, _, err := matchingStatefulSets(ctx, client, namespace, match, limit)
+		if err != nil {
+			return err
+		}
+		targets = matched
+	}
+	for _, name := range names {
+		sts, err := client.AppsV1().StatefulSets(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			log.Error(err, "failed to get statefulset", "target", fmt.Sprintf("%s/%s", namespace, name))
+			return err
+		}
+		targets = append(targets, sts)
+	}
+	for _, sts := range targets {
+		if _, err := fmt.Fprintf(w, "%s/%s\n", sts.Namespace, sts.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// skipPatternMismatch is the skip reason of a statefulset whose name does not match --pattern.
+const skipPatternMismatch = "PatternMismatch"
+
+// restartTarget restarts the statefulset and logs the result.
+// It returns false when the statefulset was skipped, logging the reason.
+func restartTarget(ctx context.Context, client kubernetes.Interface, sts *appsv1.StatefulSet, opts ...kube.RestartOption) (bool, error) {
+	log := logger.FromContext(ctx)
+	target := fmt.Sprintf("%s/%s", sts.Namespace, sts.Name)
+
+	restarted, err := kube.RestartStatefulSet(ctx, client, sts, opts...)
+	if err != nil {
+		log.Error(err, "failed to restart statefulset", "target", target)
+		return false, err
+	}
+	if !restarted {
+		log.Info("skipped", "target", target, "reason", kube.SkipAlreadyRestarted)
+		return false, nil
+	}
+	if kube.IsRestartDryRun(opts...) {
+		log.Info("would restart (dry run)", "target", target)
+		return true, nil
+	}
+	log.V(1).Info("restarted", "target", target)
+	return true, nil
+}
@@ -0,0 +1,217 @@
+/*
+MIT License
+
+Copyright (c) 2024 Norihiro Seto
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package restartsts
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/go-logr/logr/funcr"
+	"github.com/norseto/k8s-watchdogs/internal/options"
+	"github.com/norseto/k8s-watchdogs/pkg/kube"
+	"github.com/norseto/k8s-watchdogs/pkg/logger"
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestNewCommand(t *testing.T) {
+	cmd := NewCommand()
+	assert.Equal(t, "restart-sts", cmd.Use)
+
+	key, err := cmd.Flags().GetString("annotation-key")
+	assert.NoError(t, err)
+	assert.Equal(t, kube.DefaultRestartAnnotationKey, key)
+
+	manager, err := cmd.Flags().GetString("field-manager")
+	assert.NoError(t, err)
+	assert.Empty(t, manager)
+}
+
+func TestNewCommand_PatternRequiresNamespace(t *testing.T) {
+	cmd := NewCommand()
+	cmd.SetArgs([]string{"--pattern", "web-*"})
+	cmd.SetOut(io.Discard)
+	cmd.SetErr(io.Discard)
+	assert.ErrorContains(t, cmd.Execute(), "--pattern requires --namespace")
+}
+
+func TestRestartStatefulSet(t *testing.T) {
+	ctx := context.Background()
+	client := fake.NewSimpleClientset(&appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-sts", Namespace: "default"},
+	})
+
+	err := restartStatefulSet(ctx, client, "default", []string{"test-sts"},
+		kube.WithRestartAnnotationKey("example.com/restarted-at"))
+	assert.NoError(t, err)
+	sts, _ := client.AppsV1().StatefulSets("default").Get(ctx, "test-sts", metav1.GetOptions{})
+	assert.Contains(t, sts.Spec.Template.Annotations, "example.com/restarted-at")
+	assert.NotContains(t, sts.Spec.Template.Annotations, kube.DefaultRestartAnnotationKey)
+
+	err = restartStatefulSet(ctx, client, "default", []string{"missing"})
+	assert.Error(t, err)
+}
+
+func TestRestartStatefulSet_DryRun(t *testing.T) {
+	ctx := context.Background()
+	client := fake.NewSimpleClientset(&appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-sts", Namespace: "default"},
+	})
+	// Like the API server, dry run patches are not persisted.
+	dryRuns := 0
+	client.PrependReactor("patch", "statefulsets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if len(action.(k8stesting.PatchActionImpl).GetPatchOptions().DryRun) == 0 {
+			return false, nil, nil
+		}
+		dryRuns++
+		return true, &appsv1.StatefulSet{}, nil
+	})
+
+	err := restartStatefulSet(ctx, client, "default", []string{"test-sts"}, kube.WithRestartDryRun(true))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, dryRuns)
+
+	sts, err := client.AppsV1().StatefulSets("default").Get(ctx, "test-sts", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Empty(t, sts.Spec.Template.Annotations)
+}
+
+func TestCompilePattern(t *testing.T) {
+	match, err := compilePattern("", false)
+	assert.NoError(t, err)
+	assert.Nil(t, match)
+
+	match, err = compilePattern("web-*", false)
+	assert.NoError(t, err)
+	assert.True(t, match("web-0"))
+	assert.False(t, match("api-web-0"))
+
+	match, err = compilePattern("web-[0-9]+", true)
+	assert.NoError(t, err)
+	assert.True(t, match("web-12"))
+	assert.False(t, match("web-12-canary"))
+
+	_, err = compilePattern("web-[", false)
+	assert.Error(t, err)
+	_, err = compilePattern("web-(", true)
+	assert.Error(t, err)
+}
+
+func TestRestartMatchingStatefulSets(t *testing.T) {
+	ctx := context.Background()
+	statefulSet := func(name string) runtime.Object {
+		return &appsv1.StatefulSet{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"}}
+	}
+	restarted := func(client *fake.Clientset) []string {
+		list, err := client.AppsV1().StatefulSets("default").List(ctx, metav1.ListOptions{})
+		assert.NoError(t, err)
+		var names []string
+		for _, s := range list.Items {
+			if _, ok := s.Spec.Template.Annotations[kube.DefaultRestartAnnotationKey]; ok {
+				names = append(names, s.Name)
+			}
+		}
+		return names
+	}
+	match, _ := compilePattern("web-*", false)
+
+	client := fake.NewSimpleClientset(statefulSet("web-a"), statefulSet("web-b"), statefulSet("db"))
+	assert.NoError(t, restartMatchingStatefulSets(ctx, client, "default", match, options.DefaultMaxTargets))
+	assert.ElementsMatch(t, []string{"web-a", "web-b"}, restarted(client))
+
+	var objects []runtime.Object
+	for i := 0; i <= options.DefaultMaxTargets; i++ {
+		objects = append(objects, statefulSet(fmt.Sprintf("web-%d", i)))
+	}
+	client = fake.NewSimpleClientset(objects...)
+	assert.Error(t, restartMatchingStatefulSets(ctx, client, "default", match, options.DefaultMaxTargets))
+	assert.Empty(t, restarted(client))
+
+	// A larger limit restarts them deliberately.
+	assert.NoError(t, restartMatchingStatefulSets(ctx, client, "default", match, options.DefaultMaxTargets+1))
+	assert.Len(t, restarted(client), options.DefaultMaxTargets+1)
+
+	// A pattern never spans every namespace.
+	client = fake.NewSimpleClientset(statefulSet("web-a"))
+	assert.ErrorContains(t, restartMatchingStatefulSets(ctx, client, "", match, options.DefaultMaxTargets), "--namespace")
+	assert.Empty(t, restarted(client))
+}
+
+func TestRestartMatchingStatefulSets_Summary(t *testing.T) {
+	var lines []string
+	log := funcr.New(func(prefix, args string) { lines = append(lines, args) }, funcr.Options{Verbosity: 1})
+	ctx := logger.WithContext(context.Background(), log)
+	statefulSet := func(name string) runtime.Object {
+		return &appsv1.StatefulSet{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"}}
+	}
+	client := fake.NewSimpleClientset(statefulSet("web-a"), statefulSet("web-b"), statefulSet("db"))
+	match, _ := compilePattern("web-*", false)
+
+	assert.NoError(t, restartMatchingStatefulSets(ctx, client, "default", match, options.DefaultMaxTargets))
+	assert.Contains(t, lines, `"level"=1 "msg"="skipped" "target"="default/db" "reason"="PatternMismatch"`)
+	assert.Contains(t, lines, `"level"=0 "msg"="restart summary" "restarted"=2 "skipped"=1 "errored"=0 "dryRun"=false`)
+
+	// Every matching statefulset is attempted and counted even when some fail.
+	lines = nil
+	client = fake.NewSimpleClientset(statefulSet("web-a"), statefulSet("web-b"), statefulSet("db"))
+	client.PrependReactor("patch", "statefulsets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.New("conflict")
+	})
+	assert.Error(t, restartMatchingStatefulSets(ctx, client, "default", match, options.DefaultMaxTargets))
+	assert.Contains(t, lines, `"level"=0 "msg"="restart summary" "restarted"=0 "skipped"=1 "errored"=2 "dryRun"=false`)
+}
+
+func TestListStatefulSets(t *testing.T) {
+	ctx := context.Background()
+	statefulSet := func(name string) runtime.Object {
+		return &appsv1.StatefulSet{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"}}
+	}
+	client := fake.NewSimpleClientset(statefulSet("web-a"), statefulSet("web-b"), statefulSet("db"))
+	match, _ := compilePattern("web-*", false)
+
+	var buf bytes.Buffer
+	assert.NoError(t, listStatefulSets(ctx, client, "default", nil, match, options.DefaultMaxTargets, &buf))
+	assert.ElementsMatch(t, []string{"default/web-a", "default/web-b"}, strings.Fields(buf.String()))
+
+	buf.Reset()
+	assert.NoError(t, listStatefulSets(ctx, client, "default", []string{"db"}, nil, options.DefaultMaxTargets, &buf))
+	assert.Equal(t, "default/db\n", buf.String())
+
+	assert.Error(t, listStatefulSets(ctx, client, "default", []string{"missing"}, nil, options.DefaultMaxTargets, &buf))
+	assert.Error(t, listStatefulSets(ctx, client, "default", nil, match, 1, &buf))
+
+	for _, action := range client.Actions() {
+		assert.Contains(t, []string{"get", "list"}, action.GetVerb())
+	}
+}
@@ -0,0 +1,186 @@
+/*
+MIT License
+
+Copyright (c) 2024 Norihiro Seto
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package scale
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/norseto/k8s-watchdogs/internal/options"
+	"github.com/norseto/k8s-watchdogs/internal/preflight"
+	"github.com/norseto/k8s-watchdogs/internal/validation"
+	"github.com/norseto/k8s-watchdogs/pkg/generics"
+	"github.com/norseto/k8s-watchdogs/pkg/kube"
+	"github.com/norseto/k8s-watchdogs/pkg/kube/client"
+	"github.com/norseto/k8s-watchdogs/pkg/logger"
+	"github.com/spf13/cobra"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// NewCommand returns a new Cobra command for scaling deployments or statefulsets.
+func NewCommand() *cobra.Command {
+	var scOpts scaleOptions
+
+	opts := &options.Options{}
+	cmd := &cobra.Command{
+		Use:   "scale [name...]",
+		Short: "Scale deployments or statefulsets",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) < 1 && !scOpts.all {
+				_ = cmd.Usage()
+				return nil
+			}
+			ctx := cmd.Context()
+			scOpts.namespace = opts.Namespace()
+			scOpts.names = args
+			if err := scOpts.validate(); err != nil {
+				logger.FromContext(ctx).Error(err, "invalid arguments")
+				return err
+			}
+			clnt, err := client.NewClientset(client.FromContext(ctx))
+			if err != nil {
+				logger.FromContext(ctx).Error(err, "failed to create client")
+				return err
+			}
+			if done, err := preflight.Run(ctx, clnt); done {
+				return err
+			}
+			return scale(ctx, clnt, scOpts)
+		},
+	}
+	opts.BindCommonFlags(cmd)
+
+	flg := cmd.Flags()
+	flg.IntVar(&scOpts.replicas, "replicas", 0,
+		fmt.Sprintf("Number of replicas to scale to. Required. Values over %d are capped.", maxReplicas))
+	flg.BoolVar(&scOpts.all, "all", false, "Scale every target in the namespace.")
+	flg.BoolVar(&scOpts.statefulsets, "statefulsets", false, "Scale statefulsets instead of deployments.")
+	// No default, so that a forgotten flag never scales a workload down.
+	_ = cmd.MarkFlagRequired("replicas")
+	return cmd
+}
+
+// maxReplicas is the upper bound of the replicas a scale sets.
+const maxReplicas = 100
+
+// scaleOptions represents options for scaling.
+type scaleOptions struct {
+	namespace    string
+	names        []string
+	replicas     int
+	all          bool
+	statefulsets bool
+}
+
+// validate checks the namespace, the target names and the replicas.
+// The namespace is required because scaling is never applied across namespaces.
+func (o *scaleOptions) validate() error {
+	if o.namespace == "" {
+		return errors.New("--namespace is required")
+	}
+	if err := validation.ValidateNamespace(o.namespace); err != nil {
+		return err
+	}
+	if o.all && len(o.names) > 0 {
+		return errors.New("--all cannot be used with names")
+	}
+	for _, name := range o.names {
+		if err := validation.ValidateResourceName(name); err != nil {
+			return err
+		}
+	}
+	if o.replicas < 0 {
+		return fmt.Errorf("invalid --replicas %d: must not be negative", o.replicas)
+	}
+	return nil
+}
+
+// kind returns the kind of the targets.
+func (o *scaleOptions) kind() string {
+	if o.statefulsets {
+		return kube.RestartKindStatefulSet
+	}
+	return kube.RestartKindDeployment
+}
+
+// +kubebuilder:rbac:groups=apps,resources=deployments,verbs=list;patch
+// +kubebuilder:rbac:groups=apps,resources=statefulsets,verbs=list;patch
+
+// scale sets the replicas of the targets. It keeps going when a target fails
+// and returns all errors joined.
+func scale(ctx context.Context, client kubernetes.Interface, opts scaleOptions) error {
+	log := logger.FromContext(ctx)
+
+	replicas := opts.replicas
+	if replicas > maxReplicas {
+		log.Info("replicas capped", "requested", replicas, "max", maxReplicas)
+		replicas = maxReplicas
+	}
+
+	names := opts.names
+	if opts.all {
+		all, err := listNames(ctx, client, opts.namespace, opts.statefulsets)
+		if err != nil {
+			log.Error(err, "failed to list targets", "namespace", opts.namespace, "kind", opts.kind())
+			return err
+		}
+		names = all
+	}
+
+	scaleFunc := kube.ScaleDeployment
+	if opts.statefulsets {
+		scaleFunc = kube.ScaleStatefulSet
+	}
+	var errs []error
+	for _, name := range names {
+		target := fmt.Sprintf("%s/%s", opts.namespace, name)
+		if err := scaleFunc(ctx, client, opts.namespace, name, int32(replicas)); err != nil {
+			log.Error(err, "failed to scale", "kind", opts.kind(), "target", target)
+			errs = append(errs, fmt.Errorf("failed to scale %s %s: %w", opts.kind(), target, err))
+			continue
+		}
+		log.Info("scaled", "kind", opts.kind(), "target", target, "replicas", replicas)
+	}
+	return errors.Join(errs...)
+}
+
+// listNames returns the names of the deployments or statefulsets in the namespace.
+func listNames(ctx context.Context, client kubernetes.Interface, namespace string, statefulsets bool) ([]string, error) {
+	if statefulsets {
+		list, err := client.AppsV1().StatefulSets(namespace).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return nil, err
+		}
+		return generics.Convert(list.Items, func(s appsv1.StatefulSet) string { return s.Name }, nil), nil
+	}
+	list, err := client.AppsV1().Deployments(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return generics.Convert(list.Items, func(d appsv1.Deployment) string { return d.Name }, nil), nil
+}
@@ -0,0 +1,115 @@
+/*
+MIT License
+
+Copyright (c) 2024 Norihiro Seto
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package scale
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestNewCommand(t *testing.T) {
+	cmd := NewCommand()
+	assert.Equal(t, "scale [name...]", cmd.Use)
+	assert.Equal(t, []string{"true"}, cmd.Flag("replicas").Annotations[cobra.BashCompOneRequiredFlag])
+
+	// The replicas are required before connecting to the cluster.
+	cmd.SetArgs([]string{"web", "--namespace", "default"})
+	cmd.SetOut(io.Discard)
+	cmd.SetErr(io.Discard)
+	assert.ErrorContains(t, cmd.Execute(), "replicas")
+}
+
+func TestScaleOptions_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    scaleOptions
+		wantErr bool
+	}{
+		{name: "valid", opts: scaleOptions{namespace: "default", names: []string{"web"}, replicas: 2}},
+		{name: "all", opts: scaleOptions{namespace: "default", all: true}},
+		{name: "no namespace", opts: scaleOptions{names: []string{"web"}}, wantErr: true},
+		{name: "invalid namespace", opts: scaleOptions{namespace: "Default", names: []string{"web"}}, wantErr: true},
+		{name: "invalid name", opts: scaleOptions{namespace: "default", names: []string{"Web_1"}}, wantErr: true},
+		{name: "all with names", opts: scaleOptions{namespace: "default", names: []string{"web"}, all: true}, wantErr: true},
+		{name: "negative replicas", opts: scaleOptions{namespace: "default", names: []string{"web"}, replicas: -1}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.opts.validate()
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestScale(t *testing.T) {
+	ctx := context.Background()
+	one := int32(1)
+	deployment := func(name string) *appsv1.Deployment {
+		return &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec:       appsv1.DeploymentSpec{Replicas: &one},
+		}
+	}
+	client := fake.NewSimpleClientset(deployment("web"), deployment("api"),
+		&appsv1.StatefulSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "default"},
+			Spec:       appsv1.StatefulSetSpec{Replicas: &one},
+		})
+	replicasOf := func(name string) int32 {
+		dep, err := client.AppsV1().Deployments("default").Get(ctx, name, metav1.GetOptions{})
+		assert.NoError(t, err)
+		return *dep.Spec.Replicas
+	}
+
+	err := scale(ctx, client, scaleOptions{namespace: "default", names: []string{"web"}, replicas: 3})
+	assert.NoError(t, err)
+	assert.Equal(t, int32(3), replicasOf("web"))
+	assert.Equal(t, int32(1), replicasOf("api"))
+
+	err = scale(ctx, client, scaleOptions{namespace: "default", all: true, replicas: maxReplicas + 1})
+	assert.NoError(t, err)
+	assert.Equal(t, int32(maxReplicas), replicasOf("web"))
+	assert.Equal(t, int32(maxReplicas), replicasOf("api"))
+
+	err = scale(ctx, client, scaleOptions{namespace: "default", all: true, statefulsets: true, replicas: 0})
+	assert.NoError(t, err)
+	sts, _ := client.AppsV1().StatefulSets("default").Get(ctx, "db", metav1.GetOptions{})
+	assert.Equal(t, int32(0), *sts.Spec.Replicas)
+
+	err = scale(ctx, client, scaleOptions{namespace: "default", names: []string{"missing", "web"}, replicas: 2})
+	assert.Error(t, err)
+	assert.Equal(t, int32(2), replicasOf("web"))
+}
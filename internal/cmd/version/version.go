@@ -0,0 +1,62 @@
+/*
+MIT License
+
+Copyright (c) 2024 Norihiro Seto
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package version
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	watchdogs "github.com/norseto/k8s-watchdogs"
+	"github.com/norseto/k8s-watchdogs/internal/output"
+	"github.com/spf13/cobra"
+)
+
+// NewCommand returns a new Cobra command for printing the build information.
+func NewCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "version",
+		Short: "Print the version information",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return printVersion(cmd.OutOrStdout(), output.FromContext(cmd.Context()).Format())
+		},
+	}
+}
+
+// buildInfo is the structured form of the build information.
+type buildInfo struct {
+	Release    string `json:"release"`
+	GitVersion string `json:"gitVersion"`
+}
+
+// printVersion writes the build information in the output format.
+func printVersion(w io.Writer, format string) error {
+	info := buildInfo{Release: watchdogs.RELEASE_VERSION, GitVersion: watchdogs.GitVersion}
+	if format == output.FormatJSON {
+		return json.NewEncoder(w).Encode(info)
+	}
+	_, err := fmt.Fprintf(w, "watchdogs version %s (git: %s)\n", info.Release, info.GitVersion)
+	return err
+}
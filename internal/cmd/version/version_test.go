@@ -0,0 +1,62 @@
+/*
+MIT License
+
+Copyright (c) 2024 Norihiro Seto
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package version
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	watchdogs "github.com/norseto/k8s-watchdogs"
+	"github.com/norseto/k8s-watchdogs/internal/output"
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewCommand_JSON(t *testing.T) {
+	outOpts := &output.Options{}
+	fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	outOpts.BindPFlags(fs)
+	assert.NoError(t, fs.Parse([]string{"--output=json"}))
+
+	var buf bytes.Buffer
+	cmd := NewCommand()
+	cmd.SetOut(&buf)
+	cmd.SetArgs([]string{})
+	assert.NoError(t, cmd.ExecuteContext(output.WithContext(context.Background(), outOpts)))
+
+	var got map[string]string
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &got))
+	assert.Equal(t, watchdogs.RELEASE_VERSION, got["release"])
+	assert.Equal(t, watchdogs.GitVersion, got["gitVersion"])
+}
+
+func TestPrintVersion_Text(t *testing.T) {
+	var buf bytes.Buffer
+	assert.NoError(t, printVersion(&buf, output.FormatText))
+	assert.Contains(t, buf.String(), watchdogs.RELEASE_VERSION)
+	assert.Contains(t, buf.String(), watchdogs.GitVersion)
+}
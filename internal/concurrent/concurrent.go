@@ -0,0 +1,164 @@
+/*
+MIT License
+
+Copyright (c) 2024 Norihiro Seto
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package concurrent
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// Budget limits the total number of operations, such as deletions, shared across goroutines.
+type Budget struct {
+	limit int64
+	used  atomic.Int64
+}
+
+// NewBudget returns a new Budget allowing up to limit operations.
+// A limit less than 1 means unlimited.
+func NewBudget(limit int) *Budget {
+	return &Budget{limit: int64(limit)}
+}
+
+// Take consumes one operation from the budget.
+// It returns false when the budget is exhausted.
+func (b *Budget) Take() bool {
+	if b.limit < 1 {
+		b.used.Add(1)
+		return true
+	}
+	for {
+		used := b.used.Load()
+		if used >= b.limit {
+			return false
+		}
+		if b.used.CompareAndSwap(used, used+1) {
+			return true
+		}
+	}
+}
+
+// Release returns one operation to the budget, e.g. when the operation failed.
+func (b *Budget) Release() {
+	b.used.Add(-1)
+}
+
+// Used returns the number of operations consumed.
+func (b *Budget) Used() int {
+	return int(b.used.Load())
+}
+
+// pacerJitter is the maximum jitter factor added to the interval of a Pacer.
+const pacerJitter = 0.1
+
+// Pacer spaces out successive operations, such as deletions, shared across goroutines.
+type Pacer struct {
+	interval time.Duration
+	mu       sync.Mutex
+	started  bool
+}
+
+// NewPacer returns a new Pacer waiting the interval plus a small jitter between operations.
+// An interval of zero or less never waits.
+func NewPacer(interval time.Duration) *Pacer {
+	return &Pacer{interval: interval}
+}
+
+// Wait waits before an operation. The first operation does not wait.
+// Concurrent callers wait in turn. It returns the context error if the context is done while waiting.
+func (p *Pacer) Wait(ctx context.Context) error {
+	if p == nil || p.interval <= 0 {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.started {
+		p.started = true
+		return ctx.Err()
+	}
+	timer := time.NewTimer(wait.Jitter(p.interval, pacerJitter))
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// ForEach calls action for each item, running at most parallelism actions concurrently.
+// A parallelism less than 1 is treated as 1. Items not yet started are skipped once
+// the context is done. The errors returned by the actions are joined.
+func ForEach[T any](ctx context.Context, items []T, parallelism int, action func(context.Context, T) error) error {
+	if parallelism < 1 {
+		parallelism = 1
+	}
+
+	var mu sync.Mutex
+	var errs []error
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, parallelism)
+
+	for _, item := range items {
+		if err := ctx.Err(); err != nil {
+			mu.Lock()
+			errs = append(errs, err)
+			mu.Unlock()
+			break
+		}
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(item T) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			if err := action(ctx, item); err != nil {
+				mu.Lock()
+				errs = append(errs, err)
+				mu.Unlock()
+			}
+		}(item)
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// CallWithTimeout calls fn with a context that is done after the timeout, bounding a single
+// API call so that a slow call does not use up the budget of the whole run. The context is
+// derived from ctx, so a cancellation of ctx still propagates to fn.
+// A timeout of zero or less calls fn with ctx as is.
+func CallWithTimeout(ctx context.Context, timeout time.Duration, fn func(context.Context) error) error {
+	if timeout <= 0 {
+		return fn(ctx)
+	}
+	cctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	return fn(cctx)
+}
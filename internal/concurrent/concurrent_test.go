@@ -0,0 +1,172 @@
+/*
+MIT License
+
+Copyright (c) 2024 Norihiro Seto
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package concurrent
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBudget(t *testing.T) {
+	b := NewBudget(2)
+	assert.True(t, b.Take())
+	assert.True(t, b.Take())
+	assert.False(t, b.Take())
+	assert.Equal(t, 2, b.Used())
+
+	b.Release()
+	assert.True(t, b.Take())
+
+	unlimited := NewBudget(0)
+	for i := 0; i < 1000; i++ {
+		assert.True(t, unlimited.Take())
+	}
+	assert.Equal(t, 1000, unlimited.Used())
+}
+
+func TestBudget_Concurrent(t *testing.T) {
+	b := NewBudget(50)
+	var taken atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 200; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if b.Take() {
+				taken.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(50), taken.Load())
+}
+
+func TestPacer(t *testing.T) {
+	ctx := context.Background()
+	p := NewPacer(20 * time.Millisecond)
+	start := time.Now()
+	assert.NoError(t, p.Wait(ctx))
+	assert.Less(t, time.Since(start), 20*time.Millisecond)
+	assert.NoError(t, p.Wait(ctx))
+	assert.GreaterOrEqual(t, time.Since(start), 20*time.Millisecond)
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	assert.ErrorIs(t, p.Wait(canceled), context.Canceled)
+
+	var none *Pacer
+	assert.NoError(t, none.Wait(ctx))
+	assert.NoError(t, NewPacer(0).Wait(ctx))
+}
+
+func TestForEach_Concurrent(t *testing.T) {
+	items := []string{"a", "b", "c"}
+	var arrived sync.WaitGroup
+	arrived.Add(len(items))
+	all := make(chan struct{})
+	go func() {
+		arrived.Wait()
+		close(all)
+	}()
+
+	err := ForEach(context.Background(), items, len(items), func(ctx context.Context, item string) error {
+		arrived.Done()
+		select {
+		case <-all:
+			return nil
+		case <-time.After(5 * time.Second):
+			return errors.New("items were not processed concurrently")
+		}
+	})
+	assert.NoError(t, err)
+}
+
+func TestForEach_Bounded(t *testing.T) {
+	var running, maxRunning atomic.Int32
+	err := ForEach(context.Background(), make([]int, 20), 3, func(ctx context.Context, _ int) error {
+		n := running.Add(1)
+		for {
+			m := maxRunning.Load()
+			if n <= m || maxRunning.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		time.Sleep(time.Millisecond)
+		running.Add(-1)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.LessOrEqual(t, maxRunning.Load(), int32(3))
+}
+
+func TestForEach_Errors(t *testing.T) {
+	errA, errB := errors.New("a failed"), errors.New("b failed")
+	err := ForEach(context.Background(), []string{"a", "b", "c"}, 2, func(ctx context.Context, item string) error {
+		switch item {
+		case "a":
+			return errA
+		case "b":
+			return errB
+		}
+		return nil
+	})
+	assert.ErrorIs(t, err, errA)
+	assert.ErrorIs(t, err, errB)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	called := false
+	err = ForEach(ctx, []string{"a"}, 1, func(ctx context.Context, item string) error {
+		called = true
+		return nil
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.False(t, called)
+}
+
+func TestCallWithTimeout(t *testing.T) {
+	wait := func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	assert.ErrorIs(t, CallWithTimeout(context.Background(), 10*time.Millisecond, wait), context.DeadlineExceeded)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.ErrorIs(t, CallWithTimeout(ctx, time.Hour, wait), context.Canceled)
+
+	err := CallWithTimeout(context.Background(), 0, func(ctx context.Context) error {
+		_, ok := ctx.Deadline()
+		assert.False(t, ok)
+		return nil
+	})
+	assert.NoError(t, err)
+}
@@ -0,0 +1,117 @@
+/*
+MIT License
+
+Copyright (c) 2024 Norihiro Seto
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package config
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/pflag"
+	"sigs.k8s.io/yaml"
+)
+
+// Options represents the config file options of commands.
+type Options struct {
+	path string
+}
+
+// BindPFlags adds the "config" flag to the given FlagSet.
+func (o *Options) BindPFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&o.path, "config", "",
+		"Path to a YAML file with default flag values (namespace, logLevel and kubeconfig). "+
+			"Explicit flags and environment variables take precedence.")
+}
+
+// Path returns the path of the config file. An empty path means no config file.
+func (o *Options) Path() string {
+	return o.path
+}
+
+// Config represents the default flag values read from a config file.
+type Config struct {
+	// Namespace is the default of the "namespace" flag.
+	Namespace string `json:"namespace,omitempty"`
+	// LogLevel is the default of the "zap-log-level" flag.
+	LogLevel string `json:"logLevel,omitempty"`
+	// Kubeconfig is the default of the "kubeconfig" flag. The KUBECONFIG environment variable
+	// takes precedence over it.
+	Kubeconfig string `json:"kubeconfig,omitempty"`
+}
+
+// defaultValue is a default flag value and the environment variable that overrides it.
+type defaultValue struct {
+	flag  string
+	env   string
+	value string
+}
+
+// Load reads the config file at the path. Unknown keys are an error.
+// An empty path returns an empty config.
+func Load(path string) (*Config, error) {
+	cfg := &Config{}
+	if path == "" {
+		return cfg, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+	if err := yaml.UnmarshalStrict(data, cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// Apply sets the values of the config to the flags in the FlagSet as their defaults.
+// A value is skipped when it is empty, the flag is not defined or explicitly set,
+// or the environment variable that overrides it is set. The flags are not marked as changed,
+// so that a config value does not conflict with a flag given on the command line.
+func (c *Config) Apply(fs *pflag.FlagSet) error {
+	for _, d := range c.defaults() {
+		if d.value == "" {
+			continue
+		}
+		f := fs.Lookup(d.flag)
+		if f == nil || f.Changed {
+			continue
+		}
+		if d.env != "" && os.Getenv(d.env) != "" {
+			continue
+		}
+		if err := f.Value.Set(d.value); err != nil {
+			return fmt.Errorf("invalid config value for %s: %w", d.flag, err)
+		}
+		f.DefValue = d.value
+	}
+	return nil
+}
+
+func (c *Config) defaults() []defaultValue {
+	return []defaultValue{
+		{flag: "namespace", value: c.Namespace},
+		{flag: "zap-log-level", value: c.LogLevel},
+		{flag: "kubeconfig", env: "KUBECONFIG", value: c.Kubeconfig},
+	}
+}
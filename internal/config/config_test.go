@@ -0,0 +1,113 @@
+/*
+MIT License
+
+Copyright (c) 2024 Norihiro Seto
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func testFlagSet() *pflag.FlagSet {
+	fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	fs.String("namespace", "", "")
+	fs.String("zap-log-level", "info", "")
+	fs.String("kubeconfig", "", "")
+	return fs
+}
+
+func TestLoad(t *testing.T) {
+	cfg, err := Load("")
+	assert.NoError(t, err)
+	assert.Equal(t, &Config{}, cfg)
+
+	cfg, err = Load(writeConfig(t, "namespace: apps\nlogLevel: debug\nkubeconfig: /tmp/kubeconfig\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, &Config{Namespace: "apps", LogLevel: "debug", Kubeconfig: "/tmp/kubeconfig"}, cfg)
+
+	_, err = Load(writeConfig(t, "namespaces: apps\n"))
+	assert.Error(t, err)
+
+	_, err = Load(filepath.Join(t.TempDir(), "missing.yaml"))
+	assert.Error(t, err)
+}
+
+func TestApply(t *testing.T) {
+	cfg := &Config{Namespace: "apps", LogLevel: "debug", Kubeconfig: "/tmp/kubeconfig"}
+
+	t.Run("Defaults", func(t *testing.T) {
+		t.Setenv("KUBECONFIG", "")
+		fs := testFlagSet()
+		assert.NoError(t, fs.Parse(nil))
+		assert.NoError(t, cfg.Apply(fs))
+		assert.Equal(t, "apps", fs.Lookup("namespace").Value.String())
+		assert.Equal(t, "debug", fs.Lookup("zap-log-level").Value.String())
+		assert.Equal(t, "/tmp/kubeconfig", fs.Lookup("kubeconfig").Value.String())
+		assert.False(t, fs.Changed("namespace"))
+		assert.Equal(t, "apps", fs.Lookup("namespace").DefValue)
+	})
+
+	t.Run("NotConflictingWithAllNamespaces", func(t *testing.T) {
+		// Commands reject --namespace with -A only when it is given on the command line.
+		t.Setenv("KUBECONFIG", "")
+		fs := testFlagSet()
+		all := fs.BoolP("all-namespaces", "A", false, "")
+		assert.NoError(t, fs.Parse([]string{"-A"}))
+		assert.NoError(t, cfg.Apply(fs))
+		assert.True(t, *all)
+		assert.False(t, fs.Changed("namespace"))
+	})
+
+	t.Run("ExplicitFlagWins", func(t *testing.T) {
+		t.Setenv("KUBECONFIG", "")
+		fs := testFlagSet()
+		assert.NoError(t, fs.Parse([]string{"--namespace=default"}))
+		assert.NoError(t, cfg.Apply(fs))
+		assert.Equal(t, "default", fs.Lookup("namespace").Value.String())
+	})
+
+	t.Run("EnvWins", func(t *testing.T) {
+		t.Setenv("KUBECONFIG", "/etc/kubeconfig")
+		fs := testFlagSet()
+		assert.NoError(t, fs.Parse(nil))
+		assert.NoError(t, cfg.Apply(fs))
+		assert.Equal(t, "", fs.Lookup("kubeconfig").Value.String())
+	})
+
+	t.Run("UndefinedFlag", func(t *testing.T) {
+		fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+		assert.NoError(t, cfg.Apply(fs))
+	})
+}
@@ -25,13 +25,37 @@ SOFTWARE.
 package options
 
 import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/norseto/k8s-watchdogs/internal/validation"
+	"github.com/norseto/k8s-watchdogs/pkg/kube"
+	"github.com/norseto/k8s-watchdogs/pkg/kube/client"
 	"github.com/spf13/cobra"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
+const (
+	// DefaultMaxTargets is the default maximum number of targets of a run.
+	DefaultMaxTargets = 50
+	// MaxTargetsCeiling is the absolute ceiling of the maximum number of targets of a run.
+	MaxTargetsCeiling = 1000
+)
+
 // Options represents a set of configuration options.
 type Options struct {
-	namespace string
+	namespace     string
+	priorityClass kube.PriorityClassFilter
+	include       []string
+	exclude       []string
+	fromFile      string
+	reportOnly    bool
+	failOnFinding bool
+	maxTargets    int
+	maxTargetsSet bool
+	callTimeout   time.Duration
 }
 
 // BindCommonFlags binds the "namespace" flag to the "namespace" field in the Options struct.
@@ -44,3 +68,149 @@ func (o *Options) BindCommonFlags(cmd *cobra.Command) {
 func (o *Options) Namespace() string {
 	return o.namespace
 }
+
+// BindPriorityClassFlags binds the "priority-class" and "exclude-priority-class" flags
+// to the priority class filter in the Options struct.
+func (o *Options) BindPriorityClassFlags(cmd *cobra.Command) {
+	cmd.Flags().StringSliceVar(&o.priorityClass.Include, "priority-class", nil,
+		"Only target pods with these priority class names.")
+	cmd.Flags().StringSliceVar(&o.priorityClass.Exclude, "exclude-priority-class", nil,
+		"Never target pods with these priority class names.")
+}
+
+// PriorityClassFilter returns the priority class filter in the Options struct.
+func (o *Options) PriorityClassFilter() kube.PriorityClassFilter {
+	return o.priorityClass
+}
+
+// BindNamespaceScopeFlags binds the "include-namespace" and "exclude-namespace" flags
+// that restrict the namespaces targeted when operating across all namespaces.
+// defaultExclude is the default of the "exclude-namespace" flag.
+func (o *Options) BindNamespaceScopeFlags(cmd *cobra.Command, defaultExclude ...string) {
+	cmd.Flags().StringSliceVar(&o.include, "include-namespace", nil,
+		"Only target pods in these namespaces. Empty targets all namespaces.")
+	cmd.Flags().StringSliceVar(&o.exclude, "exclude-namespace", defaultExclude,
+		"Never target pods in these namespaces. Takes precedence over --include-namespace.")
+}
+
+// NamespaceScope returns the namespace scope in the Options struct.
+func (o *Options) NamespaceScope() kube.NamespaceScope {
+	return kube.NamespaceScope{
+		Include: kube.NewNamespaceSet(o.include...),
+		Exclude: kube.NewNamespaceSet(o.exclude...),
+	}
+}
+
+// BindReportFlags binds the "report-only" and "fail-on-findings" flags used by monitoring runs.
+func (o *Options) BindReportFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&o.reportOnly, "report-only", false,
+		"Only report the findings (counts and names) without changing anything.")
+	cmd.Flags().BoolVar(&o.failOnFinding, "fail-on-findings", false,
+		"Exit with code 3 when there are findings, e.g. to trigger alerts. Composes with --report-only.")
+}
+
+// ReportOnly returns whether only the findings should be reported.
+func (o *Options) ReportOnly() bool {
+	return o.reportOnly
+}
+
+// FailOnFindings returns whether the command should fail when there are findings.
+func (o *Options) FailOnFindings() bool {
+	return o.failOnFinding
+}
+
+// BindCallTimeoutFlags binds the "call-timeout" flag that bounds each API call of a run.
+func (o *Options) BindCallTimeoutFlags(cmd *cobra.Command) {
+	cmd.Flags().DurationVar(&o.callTimeout, "call-timeout", 0,
+		"Maximum duration of each API call, e.g. a list or a delete (e.g. 30s), so that a slow call does not "+
+			"use up the --timeout of the run. Zero means no per-call timeout.")
+}
+
+// CallTimeout returns the maximum duration of each API call, which is 0 for no timeout.
+// It fails when the "call-timeout" flag is negative.
+func (o *Options) CallTimeout() (time.Duration, error) {
+	if o.callTimeout < 0 {
+		return 0, fmt.Errorf("invalid --call-timeout %v: must not be negative", o.callTimeout)
+	}
+	return o.callTimeout, nil
+}
+
+// BindFromFileFlags binds the "from-file" flag that reads target names from a file.
+func (o *Options) BindFromFileFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&o.fromFile, "from-file", "",
+		"Path of a file listing target names, one per line. Blank lines and lines starting with # are ignored. "+
+			"Combined with the names given as arguments.")
+}
+
+// BindMaxTargetsFlags binds the "max-targets" flag that caps the number of targets of a run.
+func (o *Options) BindMaxTargetsFlags(cmd *cobra.Command) {
+	o.maxTargetsSet = true
+	cmd.Flags().IntVar(&o.maxTargets, "max-targets", DefaultMaxTargets,
+		fmt.Sprintf("Maximum number of targets of a run, guarding against mistakes. Must be between 1 and %d.",
+			MaxTargetsCeiling))
+}
+
+// MaxTargets returns the maximum number of targets of a run. It is DefaultMaxTargets unless
+// the "max-targets" flag is bound, and fails when the flag is out of range.
+func (o *Options) MaxTargets() (int, error) {
+	if !o.maxTargetsSet {
+		return DefaultMaxTargets, nil
+	}
+	if o.maxTargets < 1 || o.maxTargets > MaxTargetsCeiling {
+		return 0, fmt.Errorf("invalid --max-targets %d: must be between 1 and %d", o.maxTargets, MaxTargetsCeiling)
+	}
+	return o.maxTargets, nil
+}
+
+// Targets returns the names given as arguments followed by the names read from the file
+// of the "from-file" flag, without duplicates. The file path is checked like a kubeconfig path
+// and each name read must be a valid resource name. It fails with more names than MaxTargets.
+func (o *Options) Targets(args []string) ([]string, error) {
+	limit, err := o.MaxTargets()
+	if err != nil {
+		return nil, err
+	}
+	names := args
+	if o.fromFile != "" {
+		read, err := readNames(o.fromFile)
+		if err != nil {
+			return nil, err
+		}
+		names = append(append([]string{}, args...), read...)
+	}
+	seen := make(map[string]bool, len(names))
+	targets := make([]string, 0, len(names))
+	for _, n := range names {
+		if !seen[n] {
+			seen[n] = true
+			targets = append(targets, n)
+		}
+	}
+	if len(targets) > limit {
+		return nil, fmt.Errorf("%d targets given, must be at most %d", len(targets), limit)
+	}
+	return targets, nil
+}
+
+// readNames reads newline-separated resource names from the file.
+func readNames(path string) ([]string, error) {
+	if err := client.ValidateFilePath(path); err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	var names []string
+	for i, line := range strings.Split(string(data), "\n") {
+		name := strings.TrimSpace(line)
+		if name == "" || strings.HasPrefix(name, "#") {
+			continue
+		}
+		if err := validation.ValidateResourceName(name); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, i+1, err)
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
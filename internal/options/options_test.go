@@ -25,7 +25,12 @@ SOFTWARE.
 package options
 
 import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/spf13/cobra"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -82,3 +87,188 @@ func TestOptions_BindCommonFlags(t *testing.T) {
 		}
 	}
 }
+
+func TestOptions_BindPriorityClassFlags(t *testing.T) {
+	cmd := &cobra.Command{}
+	options := &Options{}
+
+	options.BindPriorityClassFlags(cmd)
+	if err := cmd.Flags().Parse([]string{"--priority-class=high,low", "--exclude-priority-class=system"}); err != nil {
+		t.Fatal(err)
+	}
+
+	filter := options.PriorityClassFilter()
+	if len(filter.Include) != 2 || filter.Include[0] != "high" || filter.Include[1] != "low" {
+		t.Errorf("Unexpected include list %v", filter.Include)
+	}
+	if len(filter.Exclude) != 1 || filter.Exclude[0] != "system" {
+		t.Errorf("Unexpected exclude list %v", filter.Exclude)
+	}
+}
+
+func TestOptions_BindNamespaceScopeFlags(t *testing.T) {
+	cmd := &cobra.Command{}
+	options := &Options{}
+
+	options.BindNamespaceScopeFlags(cmd)
+	if err := cmd.Flags().Parse([]string{"--include-namespace=app,web", "--exclude-namespace=web"}); err != nil {
+		t.Fatal(err)
+	}
+
+	scope := options.NamespaceScope()
+	if len(scope.Include) != 2 || !scope.Include.Has("app") || !scope.Include.Has("web") {
+		t.Errorf("Unexpected include set %v", scope.Include)
+	}
+	if len(scope.Exclude) != 1 || !scope.Exclude.Has("web") {
+		t.Errorf("Unexpected exclude set %v", scope.Exclude)
+	}
+}
+
+func TestOptions_BindNamespaceScopeFlags_DefaultExclude(t *testing.T) {
+	cmd := &cobra.Command{}
+	options := &Options{}
+
+	options.BindNamespaceScopeFlags(cmd, "kube-system", "kube-public")
+	scope := options.NamespaceScope()
+	if len(scope.Exclude) != 2 || !scope.Exclude.Has("kube-system") || !scope.Exclude.Has("kube-public") {
+		t.Errorf("Unexpected default exclude set %v", scope.Exclude)
+	}
+
+	if err := cmd.Flags().Parse([]string{"--exclude-namespace="}); err != nil {
+		t.Fatal(err)
+	}
+	if scope := options.NamespaceScope(); len(scope.Exclude) != 0 {
+		t.Errorf("Expected an empty exclude set, got %v", scope.Exclude)
+	}
+}
+
+func TestOptions_BindReportFlags(t *testing.T) {
+	cmd := &cobra.Command{}
+	options := &Options{}
+
+	options.BindReportFlags(cmd)
+	if options.ReportOnly() || options.FailOnFindings() {
+		t.Errorf("Expected report flags to be disabled by default")
+	}
+	if err := cmd.Flags().Parse([]string{"--report-only", "--fail-on-findings"}); err != nil {
+		t.Fatal(err)
+	}
+	if !options.ReportOnly() || !options.FailOnFindings() {
+		t.Errorf("Expected report flags to be enabled")
+	}
+}
+
+func TestOptions_CallTimeout(t *testing.T) {
+	cmd := &cobra.Command{}
+	options := &Options{}
+
+	options.BindCallTimeoutFlags(cmd)
+	if got, err := options.CallTimeout(); err != nil || got != 0 {
+		t.Errorf("Expected no call timeout by default, but got %v, %v", got, err)
+	}
+	if err := cmd.Flags().Parse([]string{"--call-timeout=30s"}); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := options.CallTimeout(); err != nil || got != 30*time.Second {
+		t.Errorf("Expected 30s, but got %v, %v", got, err)
+	}
+	if err := cmd.Flags().Parse([]string{"--call-timeout=-1s"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := options.CallTimeout(); err == nil {
+		t.Errorf("Expected an error with a negative call timeout")
+	}
+}
+
+func TestOptions_Targets(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	cmd := &cobra.Command{}
+	options := &Options{}
+	options.BindFromFileFlags(cmd)
+	path := write("names", "# curated\nweb\n\n  api  \nweb\n")
+	if err := cmd.Flags().Parse([]string{"--from-file=" + path}); err != nil {
+		t.Fatal(err)
+	}
+	targets, err := options.Targets([]string{"db", "api"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(targets, ","); got != "db,api,web" {
+		t.Errorf("Unexpected targets %s", got)
+	}
+
+	// Without the file, the arguments are returned as they are.
+	targets, err = (&Options{}).Targets([]string{"db"})
+	if err != nil || len(targets) != 1 || targets[0] != "db" {
+		t.Errorf("Unexpected targets %v, error %v", targets, err)
+	}
+
+	invalid := []string{
+		write("invalid", "web\nInvalid_Name\n"),
+		filepath.Join(dir, "missing"),
+		dir,
+	}
+	for _, p := range invalid {
+		if _, err := (&Options{fromFile: p}).Targets(nil); err == nil {
+			t.Errorf("Expected an error for %s", p)
+		}
+	}
+
+	var many []string
+	for i := 0; i <= DefaultMaxTargets; i++ {
+		many = append(many, fmt.Sprintf("app-%d", i))
+	}
+	if _, err := (&Options{fromFile: write("many", strings.Join(many[1:], "\n"))}).Targets(many[:1]); err == nil {
+		t.Error("Expected an error for too many targets")
+	}
+}
+
+func TestOptions_MaxTargets(t *testing.T) {
+	if got, err := (&Options{}).MaxTargets(); err != nil || got != DefaultMaxTargets {
+		t.Errorf("Expected the default %d, but got %d, %v", DefaultMaxTargets, got, err)
+	}
+
+	tests := []struct {
+		args    []string
+		want    int
+		wantErr bool
+	}{
+		{nil, DefaultMaxTargets, false},
+		{[]string{"--max-targets=200"}, 200, false},
+		{[]string{fmt.Sprintf("--max-targets=%d", MaxTargetsCeiling)}, MaxTargetsCeiling, false},
+		{[]string{fmt.Sprintf("--max-targets=%d", MaxTargetsCeiling+1)}, 0, true},
+		{[]string{"--max-targets=0"}, 0, true},
+	}
+	for _, tt := range tests {
+		cmd := &cobra.Command{}
+		options := &Options{}
+		options.BindMaxTargetsFlags(cmd)
+		if err := cmd.Flags().Parse(tt.args); err != nil {
+			t.Fatal(err)
+		}
+		got, err := options.MaxTargets()
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("MaxTargets() with %v = %d, %v, want %d, wantErr %v", tt.args, got, err, tt.want, tt.wantErr)
+		}
+		if _, err := options.Targets([]string{"app"}); (err != nil) != tt.wantErr {
+			t.Errorf("Targets() with %v error = %v, wantErr %v", tt.args, err, tt.wantErr)
+		}
+	}
+
+	many := make([]string, DefaultMaxTargets+1)
+	for i := range many {
+		many[i] = fmt.Sprintf("app-%d", i)
+	}
+	options := &Options{maxTargets: len(many), maxTargetsSet: true}
+	if got, err := options.Targets(many); err != nil || len(got) != len(many) {
+		t.Errorf("Expected %d targets, but got %d, %v", len(many), len(got), err)
+	}
+}
@@ -0,0 +1,59 @@
+/*
+MIT License
+
+Copyright (c) 2024 Norihiro Seto
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package output
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Exit codes of the commands.
+const (
+	// ExitFailure is returned when a command fails.
+	ExitFailure = 1
+	// ExitFindings is returned when a command with --fail-on-findings finds something to clean up.
+	ExitFindings = 3
+)
+
+// ErrFindings indicates that a command found targets to report, e.g. evicted pods or
+// imbalanced replicasets, and was asked to fail on them.
+var ErrFindings = errors.New("findings exist")
+
+// Findings returns an error wrapping ErrFindings that describes the number of findings.
+func Findings(n int, what string) error {
+	return fmt.Errorf("%d %s found: %w", n, what, ErrFindings)
+}
+
+// ExitCode returns the process exit code for the error of a command.
+func ExitCode(err error) int {
+	switch {
+	case err == nil:
+		return 0
+	case errors.Is(err, ErrFindings):
+		return ExitFindings
+	default:
+		return ExitFailure
+	}
+}
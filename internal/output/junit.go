@@ -0,0 +1,109 @@
+/*
+MIT License
+
+Copyright (c) 2024 Norihiro Seto
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package output
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const allNamespaces = "all-namespaces"
+
+// Result represents the outcome of an operation performed in a namespace.
+type Result struct {
+	Namespace string
+	Operation string
+	Err       error
+}
+
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// WriteJUnit writes the results as a JUnit XML document to w.
+// Each result becomes a test case named after its operation and classified by its namespace.
+// Results with a non-nil error are reported as failures.
+func WriteJUnit(w io.Writer, suite string, results []Result) error {
+	ts := junitTestSuite{Name: suite, Tests: len(results)}
+	for _, r := range results {
+		ns := r.Namespace
+		if ns == metav1.NamespaceAll {
+			ns = allNamespaces
+		}
+		tc := junitTestCase{Name: r.Operation, ClassName: ns}
+		if r.Err != nil {
+			ts.Failures++
+			tc.Failure = &junitFailure{Message: r.Err.Error(), Type: "error", Text: r.Err.Error()}
+		}
+		ts.Cases = append(ts.Cases, tc)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(junitTestSuites{Suites: []junitTestSuite{ts}}); err != nil {
+		return fmt.Errorf("failed to encode junit report: %w", err)
+	}
+	return enc.Flush()
+}
+
+// WriteJUnitFile writes the results as a JUnit XML document to the file at path.
+func WriteJUnitFile(path, suite string, results []Result) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create junit report: %w", err)
+	}
+	if err := WriteJUnit(f, suite, results); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,82 @@
+/*
+MIT License
+
+Copyright (c) 2024 Norihiro Seto
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package output
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteJUnit(t *testing.T) {
+	results := []Result{
+		{Namespace: "default", Operation: "clean-evicted"},
+		{Namespace: "kube-system", Operation: "clean-evicted", Err: errors.New("failed to list pods")},
+		{Namespace: "", Operation: "rebalance-pods"},
+	}
+
+	var buf bytes.Buffer
+	err := WriteJUnit(&buf, "watchdogs", results)
+	assert.NoError(t, err)
+
+	var suites junitTestSuites
+	assert.NoError(t, xml.Unmarshal(buf.Bytes(), &suites))
+	assert.Len(t, suites.Suites, 1)
+
+	suite := suites.Suites[0]
+	assert.Equal(t, "watchdogs", suite.Name)
+	assert.Equal(t, 3, suite.Tests)
+	assert.Equal(t, 1, suite.Failures)
+	assert.Len(t, suite.Cases, 3)
+
+	assert.Nil(t, suite.Cases[0].Failure)
+	assert.Equal(t, "default", suite.Cases[0].ClassName)
+	if assert.NotNil(t, suite.Cases[1].Failure) {
+		assert.Equal(t, "failed to list pods", suite.Cases[1].Failure.Message)
+	}
+	assert.Equal(t, allNamespaces, suite.Cases[2].ClassName)
+}
+
+func TestWriteJUnitFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "report.xml")
+	err := WriteJUnitFile(path, "watchdogs", []Result{{Namespace: "default", Operation: "delete-oldest"}})
+	assert.NoError(t, err)
+
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+
+	var suites junitTestSuites
+	assert.NoError(t, xml.Unmarshal(data, &suites))
+	assert.Equal(t, 1, suites.Suites[0].Tests)
+	assert.Equal(t, 0, suites.Suites[0].Failures)
+
+	err = WriteJUnitFile(filepath.Join(t.TempDir(), "missing", "report.xml"), "watchdogs", nil)
+	assert.Error(t, err)
+}
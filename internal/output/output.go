@@ -0,0 +1,122 @@
+/*
+MIT License
+
+Copyright (c) 2024 Norihiro Seto
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package output
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// Output formats.
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+	// FormatTable renders results as aligned columns. Commands without a table fall back to text.
+	FormatTable = "table"
+)
+
+// Options represents the output options of commands.
+type Options struct {
+	format string
+}
+
+// BindPFlags adds the "output" flag to the given FlagSet.
+func (o *Options) BindPFlags(fs *pflag.FlagSet) {
+	fs.StringVarP(&o.format, "output", "o", FormatText, "Output format (one of 'text', 'json' or 'table')")
+}
+
+// Format returns the output format.
+func (o *Options) Format() string {
+	if o.format == "" {
+		return FormatText
+	}
+	return o.format
+}
+
+// Validate checks that the output format is supported.
+func (o *Options) Validate() error {
+	switch o.Format() {
+	case FormatText, FormatJSON, FormatTable:
+		return nil
+	default:
+		return fmt.Errorf("invalid output format %q, must be one of 'text', 'json' or 'table'", o.format)
+	}
+}
+
+type contextKey struct{}
+
+// FromContext retrieves the *Options value from the given context.
+// If the value does not exist, options with the text format are returned.
+func FromContext(ctx context.Context) *Options {
+	if v, ok := ctx.Value(contextKey{}).(*Options); ok && v != nil {
+		return v
+	}
+	return &Options{}
+}
+
+// WithContext sets the options in the given context.
+func WithContext(ctx context.Context, opts *Options) context.Context {
+	return context.WithValue(ctx, contextKey{}, opts)
+}
+
+// ErrorObject is the structured form of a command failure.
+type ErrorObject struct {
+	Command string `json:"command"`
+	Error   string `json:"error"`
+}
+
+// WriteError writes the error of the command as a JSON ErrorObject.
+func WriteError(w io.Writer, command string, err error) error {
+	return json.NewEncoder(w).Encode(ErrorObject{Command: command, Error: err.Error()})
+}
+
+// RouteErrors wraps RunE of the command and all of its subcommands so that
+// a failure is also written to the command output as an ErrorObject when
+// the output format in the command context is json. The error is still returned.
+func RouteErrors(cmd *cobra.Command) {
+	for _, c := range cmd.Commands() {
+		RouteErrors(c)
+	}
+	runE := cmd.RunE
+	if runE == nil {
+		return
+	}
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		opts := FromContext(cmd.Context())
+		err := opts.Validate()
+		if err == nil {
+			err = runE(cmd, args)
+		}
+		if err != nil && opts.Format() == FormatJSON {
+			_ = WriteError(cmd.OutOrStdout(), cmd.CommandPath(), err)
+		}
+		return err
+	}
+}
@@ -0,0 +1,99 @@
+/*
+MIT License
+
+Copyright (c) 2024 Norihiro Seto
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package output
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestCommand(opts *Options, out *bytes.Buffer) *cobra.Command {
+	root := &cobra.Command{Use: "root"}
+	opts.BindPFlags(root.PersistentFlags())
+	root.AddCommand(&cobra.Command{
+		Use: "fail",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return errors.New("something went wrong")
+		},
+	}, &cobra.Command{
+		Use:  "ok",
+		RunE: func(cmd *cobra.Command, args []string) error { return nil },
+	})
+	root.SetOut(out)
+	root.SilenceUsage = true
+	root.SilenceErrors = true
+	root.SetContext(WithContext(context.Background(), opts))
+	RouteErrors(root)
+	return root
+}
+
+func TestRouteErrors(t *testing.T) {
+	t.Run("json failure", func(t *testing.T) {
+		out := &bytes.Buffer{}
+		root := newTestCommand(&Options{}, out)
+		root.SetArgs([]string{"fail", "--output", "json"})
+
+		err := root.Execute()
+		assert.Error(t, err)
+
+		var obj ErrorObject
+		if assert.NoError(t, json.Unmarshal(out.Bytes(), &obj)) {
+			assert.Equal(t, "root fail", obj.Command)
+			assert.Equal(t, "something went wrong", obj.Error)
+		}
+	})
+
+	t.Run("json success", func(t *testing.T) {
+		out := &bytes.Buffer{}
+		root := newTestCommand(&Options{}, out)
+		root.SetArgs([]string{"ok", "-o", "json"})
+
+		assert.NoError(t, root.Execute())
+		assert.Empty(t, out.String())
+	})
+
+	t.Run("text failure", func(t *testing.T) {
+		out := &bytes.Buffer{}
+		root := newTestCommand(&Options{}, out)
+		root.SetArgs([]string{"fail"})
+
+		assert.Error(t, root.Execute())
+		assert.Empty(t, out.String())
+	})
+
+	t.Run("invalid format", func(t *testing.T) {
+		out := &bytes.Buffer{}
+		root := newTestCommand(&Options{}, out)
+		root.SetArgs([]string{"ok", "--output", "yaml"})
+
+		assert.ErrorContains(t, root.Execute(), "invalid output format")
+	})
+}
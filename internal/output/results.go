@@ -0,0 +1,87 @@
+/*
+MIT License
+
+Copyright (c) 2024 Norihiro Seto
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package output
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+)
+
+// Results collects the results of the operations performed during a single command run.
+type Results struct {
+	mu      sync.Mutex
+	results []Result
+}
+
+type resultsKey struct{}
+
+// WithResults returns a new context holding an empty Results.
+// RecordResult called with the returned context records the results of the run.
+// Call it at the start of each command run.
+func WithResults(ctx context.Context) context.Context {
+	return context.WithValue(ctx, resultsKey{}, &Results{})
+}
+
+// resultsFromContext retrieves the *Results from the given context, or nil if absent.
+func resultsFromContext(ctx context.Context) *Results {
+	if v, ok := ctx.Value(resultsKey{}).(*Results); ok {
+		return v
+	}
+	return nil
+}
+
+// RecordResult records the result of an operation performed in a namespace.
+// It does nothing if the context holds no Results. It is safe to call concurrently.
+func RecordResult(ctx context.Context, r Result) {
+	results := resultsFromContext(ctx)
+	if results == nil {
+		return
+	}
+	results.mu.Lock()
+	defer results.mu.Unlock()
+	results.results = append(results.results, r)
+}
+
+// CollectResults returns the results recorded in the context followed by the result of the whole
+// run in the namespace. The result of the run is left out when some results are recorded and the
+// run succeeded, or its error is one of the recorded ones.
+func CollectResults(ctx context.Context, namespace, operation string, err error) []Result {
+	var recorded []Result
+	if results := resultsFromContext(ctx); results != nil {
+		results.mu.Lock()
+		recorded = slices.Clone(results.results)
+		results.mu.Unlock()
+	}
+	run := Result{Namespace: namespace, Operation: operation, Err: err}
+	if len(recorded) == 0 {
+		return []Result{run}
+	}
+	if err == nil || slices.ContainsFunc(recorded, func(r Result) bool { return r.Err != nil && errors.Is(err, r.Err) }) {
+		return recorded
+	}
+	return append(recorded, run)
+}
@@ -0,0 +1,76 @@
+/*
+MIT License
+
+Copyright (c) 2024 Norihiro Seto
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package output
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCollectResults(t *testing.T) {
+	listErr := errors.New("failed to list pods")
+
+	// Without recorded results, the run is the only result.
+	ctx := WithResults(context.Background())
+	assert.Equal(t, []Result{{Namespace: "default", Operation: "scale"}}, CollectResults(ctx, "default", "scale", nil))
+	RecordResult(context.Background(), Result{Namespace: "ignored"})
+
+	RecordResult(ctx, Result{Namespace: "ns-1", Operation: "clean-failed"})
+	RecordResult(ctx, Result{Namespace: "ns-2", Operation: "clean-failed", Err: listErr})
+	RecordResult(ctx, Result{Namespace: "ns-3", Operation: "clean-failed"})
+
+	// The run error is the one of ns-2.
+	results := CollectResults(ctx, "", "clean-failed", fmt.Errorf("namespace ns-2: %w", listErr))
+	assert.Len(t, results, 3)
+
+	var buf bytes.Buffer
+	assert.NoError(t, WriteJUnit(&buf, "watchdogs", results))
+	var suites junitTestSuites
+	assert.NoError(t, xml.Unmarshal(buf.Bytes(), &suites))
+	suite := suites.Suites[0]
+	assert.Equal(t, 3, suite.Tests)
+	assert.Equal(t, 1, suite.Failures)
+	if assert.Len(t, suite.Cases, 3) {
+		assert.Equal(t, "ns-1", suite.Cases[0].ClassName)
+		assert.Nil(t, suite.Cases[0].Failure)
+		assert.Equal(t, "ns-2", suite.Cases[1].ClassName)
+		if assert.NotNil(t, suite.Cases[1].Failure) {
+			assert.Equal(t, listErr.Error(), suite.Cases[1].Failure.Message)
+		}
+		assert.Equal(t, "ns-3", suite.Cases[2].ClassName)
+	}
+
+	// A failure of the run outside the namespaces is added as a case of its own.
+	results = CollectResults(ctx, "", "clean-failed", context.DeadlineExceeded)
+	if assert.Len(t, results, 4) {
+		assert.Equal(t, Result{Operation: "clean-failed", Err: context.DeadlineExceeded}, results[3])
+	}
+}
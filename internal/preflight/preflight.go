@@ -0,0 +1,80 @@
+/*
+MIT License
+
+Copyright (c) 2024 Norihiro Seto
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package preflight
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/norseto/k8s-watchdogs/pkg/logger"
+	"github.com/spf13/pflag"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Options represents the pre-flight options of commands.
+type Options struct {
+	validateOnly bool
+}
+
+// BindPFlags adds the "validate-only" flag to the given FlagSet.
+func (o *Options) BindPFlags(fs *pflag.FlagSet) {
+	fs.BoolVar(&o.validateOnly, "validate-only", false,
+		"Validate flags and the connection to the API server, then exit without changing anything.")
+}
+
+// ValidateOnly returns true if the command should stop after the pre-flight check.
+func (o *Options) ValidateOnly() bool {
+	return o.validateOnly
+}
+
+type contextKey struct{}
+
+// FromContext retrieves the *Options value from the given context.
+// If the value does not exist, options with validate-only disabled are returned.
+func FromContext(ctx context.Context) *Options {
+	if v, ok := ctx.Value(contextKey{}).(*Options); ok && v != nil {
+		return v
+	}
+	return &Options{}
+}
+
+// WithContext returns a new context with the given *Options value.
+func WithContext(ctx context.Context, opts *Options) context.Context {
+	return context.WithValue(ctx, contextKey{}, opts)
+}
+
+// Run checks the connection to the API server when validate-only is enabled in the context.
+// It returns true if the command should exit with the returned error instead of running.
+func Run(ctx context.Context, client kubernetes.Interface) (bool, error) {
+	if !FromContext(ctx).ValidateOnly() {
+		return false, nil
+	}
+	ver, err := client.Discovery().ServerVersion()
+	if err != nil {
+		return true, fmt.Errorf("failed to get server version: %w", err)
+	}
+	logger.FromContext(ctx).Info("validation succeeded", "serverVersion", ver.GitVersion)
+	return true, nil
+}
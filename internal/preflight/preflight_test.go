@@ -0,0 +1,84 @@
+/*
+MIT License
+
+Copyright (c) 2024 Norihiro Seto
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package preflight
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestRun(t *testing.T) {
+	t.Run("disabled", func(t *testing.T) {
+		client := fake.NewSimpleClientset()
+		done, err := Run(context.Background(), client)
+		assert.False(t, done)
+		assert.NoError(t, err)
+		assert.Empty(t, client.Actions())
+	})
+
+	t.Run("validate only", func(t *testing.T) {
+		client := fake.NewSimpleClientset()
+		ctx := WithContext(context.Background(), &Options{validateOnly: true})
+		done, err := Run(ctx, client)
+		assert.True(t, done)
+		assert.NoError(t, err)
+
+		versionCalled := false
+		for _, a := range client.Actions() {
+			assert.NotContains(t, []string{"create", "update", "patch", "delete"}, a.GetVerb())
+			if a.GetVerb() == "get" && a.GetResource().Resource == "version" {
+				versionCalled = true
+			}
+		}
+		assert.True(t, versionCalled, "server version should be requested")
+	})
+
+	t.Run("unreachable server", func(t *testing.T) {
+		client := fake.NewSimpleClientset()
+		client.PrependReactor("get", "version", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			return true, nil, errors.New("connection refused")
+		})
+		ctx := WithContext(context.Background(), &Options{validateOnly: true})
+		done, err := Run(ctx, client)
+		assert.True(t, done)
+		assert.Error(t, err)
+	})
+}
+
+func TestOptions_BindPFlags(t *testing.T) {
+	opts := &Options{}
+	fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	opts.BindPFlags(fs)
+	assert.NoError(t, fs.Parse([]string{"--validate-only"}))
+	assert.True(t, opts.ValidateOnly())
+	assert.False(t, FromContext(context.Background()).ValidateOnly())
+}
@@ -26,13 +26,18 @@ package rebalancer
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sort"
+	"time"
 
+	"github.com/norseto/k8s-watchdogs/internal/concurrent"
 	"github.com/norseto/k8s-watchdogs/pkg/generics"
 	"github.com/norseto/k8s-watchdogs/pkg/kube"
 	"github.com/norseto/k8s-watchdogs/pkg/logger"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
 	k8s "k8s.io/client-go/kubernetes"
 )
 
@@ -57,6 +62,33 @@ type PodStatus struct {
 type Rebalancer struct {
 	current          *ReplicaState
 	maxRebalanceRate float32
+	maxPerNode       int
+	respectAntiAff   bool
+	defaultRequest   corev1.ResourceList
+	deleteRetries    int
+	preferPressured  bool
+	checkHeadroom    bool
+	threshold        float32
+	tolerance        int
+	pacer            *concurrent.Pacer
+	pdbs             *kube.PDBCache
+	weights          map[string]float64
+	verifyTimeout    time.Duration
+	callTimeout      time.Duration
+	countedReplicas  bool
+	command          string
+}
+
+// Option configures a Rebalancer.
+type Option func(*Rebalancer)
+
+// WithMaxPerNode sets the maximum number of pods of the replica set on a single node.
+// Pods over the cap are deleted even if the node is within the average-based limit.
+// The rebalance rate still caps the total number of deletions. 0 disables the cap.
+func WithMaxPerNode(max int) Option {
+	return func(r *Rebalancer) {
+		r.maxPerNode = max
+	}
 }
 
 // specReplicas returns the number of replicas specified in the current ReplicaSet.
@@ -67,6 +99,18 @@ func (r *Rebalancer) specReplicas() int32 {
 	return *r.current.Replicaset.Spec.Replicas
 }
 
+// balancedReplicas returns the number of the replicas balanced across the nodes, that is the spec
+// replicas, or the pods in the replica state, including the deleted ones, with WithCountedReplicas.
+func (r *Rebalancer) balancedReplicas() int32 {
+	if !r.countedReplicas {
+		return r.specReplicas()
+	}
+	if r.current == nil {
+		return 0
+	}
+	return int32(len(generics.Filter(r.current.PodStatus, func(s *PodStatus) bool { return s != nil && s.Pod != nil })))
+}
+
 // currentReplicas returns the number of replicas currently running in the ReplicaSet.
 func (r *Rebalancer) currentReplicas() int32 {
 	if r.current == nil || r.current.Replicaset == nil {
@@ -82,7 +126,7 @@ func (r *Rebalancer) currentReplicas() int32 {
 // It assigns the first Pod from current.PodStatus to the variable "Pod".
 // If "Pod" is nil, it returns the original list of Nodes.
 // It calls the k8sutils.FilterScheduleable function to filter the list of Nodes based on the Pod.Spec.
-// It assigns the filtered list to the current.Nodes.
+// It assigns the filtered list to the current.Nodes. Nil nodes are dropped.
 func (r *Rebalancer) filterSchedulables(ctx context.Context) {
 	if r.current == nil || len(r.current.PodStatus) < 1 {
 		return
@@ -92,15 +136,18 @@ func (r *Rebalancer) filterSchedulables(ctx context.Context) {
 		return
 	}
 
-	res := kube.GetPodRequestResources(firstPod.Spec)
+	res := kube.GetPodRequestResourcesWithDefault(firstPod.Spec, r.defaultRequest)
 	logger.FromContext(ctx).V(1).Info("Pod requests", "name", firstPod.Name,
 		"cpu", res.Cpu(), "mem", res.Memory())
 
-	schedulables := kube.FilterScheduleable(r.current.Nodes, &firstPod.Spec)
-	r.current.Nodes = mergeNodes(schedulables, r.current.Nodes, r.current.PodStatus)
+	nodes := generics.Filter(r.current.Nodes, func(n *corev1.Node) bool { return n != nil })
+	schedulables := kube.FilterScheduleableWithDefaultRequest(nodes, &firstPod.Spec, r.defaultRequest)
+	r.current.Nodes = mergeNodes(ctx, schedulables, nodes, r.current.PodStatus)
 }
 
-func mergeNodes(origin, nodes []*corev1.Node, podState []*PodStatus) []*corev1.Node {
+// mergeNodes appends the nodes running the pods to the origin nodes when they are missing there.
+// Pods whose node is not found, e.g. the node was just deleted, are skipped.
+func mergeNodes(ctx context.Context, origin, nodes []*corev1.Node, podState []*PodStatus) []*corev1.Node {
 	originMap := toNodeMap(origin)
 	result := origin
 	nodeMap := toNodeMap(nodes)
@@ -110,9 +157,15 @@ func mergeNodes(origin, nodes []*corev1.Node, podState []*PodStatus) []*corev1.N
 			continue
 		}
 		name := pod.Pod.Spec.NodeName
-		if _, ok := originMap[name]; !ok {
-			result = append(result, nodeMap[name])
+		if _, ok := originMap[name]; ok {
+			continue
 		}
+		node, ok := nodeMap[name]
+		if !ok {
+			logger.FromContext(ctx).V(1).Info("node of the pod not found, skip", "pod", pod.Pod.Name, "node", name)
+			continue
+		}
+		result = append(result, node)
 	}
 	return result
 }
@@ -120,16 +173,161 @@ func mergeNodes(origin, nodes []*corev1.Node, podState []*PodStatus) []*corev1.N
 // toNodeMap returns a map that maps the name of a node to the node object itself.
 // It takes in a slice of nodes and iterates through each node, populating the map
 // with the node's name as the key and the node object as the value.
-// It then returns the resulting map.
+// It then returns the resulting map. Nil nodes are ignored.
 func toNodeMap(nodes []*corev1.Node) map[string]*corev1.Node {
-	return generics.MakItemMap(nodes, func(node *corev1.Node) string { return node.Name })
+	return generics.MakeMap(nodes, func(node *corev1.Node) string { return node.Name },
+		func(node, _ *corev1.Node) *corev1.Node { return node },
+		func(node *corev1.Node) bool { return node != nil })
+}
+
+// WithRespectAntiAffinity makes the Rebalancer skip deleting a pod when no other schedulable
+// node satisfies the pod's required pod anti-affinity against the already placed pods.
+func WithRespectAntiAffinity(respect bool) Option {
+	return func(r *Rebalancer) {
+		r.respectAntiAff = respect
+	}
+}
+
+// WithDefaultRequest sets the resource requests assumed for pods that do not request them
+// when checking whether nodes can schedule the pods. nil keeps the requests as declared.
+func WithDefaultRequest(request corev1.ResourceList) Option {
+	return func(r *Rebalancer) {
+		r.defaultRequest = request
+	}
+}
+
+// WithDeleteRetries sets the number of retries of a pod deletion that failed with a
+// conflict or server error. 0 makes a single attempt.
+func WithDeleteRetries(retries int) Option {
+	return func(r *Rebalancer) {
+		r.deleteRetries = retries
+	}
+}
+
+// WithPreferPressuredNodes makes the rebalancer evacuate pods from nodes under memory or
+// disk pressure first, even if the nodes are not the most populated or within the average.
+// The rebalance rate still caps the total number of deletions.
+func WithPreferPressuredNodes(prefer bool) Option {
+	return func(r *Rebalancer) {
+		r.preferPressured = prefer
+	}
+}
+
+// WithCheckHeadroom makes the Rebalancer skip deleting a pod when no other schedulable node
+// has enough free allocatable cpu and memory for the pod. The free capacity is the node
+// allocatable minus the requests of the pods of the replica set that are placed on the node.
+func WithCheckHeadroom(check bool) Option {
+	return func(r *Rebalancer) {
+		r.checkHeadroom = check
+	}
+}
+
+// WithThreshold sets the slack over the average number of pods per node. Pods are deleted from
+// the most populated node while its pod count is over the average and at least the average plus the threshold.
+// Negative values are ignored and DefaultThreshold is used.
+func WithThreshold(threshold float32) Option {
+	return func(r *Rebalancer) {
+		if threshold >= 0 {
+			r.threshold = threshold
+		}
+	}
+}
+
+// WithReplicaTolerance sets the spread of pods per node, the difference between the most and
+// the least populated nodes, that is tolerated without trimming pods over the average.
+// With WithNodeWeights, the spread is measured from the weighted shares of the nodes.
+// Pods over the per node cap or on pressured nodes are deleted regardless. 0 tolerates no spread.
+func WithReplicaTolerance(tolerance int) Option {
+	return func(r *Rebalancer) {
+		r.tolerance = tolerance
+	}
 }
 
+// WithPacer sets the pacer that spaces out the pod deletions.
+// The same pacer can be shared by rebalancers to space out deletions across replica sets.
+// nil deletes pods without waiting.
+func WithPacer(pacer *concurrent.Pacer) Option {
+	return func(r *Rebalancer) {
+		r.pacer = pacer
+	}
+}
+
+// WithPDBCache skips pods whose PodDisruptionBudgets currently disallow a disruption.
+// The same cache can be shared by rebalancers so that budgets are listed once per namespace.
+// nil does not check the budgets.
+func WithPDBCache(pdbs *kube.PDBCache) Option {
+	return func(r *Rebalancer) {
+		r.pdbs = pdbs
+	}
+}
+
+// WithNodeWeights makes the Rebalancer normalize the pod counts by the weights of the nodes,
+// keyed by the node names, so that bigger nodes legitimately host more pods. The most loaded node
+// is the one with the most pods per weight, and its share of the replicas is proportional to its weight.
+// Nodes without a positive weight are weighted 1. nil balances the raw pod counts.
+func WithNodeWeights(weights map[string]float64) Option {
+	return func(r *Rebalancer) {
+		r.weights = weights
+	}
+}
+
+// WithVerify makes the Rebalancer wait up to the timeout after each deletion for a new pod of the
+// replica set to become ready before deleting the next pod. When no replacement becomes ready,
+// further deletions are aborted with ErrReplacementNotReady. 0 disables the verification.
+func WithVerify(timeout time.Duration) Option {
+	return func(r *Rebalancer) {
+		r.verifyTimeout = timeout
+	}
+}
+
+// WithCallTimeout bounds each API call of the Rebalancer, e.g. a pod deletion including its retries,
+// by the timeout. 0 disables it.
+func WithCallTimeout(timeout time.Duration) Option {
+	return func(r *Rebalancer) {
+		r.callTimeout = timeout
+	}
+}
+
+// WithCountedReplicas makes the Rebalancer derive the expected pods per node from the pods in the
+// replica state instead of the spec replicas. Use it when pods are left out of the state, e.g. the
+// pods on nodes out of a node selector, so that they do not inflate the average.
+func WithCountedReplicas(counted bool) Option {
+	return func(r *Rebalancer) {
+		r.countedReplicas = counted
+	}
+}
+
+// WithCommand sets the command name recorded in the audit log entries of the pod deletions.
+// It is DefaultCommand unless set.
+func WithCommand(name string) Option {
+	return func(r *Rebalancer) {
+		if name != "" {
+			r.command = name
+		}
+	}
+}
+
+// ErrReplacementNotReady is returned when the replacement of a deleted pod did not become ready
+// within the timeout set with WithVerify, e.g. because the cluster is full.
+var ErrReplacementNotReady = errors.New("replacement pod did not become ready")
+
+// verifyInterval is the interval of polling the replacement of a deleted pod.
+const verifyInterval = 2 * time.Second
+
+// DefaultThreshold is the default slack over the average number of pods per node.
+const DefaultThreshold = 1.0
+
+// DefaultCommand is the default command name recorded in the audit log entries.
+const DefaultCommand = "rebalancer"
+
 // NewRebalancer returns a new instance of the Rebalancer struct with the provided current
-// replica state and a default maxRebalanceRate of 0.25.
+// replica state, a default maxRebalanceRate of 0.25, DefaultThreshold and DefaultCommand.
 // The Rebalancer struct contains methods for rebalancing pods across Nodes in a Kubernetes cluster.
-func NewRebalancer(ctx context.Context, current *ReplicaState) *Rebalancer {
-	ret := &Rebalancer{current: current, maxRebalanceRate: .25}
+func NewRebalancer(ctx context.Context, current *ReplicaState, opts ...Option) *Rebalancer {
+	ret := &Rebalancer{current: current, maxRebalanceRate: .25, threshold: DefaultThreshold, command: DefaultCommand}
+	for _, opt := range opts {
+		opt(ret)
+	}
 	ret.filterSchedulables(ctx)
 	return ret
 }
@@ -137,12 +335,47 @@ func NewRebalancer(ctx context.Context, current *ReplicaState) *Rebalancer {
 // Rebalance rebalances the pods across the Nodes in the cluster.
 // It returns a boolean indicating if any pods were rebalanced and an error, if any.
 // The rebalancing is done by deleting pods from the Node that has the maximum number of pods
-// until the Pod count on that Node is less than or equal to the average number of pods across all Nodes plus one.
+// until the Pod count on that Node is at most the average number of pods across all Nodes
+// or less than the average plus the threshold, which is one unless set with WithThreshold.
+// If a per node cap is set with WithMaxPerNode, pods over the cap are deleted as well.
 // The maximum number of pods to be deleted is calculated based on the specified rebalance rate.
 // If the number of Nodes is less than 2, the number of replicas is less than 2,
 // or the current number of replicas is less than the specified replicas,
 // no rebalancing is performed and the function returns false.
 func (r *Rebalancer) Rebalance(ctx context.Context, client k8s.Interface) (bool, error) {
+	return r.RebalanceWithReport(ctx, client, nil)
+}
+
+// RebalanceWithReport rebalances the pods across the Nodes in the cluster like Rebalance.
+// If report is not nil, the pods per node before and after rebalancing and the names of
+// the deleted pods are appended to it.
+func (r *Rebalancer) RebalanceWithReport(ctx context.Context, client k8s.Interface, report *RebalanceReport) (bool, error) {
+	if report != nil {
+		before := r.countPodsPerNode()
+		defer func() { report.add(r.makeReport(before)) }()
+	}
+	return r.rebalance(ctx, client)
+}
+
+// makeReport makes a ReplicaSetReport of the current state.
+func (r *Rebalancer) makeReport(before map[string]int) ReplicaSetReport {
+	ret := ReplicaSetReport{Before: before, After: r.countPodsPerNode()}
+	nodes := r.nodeNames()
+	ret.BeforeCV = coefficientOfVariation(ret.Before, nodes)
+	ret.AfterCV = coefficientOfVariation(ret.After, nodes)
+	if rs := r.current.Replicaset; rs != nil {
+		ret.Namespace, ret.Name = rs.Namespace, rs.Name
+	}
+	for _, s := range r.current.PodStatus {
+		if s != nil && s.deleted && s.Pod != nil {
+			ret.Deleted = append(ret.Deleted, s.Pod.Name)
+		}
+	}
+	return ret
+}
+
+// rebalance deletes pods from the Node that has the maximum number of pods.
+func (r *Rebalancer) rebalance(ctx context.Context, client k8s.Interface) (bool, error) {
 	nodeCount := len(r.current.Nodes)
 	sr := r.specReplicas()
 
@@ -157,6 +390,9 @@ func (r *Rebalancer) Rebalance(ctx context.Context, client k8s.Interface) (bool,
 	}
 
 	for i := 0; i < maxDel; i++ {
+		if err := ctx.Err(); err != nil {
+			return deleted > 0, err
+		}
 		node, num := r.getNodeWithMaxPods()
 		for _, n := range r.current.Nodes {
 			capacity, err := kube.GetNodeResourceCapacity(n)
@@ -169,60 +405,275 @@ func (r *Rebalancer) Rebalance(ctx context.Context, client k8s.Interface) (bool,
 			return deleted > 0, nil
 		}
 
-		ave := float32(sr) / float32(nodeCount)
-		if len(node) <= 0 || float32(num) < ave+1.0 {
+		ave := r.expectedPods(node, r.balancedReplicas())
+		overCap := r.maxPerNode > 0 && num > r.maxPerNode
+		pressured := r.preferPressured && kube.IsNodeUnderPressure(r.findNode(node))
+		balanced := float32(num) <= ave || float32(num) < ave+r.threshold || r.current.WeightedPodSpread(r.weights) <= float64(r.tolerance)
+		if len(node) <= 0 || (balanced && !overCap && !pressured) {
 			return deleted > 0, nil
 		}
-		if err := r.deletePodOnNode(ctx, client, node); err != nil {
+		var known map[types.UID]bool
+		if r.verifyTimeout > 0 && r.current.Replicaset != nil {
+			var uids map[types.UID]bool
+			err := concurrent.CallWithTimeout(ctx, r.callTimeout, func(ctx context.Context) (err error) {
+				uids, err = kube.ReplicaSetPodUIDs(ctx, client, r.current.Replicaset)
+				return err
+			})
+			if err != nil {
+				return deleted > 0, fmt.Errorf("failed to list pods to verify: %v", err)
+			}
+			known = uids
+		}
+		ok, err := r.deletePodOnNode(ctx, client, node)
+		if err != nil {
 			return deleted > 0, fmt.Errorf("failed to delete Pod: %v", err)
 		}
+		if !ok {
+			return deleted > 0, nil
+		}
 		deleted++
+		if err := r.verifyReplacement(ctx, client, known); err != nil {
+			return true, err
+		}
 	}
 
 	return deleted > 0, nil
 }
 
-// deletePodOnNode deletes a Pod on specified Node.
-func (r *Rebalancer) deletePodOnNode(ctx context.Context, client k8s.Interface, node string) error {
+// deletePodOnNode deletes a ready Pod on specified Node.
+// Pods that are not ready are only counted and never deleted.
+// It returns false if there is no Pod to delete on the Node.
+// It never deletes the last ready pod of the replica set regardless of the spec replicas,
+// which guards against races with controllers scaling down to one.
+func (r *Rebalancer) deletePodOnNode(ctx context.Context, client k8s.Interface, node string) (bool, error) {
 	log := logger.FromContext(ctx)
+	if ready := r.countReadyPods(); ready <= 1 {
+		log.Info("protecting the last ready pod, skip", "node", node, "ready", ready)
+		return false, nil
+	}
 	l := len(r.current.PodStatus)
 	for i := 0; i < l; i++ {
 		s := r.current.PodStatus[i]
-		if s.deleted || s.Pod == nil {
+		if s.deleted || s.Pod == nil || !kube.IsPodReadyRunning(*s.Pod) {
 			continue
 		}
 		if s.Pod.Spec.NodeName == node {
+			if r.respectAntiAff && !r.canReschedule(s.Pod) {
+				log.V(1).Info("no other node satisfies pod anti-affinity, skip", "node", node, "pod", s.Pod.Name)
+				continue
+			}
+			if r.checkHeadroom && !r.hasHeadroom(s.Pod) {
+				log.V(1).Info("no other node has headroom for the pod, skip", "node", node, "pod", s.Pod.Name)
+				continue
+			}
 			log.V(1).Info("deleting pod on node", "node", node, "pod", s.Pod.Name)
+			if r.pdbs != nil {
+				allowed, err := r.pdbs.DisruptionAllowed(ctx, s.Pod)
+				if err != nil {
+					return false, err
+				}
+				if !allowed {
+					log.V(1).Info("pod disruption budget disallows a disruption, skip", "node", node, "pod", s.Pod.Name)
+					continue
+				}
+			}
+			if err := r.pacer.Wait(ctx); err != nil {
+				return false, err
+			}
 			s.deleted = true
-			return kube.DeletePod(ctx, client, *s.Pod)
+			kube.AuditPodDeletion(ctx, s.Pod, "rebalance", r.command)
+			err := concurrent.CallWithTimeout(ctx, r.callTimeout, func(ctx context.Context) error {
+				return kube.DeletePodWithRetry(ctx, client, *s.Pod, r.deleteRetries+1, kube.DefaultDeleteRetryBackoff)
+			})
+			if err != nil {
+				return true, err
+			}
+			if r.pdbs != nil {
+				return true, r.pdbs.RecordDisruption(ctx, s.Pod)
+			}
+			return true, nil
 		}
 	}
+	return false, nil
+}
+
+// verifyReplacement waits for a pod of the replica set that is not known, that is did not exist
+// before the deletion, to become ready when enabled with WithVerify.
+// It returns an error wrapping ErrReplacementNotReady on timeout.
+func (r *Rebalancer) verifyReplacement(ctx context.Context, client k8s.Interface, known map[types.UID]bool) error {
+	if r.verifyTimeout <= 0 || r.current.Replicaset == nil {
+		return nil
+	}
+	vctx, cancel := context.WithTimeout(ctx, r.verifyTimeout)
+	defer cancel()
+	if err := kube.WaitForReplacementPod(vctx, client, r.current.Replicaset, known, verifyInterval); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return fmt.Errorf("%w within %v: %v", ErrReplacementNotReady, r.verifyTimeout, err)
+	}
+	logger.FromContext(ctx).V(1).Info("replacement pod is ready", "rs", r.current.Replicaset.Name)
 	return nil
 }
 
+// countReadyPods returns the number of non-deleted ready pods of the replica set.
+func (r *Rebalancer) countReadyPods() int {
+	count := 0
+	for _, s := range r.current.PodStatus {
+		if s != nil && !s.deleted && s.Pod != nil && kube.IsPodReadyRunning(*s.Pod) {
+			count++
+		}
+	}
+	return count
+}
+
+// canReschedule checks if there is another schedulable node that satisfies the
+// required pod anti-affinity of the pod against the pods that are not deleted.
+func (r *Rebalancer) canReschedule(pod *corev1.Pod) bool {
+	placed := generics.Convert(r.current.PodStatus,
+		func(s *PodStatus) *corev1.Pod { return s.Pod },
+		func(s *PodStatus) bool { return s != nil && !s.deleted && s.Pod != nil })
+	for _, n := range kube.FilterScheduleableWithDefaultRequest(r.current.Nodes, &pod.Spec, r.defaultRequest) {
+		if n.Name != pod.Spec.NodeName && kube.SatisfiesPodAntiAffinity(pod, n, placed, r.current.Nodes) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasHeadroom checks if there is another schedulable node whose allocatable cpu and memory
+// minus the requests of the pods that are not deleted can fit the requests of the pod.
+func (r *Rebalancer) hasHeadroom(pod *corev1.Pod) bool {
+	request := kube.GetPodRequestResourcesWithDefault(pod.Spec, r.defaultRequest)
+	used := r.requestsPerNode()
+	for _, n := range kube.FilterScheduleableWithDefaultRequest(r.current.Nodes, &pod.Spec, r.defaultRequest) {
+		if n.Name == pod.Spec.NodeName {
+			continue
+		}
+		capacity, err := kube.GetNodeResourceCapacity(n)
+		if err != nil {
+			continue
+		}
+		if fits(capacity, used[n.Name], request, corev1.ResourceCPU) &&
+			fits(capacity, used[n.Name], request, corev1.ResourceMemory) {
+			return true
+		}
+	}
+	return false
+}
+
+// fits checks if the capacity minus the used amount of the resource is at least the requested amount.
+func fits(capacity, used, request corev1.ResourceList, name corev1.ResourceName) bool {
+	free := capacity[name]
+	if u, ok := used[name]; ok {
+		free.Sub(u)
+	}
+	return free.Cmp(request[name]) >= 0
+}
+
+// requestsPerNode returns the sum of the requests of the non-deleted pods per node.
+func (r *Rebalancer) requestsPerNode() map[string]corev1.ResourceList {
+	return generics.Reduce(r.current.PodStatus, map[string]corev1.ResourceList{},
+		func(ret map[string]corev1.ResourceList, s *PodStatus) map[string]corev1.ResourceList {
+			if s == nil || s.deleted || s.Pod == nil {
+				return ret
+			}
+			name := s.Pod.Spec.NodeName
+			if ret[name] == nil {
+				ret[name] = corev1.ResourceList{}
+			}
+			for rn, q := range kube.GetPodRequestResourcesWithDefault(s.Pod.Spec, r.defaultRequest) {
+				sum := ret[name][rn]
+				sum.Add(q)
+				ret[name][rn] = sum
+			}
+			return ret
+		})
+}
+
 // getNodeWithMaxPods returns the Node with the maximum number of non-deleted pods and the corresponding Pod count.
+// Ties are broken by the node name so that the selection is deterministic.
+// With WithPreferPressuredNodes, the most populated node under pressure that has pods is returned first.
+// Nil nodes are ignored.
 func (r *Rebalancer) getNodeWithMaxPods() (string, int) {
 	if r.current == nil {
 		return "", 0
 	}
 
 	podCounts := r.countPodsPerNode()
+	sorted := kube.SortNodesByPodCount(r.current.Nodes, podCounts)
+	if r.weights != nil {
+		sorted = r.sortNodesByLoad(podCounts)
+	}
+	if r.preferPressured {
+		for _, n := range sorted {
+			if podCounts[n.Name] > 0 && kube.IsNodeUnderPressure(n) {
+				return n.Name, podCounts[n.Name]
+			}
+		}
+	}
+	if len(sorted) < 1 || podCounts[sorted[0].Name] < 1 {
+		return "", 0
+	}
+	return sorted[0].Name, podCounts[sorted[0].Name]
+}
 
-	maxPods := 0
-	nodeNameWithMaxPods := ""
-	for nodeName, podCount := range podCounts {
-		if podCount > maxPods {
-			maxPods = podCount
-			nodeNameWithMaxPods = nodeName
+// sortNodesByLoad returns the non-nil nodes sorted by the pods per weight in descending order.
+// Ties are broken by the node name.
+func (r *Rebalancer) sortNodesByLoad(counts map[string]int) []*corev1.Node {
+	sorted := generics.Filter(r.current.Nodes, func(n *corev1.Node) bool { return n != nil })
+	load := func(n *corev1.Node) float64 { return float64(counts[n.Name]) / r.weight(n.Name) }
+	sort.SliceStable(sorted, func(i, j int) bool {
+		li, lj := load(sorted[i]), load(sorted[j])
+		if li != lj {
+			return li > lj
 		}
+		return sorted[i].Name < sorted[j].Name
+	})
+	return sorted
+}
+
+// weight returns the weight of the node, which is 1 unless a positive weight is set.
+func (r *Rebalancer) weight(node string) float64 {
+	if w := r.weights[node]; w > 0 {
+		return w
 	}
-	return nodeNameWithMaxPods, maxPods
+	return 1
+}
+
+// expectedPods returns the number of the replicas the node is expected to host, that is
+// the average per node, or the share proportional to the node weight with WithNodeWeights.
+func (r *Rebalancer) expectedPods(node string, replicas int32) float32 {
+	if r.weights == nil {
+		return float32(replicas) / float32(len(r.current.Nodes))
+	}
+	total := generics.Reduce(r.current.Nodes, 0.0, func(total float64, n *corev1.Node) float64 {
+		if n == nil {
+			return total
+		}
+		return total + r.weight(n.Name)
+	})
+	return float32(float64(replicas) * r.weight(node) / total)
+}
+
+// findNode returns the Node with the name in the current replica state or nil if not found.
+func (r *Rebalancer) findNode(name string) *corev1.Node {
+	node, _ := generics.Find(r.current.Nodes, func(n *corev1.Node) bool { return n != nil && n.Name == name })
+	return node
+}
+
+// nodeNames returns the names of the nodes in the current replica state.
+func (r *Rebalancer) nodeNames() []string {
+	names := make([]string, 0, len(r.current.Nodes))
+	for _, n := range r.current.Nodes {
+		if n != nil {
+			names = append(names, n.Name)
+		}
+	}
+	return names
 }
 
 // countPodsPerNode returns a map containing the count of pods per Node in the current replica state.
 func (r *Rebalancer) countPodsPerNode() map[string]int {
-	return generics.MakeMap(r.current.PodStatus,
-		func(s *PodStatus) string { return s.Pod.Spec.NodeName },
-		func(s *PodStatus, v int) int { return v + 1 },
-		func(s *PodStatus) bool { return s != nil && !s.deleted && s.Pod != nil })
+	return r.current.PodsPerNode()
 }
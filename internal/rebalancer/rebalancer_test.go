@@ -25,16 +25,27 @@ SOFTWARE.
 package rebalancer
 
 import (
+	"bytes"
 	"context"
+	"fmt"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/go-logr/logr/funcr"
+	"github.com/norseto/k8s-watchdogs/pkg/kube"
+	"github.com/norseto/k8s-watchdogs/pkg/logger"
 	"github.com/stretchr/testify/assert"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
 
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
 )
 
 func TestSpecReplicas(t *testing.T) {
@@ -165,6 +176,35 @@ func TestFilterSchedulables(t *testing.T) {
 	}
 }
 
+func TestFilterSchedulables_VanishedNode(t *testing.T) {
+	replicaState := &ReplicaState{
+		PodStatus: []*PodStatus{
+			{Pod: pod("pod-1", "node-1")},
+			{Pod: pod("pod-2", "node-2")},
+			{Pod: pod("pod-3", "node-gone")},
+		},
+		Nodes: []*corev1.Node{
+			node("node-1"),
+			nil,
+			node("node-2", func(n *corev1.Node) {
+				n.Spec.Unschedulable = true
+			}),
+		},
+	}
+	rebalancer := &Rebalancer{
+		current: replicaState,
+	}
+
+	rebalancer.filterSchedulables(context.TODO())
+
+	// The unschedulable node running a pod is kept, while the vanished node is skipped.
+	assert.Equal(t, []string{"node-1", "node-2"}, rebalancer.nodeNames())
+	assert.NotContains(t, rebalancer.current.Nodes, (*corev1.Node)(nil))
+	name, count := rebalancer.getNodeWithMaxPods()
+	assert.Equal(t, "node-1", name)
+	assert.Equal(t, 1, count)
+}
+
 func TestRebalance(t *testing.T) {
 	replicas := int32(3)
 	ctx := context.Background()
@@ -215,6 +255,761 @@ func TestRebalance(t *testing.T) {
 	}
 }
 
+func TestRebalanceWithReport(t *testing.T) {
+	replicas := int32(4)
+	ctx := context.Background()
+
+	replicaSet := &appsv1.ReplicaSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "rs", Namespace: "default"},
+		Status:     appsv1.ReplicaSetStatus{Replicas: replicas},
+		Spec:       appsv1.ReplicaSetSpec{Replicas: &replicas},
+	}
+	node1, node2 := node("node-1", capacity("100m", "100Mi")), node("node-2", capacity("100m", "100Mi"))
+	pods := []*corev1.Pod{
+		pod("pod-1", "node-1"), pod("pod-2", "node-1"), pod("pod-3", "node-1"), pod("pod-4", "node-1"),
+	}
+	state := &ReplicaState{Replicaset: replicaSet, Nodes: []*corev1.Node{node1, node2}}
+	for _, p := range pods {
+		state.PodStatus = append(state.PodStatus, &PodStatus{Pod: p})
+	}
+	client := fake.NewSimpleClientset(node1, node2, pods[0], pods[1], pods[2], pods[3])
+
+	report := &RebalanceReport{}
+	result, err := NewRebalancer(ctx, state).RebalanceWithReport(ctx, client, report)
+	assert.NoError(t, err)
+	assert.True(t, result)
+
+	rep := report.Last()
+	if assert.NotNil(t, rep) {
+		assert.Equal(t, "rs", rep.Name)
+		assert.Equal(t, "default", rep.Namespace)
+		assert.Equal(t, map[string]int{"node-1": 4}, rep.Before)
+		assert.Equal(t, map[string]int{"node-1": 3}, rep.After)
+		assert.Equal(t, []string{"pod-1"}, rep.Deleted)
+		assert.InDelta(t, 1.0, rep.BeforeCV, 1e-9)
+		assert.InDelta(t, 1.0, rep.AfterCV, 1e-9)
+	}
+
+	// Nothing is reported without a report.
+	var none *RebalanceReport
+	assert.Nil(t, none.Last())
+}
+
+func TestCoefficientOfVariation(t *testing.T) {
+	nodes := []string{"node-1", "node-2", "node-3"}
+	assert.Equal(t, 0.0, coefficientOfVariation(nil, nodes))
+	assert.Equal(t, 0.0, coefficientOfVariation(map[string]int{"node-1": 2, "node-2": 2, "node-3": 2}, nodes))
+	// 3, 1 and 0 pods: mean 4/3, standard deviation sqrt(14/9).
+	assert.InDelta(t, 0.9354, coefficientOfVariation(map[string]int{"node-1": 3, "node-2": 1}, nodes), 1e-4)
+	// Pods on a node outside the list are counted as well.
+	assert.InDelta(t, 0.0, coefficientOfVariation(map[string]int{"node-1": 1, "node-4": 1}, nodes[:1]), 1e-9)
+}
+
+func TestRebalanceReport_WriteTable(t *testing.T) {
+	report := &RebalanceReport{ReplicaSets: []ReplicaSetReport{
+		{Namespace: "default", Name: "web-abc", Before: map[string]int{"node-2": 1, "node-1": 3},
+			After: map[string]int{"node-1": 2, "node-2": 1}, Deleted: []string{"web-abc-1"}},
+		{Namespace: "apps", Name: "api-xyz", Before: map[string]int{"node-1": 1}, After: map[string]int{"node-1": 1}},
+	}}
+	buf := &bytes.Buffer{}
+	assert.NoError(t, report.WriteTable(buf))
+	assert.Equal(t, ""+
+		"NAMESPACE  REPLICASET  BEFORE             AFTER              ACTION\n"+
+		"default    web-abc     node-1=3,node-2=1  node-1=2,node-2=1  deleted web-abc-1\n"+
+		"apps       api-xyz     node-1=1           node-1=1           none\n", buf.String())
+
+	buf.Reset()
+	assert.NoError(t, (*RebalanceReport)(nil).WriteTable(buf))
+	assert.Equal(t, "NAMESPACE  REPLICASET  BEFORE  AFTER  ACTION\n", buf.String())
+}
+
+func TestRebalance_MaxPerNode(t *testing.T) {
+	replicas := int32(8)
+	ctx := context.Background()
+
+	newState := func() (*ReplicaState, *fake.Clientset) {
+		replicaSet := &appsv1.ReplicaSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "rs", Namespace: "default"},
+			Status:     appsv1.ReplicaSetStatus{Replicas: replicas},
+			Spec:       appsv1.ReplicaSetSpec{Replicas: &replicas},
+		}
+		nodes := []*corev1.Node{
+			node("node-1", capacity("100m", "100Mi")),
+			node("node-2", capacity("100m", "100Mi")),
+			node("node-3", capacity("100m", "100Mi")),
+		}
+		pods := []*corev1.Pod{
+			pod("pod-1", "node-1"), pod("pod-2", "node-1"), pod("pod-3", "node-1"),
+			pod("pod-4", "node-2"), pod("pod-5", "node-2"), pod("pod-6", "node-2"),
+			pod("pod-7", "node-3"), pod("pod-8", "node-3"),
+		}
+		state := &ReplicaState{Replicaset: replicaSet, Nodes: nodes}
+		client := fake.NewSimpleClientset()
+		for _, p := range pods {
+			state.PodStatus = append(state.PodStatus, &PodStatus{Pod: p})
+			_ = client.Tracker().Add(p)
+		}
+		return state, client
+	}
+
+	// Within the average based limit, nothing is deleted.
+	state, client := newState()
+	result, err := NewRebalancer(ctx, state).Rebalance(ctx, client)
+	assert.NoError(t, err)
+	assert.False(t, result)
+
+	// The overflow over the cap is deleted from every node.
+	state, client = newState()
+	report := &RebalanceReport{}
+	result, err = NewRebalancer(ctx, state, WithMaxPerNode(2)).RebalanceWithReport(ctx, client, report)
+	assert.NoError(t, err)
+	assert.True(t, result)
+	assert.Equal(t, map[string]int{"node-1": 2, "node-2": 2, "node-3": 2}, report.Last().After)
+
+	// The rebalance rate still caps the total deletions.
+	state, client = newState()
+	report = &RebalanceReport{}
+	_, err = NewRebalancer(ctx, state, WithMaxPerNode(1)).RebalanceWithReport(ctx, client, report)
+	assert.NoError(t, err)
+	assert.Len(t, report.Last().Deleted, 2)
+}
+
+func TestRebalance_Threshold(t *testing.T) {
+	replicas := int32(8)
+	ctx := context.Background()
+
+	newState := func() (*ReplicaState, *fake.Clientset) {
+		replicaSet := &appsv1.ReplicaSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "rs", Namespace: "default"},
+			Status:     appsv1.ReplicaSetStatus{Replicas: replicas},
+			Spec:       appsv1.ReplicaSetSpec{Replicas: &replicas},
+		}
+		nodes := []*corev1.Node{
+			node("node-1", capacity("100m", "100Mi")),
+			node("node-2", capacity("100m", "100Mi")),
+			node("node-3", capacity("100m", "100Mi")),
+		}
+		pods := []*corev1.Pod{
+			pod("pod-1", "node-1"), pod("pod-2", "node-1"), pod("pod-3", "node-1"),
+			pod("pod-4", "node-2"), pod("pod-5", "node-2"), pod("pod-6", "node-2"),
+			pod("pod-7", "node-3"), pod("pod-8", "node-3"),
+		}
+		state := &ReplicaState{Replicaset: replicaSet, Nodes: nodes}
+		client := fake.NewSimpleClientset()
+		for _, p := range pods {
+			state.PodStatus = append(state.PodStatus, &PodStatus{Pod: p})
+			_ = client.Tracker().Add(p)
+		}
+		return state, client
+	}
+
+	// 3 pods on node-1 are below the average 2.67 plus the default threshold 1.
+	state, client := newState()
+	result, err := NewRebalancer(ctx, state).Rebalance(ctx, client)
+	assert.NoError(t, err)
+	assert.False(t, result)
+
+	// Negative thresholds are ignored.
+	state, client = newState()
+	result, err = NewRebalancer(ctx, state, WithThreshold(-1)).Rebalance(ctx, client)
+	assert.NoError(t, err)
+	assert.False(t, result)
+
+	// Without slack, pods over the average are deleted.
+	state, client = newState()
+	result, err = NewRebalancer(ctx, state, WithThreshold(0)).Rebalance(ctx, client)
+	assert.NoError(t, err)
+	assert.True(t, result)
+}
+
+func TestRebalance_Command(t *testing.T) {
+	replicas := int32(8)
+
+	newState := func() (*ReplicaState, *fake.Clientset) {
+		replicaSet := &appsv1.ReplicaSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "rs", Namespace: "default"},
+			Status:     appsv1.ReplicaSetStatus{Replicas: replicas},
+			Spec:       appsv1.ReplicaSetSpec{Replicas: &replicas},
+		}
+		nodes := []*corev1.Node{
+			node("node-1", capacity("100m", "100Mi")),
+			node("node-2", capacity("100m", "100Mi")),
+		}
+		pods := []*corev1.Pod{
+			pod("pod-1", "node-1"), pod("pod-2", "node-1"), pod("pod-3", "node-1"),
+			pod("pod-4", "node-1"), pod("pod-5", "node-1"), pod("pod-6", "node-1"),
+			pod("pod-7", "node-2"), pod("pod-8", "node-2"),
+		}
+		state := &ReplicaState{Replicaset: replicaSet, Nodes: nodes}
+		client := fake.NewSimpleClientset()
+		for _, p := range pods {
+			state.PodStatus = append(state.PodStatus, &PodStatus{Pod: p})
+			_ = client.Tracker().Add(p)
+		}
+		return state, client
+	}
+	audited := func(opts ...Option) []string {
+		var commands []string
+		ctx := logger.WithContext(context.Background(), funcr.New(func(_, args string) {
+			if strings.Contains(args, kube.AuditDeletionMessage) {
+				commands = append(commands, args[strings.Index(args, `"command"=`):])
+			}
+		}, funcr.Options{}))
+		state, client := newState()
+		result, err := NewRebalancer(ctx, state, opts...).Rebalance(ctx, client)
+		assert.NoError(t, err)
+		assert.True(t, result)
+		return commands
+	}
+
+	assert.Equal(t, []string{`"command"="rebalancer"`, `"command"="rebalancer"`}, audited())
+	assert.Equal(t, []string{`"command"="rebalance-pods"`, `"command"="rebalance-pods"`}, audited(WithCommand("rebalance-pods")))
+	// An empty name keeps the default.
+	assert.Equal(t, []string{`"command"="rebalancer"`, `"command"="rebalancer"`}, audited(WithCommand("")))
+}
+
+func TestRebalance_ThresholdZeroBalanced(t *testing.T) {
+	replicas := int32(6)
+	ctx := context.Background()
+	weights := map[string]float64{"node-1": 4, "node-2": 1, "node-3": 1}
+
+	replicaSet := &appsv1.ReplicaSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "rs", Namespace: "default"},
+		Status:     appsv1.ReplicaSetStatus{Replicas: replicas},
+		Spec:       appsv1.ReplicaSetSpec{Replicas: &replicas},
+	}
+	nodes := []*corev1.Node{
+		node("node-1", capacity("4", "4Gi")), node("node-2", capacity("1", "1Gi")), node("node-3", capacity("1", "1Gi")),
+	}
+	pods := []*corev1.Pod{
+		pod("pod-1", "node-1"), pod("pod-2", "node-1"), pod("pod-3", "node-1"), pod("pod-4", "node-1"),
+		pod("pod-5", "node-2"), pod("pod-6", "node-3"),
+	}
+	state := &ReplicaState{Replicaset: replicaSet, Nodes: nodes}
+	client := fake.NewSimpleClientset()
+	for _, p := range pods {
+		state.PodStatus = append(state.PodStatus, &PodStatus{Pod: p})
+		_ = client.Tracker().Add(p)
+	}
+
+	// 4 pods on node-1 are exactly its weighted share, so nothing is deleted even without slack.
+	result, err := NewRebalancer(ctx, state, WithNodeWeights(weights), WithThreshold(0)).Rebalance(ctx, client)
+	assert.NoError(t, err)
+	assert.False(t, result)
+	list, err := client.CoreV1().Pods("default").List(ctx, metav1.ListOptions{})
+	assert.NoError(t, err)
+	assert.Len(t, list.Items, 6)
+}
+
+func TestRebalance_CountedReplicas(t *testing.T) {
+	replicas := int32(12)
+	ctx := context.Background()
+
+	// 6 of the 12 replicas run on nodes out of the node selector and are left out of the state.
+	newState := func() (*ReplicaState, *fake.Clientset) {
+		replicaSet := &appsv1.ReplicaSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "rs", Namespace: "default"},
+			Status:     appsv1.ReplicaSetStatus{Replicas: replicas},
+			Spec:       appsv1.ReplicaSetSpec{Replicas: &replicas},
+		}
+		nodes := []*corev1.Node{
+			node("node-1", capacity("100m", "100Mi")),
+			node("node-2", capacity("100m", "100Mi")),
+			node("node-3", capacity("100m", "100Mi")),
+		}
+		pods := []*corev1.Pod{
+			pod("pod-1", "node-1"), pod("pod-2", "node-1"), pod("pod-3", "node-1"), pod("pod-4", "node-1"),
+			pod("pod-5", "node-2"), pod("pod-6", "node-3"),
+		}
+		state := &ReplicaState{Replicaset: replicaSet, Nodes: nodes}
+		client := fake.NewSimpleClientset()
+		for _, p := range pods {
+			state.PodStatus = append(state.PodStatus, &PodStatus{Pod: p})
+			_ = client.Tracker().Add(p)
+		}
+		return state, client
+	}
+
+	// 4 pods on node-1 are below the average of the spec replicas 4 plus the threshold 1.
+	state, client := newState()
+	result, err := NewRebalancer(ctx, state).Rebalance(ctx, client)
+	assert.NoError(t, err)
+	assert.False(t, result)
+
+	// The average of the 6 counted pods is 2, so node-1 is over it.
+	state, client = newState()
+	result, err = NewRebalancer(ctx, state, WithCountedReplicas(true)).Rebalance(ctx, client)
+	assert.NoError(t, err)
+	assert.True(t, result)
+}
+
+func TestRebalance_ReplicaTolerance(t *testing.T) {
+	replicas := int32(8)
+	ctx := context.Background()
+
+	newState := func(placement map[string]int) (*ReplicaState, *fake.Clientset) {
+		replicaSet := &appsv1.ReplicaSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "rs", Namespace: "default"},
+			Status:     appsv1.ReplicaSetStatus{Replicas: replicas},
+			Spec:       appsv1.ReplicaSetSpec{Replicas: &replicas},
+		}
+		state := &ReplicaState{Replicaset: replicaSet}
+		client := fake.NewSimpleClientset()
+		for _, n := range []string{"node-1", "node-2", "node-3"} {
+			state.Nodes = append(state.Nodes, node(n, capacity("100m", "100Mi")))
+			for i := 0; i < placement[n]; i++ {
+				p := pod(fmt.Sprintf("%s-pod-%d", n, i), n)
+				state.PodStatus = append(state.PodStatus, &PodStatus{Pod: p})
+				_ = client.Tracker().Add(p)
+			}
+		}
+		return state, client
+	}
+
+	// A spread of 1 is tolerated even without threshold slack.
+	state, client := newState(map[string]int{"node-1": 3, "node-2": 3, "node-3": 2})
+	result, err := NewRebalancer(ctx, state, WithThreshold(0), WithReplicaTolerance(1)).Rebalance(ctx, client)
+	assert.NoError(t, err)
+	assert.False(t, result)
+
+	// A spread over the tolerance is rebalanced.
+	state, client = newState(map[string]int{"node-1": 5, "node-2": 2, "node-3": 1})
+	result, err = NewRebalancer(ctx, state, WithReplicaTolerance(1)).Rebalance(ctx, client)
+	assert.NoError(t, err)
+	assert.True(t, result)
+
+	// A large tolerance keeps the same placement as is.
+	state, client = newState(map[string]int{"node-1": 5, "node-2": 2, "node-3": 1})
+	result, err = NewRebalancer(ctx, state, WithReplicaTolerance(4)).Rebalance(ctx, client)
+	assert.NoError(t, err)
+	assert.False(t, result)
+}
+
+func TestRebalance_ProtectLastReadyPod(t *testing.T) {
+	replicas := int32(2)
+	ctx := context.Background()
+
+	replicaSet := &appsv1.ReplicaSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "rs", Namespace: "default"},
+		Status:     appsv1.ReplicaSetStatus{Replicas: replicas},
+		Spec:       appsv1.ReplicaSetSpec{Replicas: &replicas},
+	}
+	notReady := func(p *corev1.Pod) { p.Status.Phase = corev1.PodPending }
+	pods := []*corev1.Pod{pod("pod-1", "node-1"), pod("pod-2", "node-1", notReady)}
+	state := &ReplicaState{
+		Replicaset: replicaSet,
+		Nodes:      []*corev1.Node{node("node-1", capacity("100m", "100Mi")), node("node-2", capacity("100m", "100Mi"))},
+	}
+	client := fake.NewSimpleClientset()
+	for _, p := range pods {
+		state.PodStatus = append(state.PodStatus, &PodStatus{Pod: p})
+		_ = client.Tracker().Add(p)
+	}
+
+	// pod-1 is over the per node cap but is the only ready pod.
+	result, err := NewRebalancer(ctx, state, WithMaxPerNode(1)).Rebalance(ctx, client)
+	assert.NoError(t, err)
+	assert.False(t, result)
+	for _, a := range client.Actions() {
+		assert.NotEqual(t, "delete", a.GetVerb())
+	}
+}
+
+func TestRebalance_NeverDeletesNotReadyPods(t *testing.T) {
+	replicas := int32(5)
+	ctx := context.Background()
+	notReady := func(p *corev1.Pod) {
+		p.Status.Phase = corev1.PodRunning
+		p.Status.ContainerStatuses = []corev1.ContainerStatus{{Ready: false}}
+	}
+	replicaSet := &appsv1.ReplicaSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "rs", Namespace: "default"},
+		Status:     appsv1.ReplicaSetStatus{Replicas: replicas},
+		Spec:       appsv1.ReplicaSetSpec{Replicas: &replicas},
+	}
+	// The not ready pods are counted, making node-1 the hot node.
+	state := &ReplicaState{
+		Replicaset: replicaSet,
+		Nodes: []*corev1.Node{
+			node("node-1", capacity("1", "1Gi")), node("node-2", capacity("1", "1Gi")), node("node-3", capacity("1", "1Gi")),
+		},
+	}
+	client := fake.NewSimpleClientset()
+	for _, p := range []*corev1.Pod{
+		pod("pod-1", "node-1", notReady), pod("pod-2", "node-1", notReady), pod("pod-3", "node-1", notReady),
+		pod("pod-4", "node-2"), pod("pod-5", "node-3"),
+	} {
+		state.PodStatus = append(state.PodStatus, &PodStatus{Pod: p})
+		_ = client.Tracker().Add(p)
+	}
+
+	result, err := NewRebalancer(ctx, state, WithThreshold(0)).Rebalance(ctx, client)
+	assert.NoError(t, err)
+	assert.False(t, result)
+	for _, a := range client.Actions() {
+		assert.NotEqual(t, "delete", a.GetVerb())
+	}
+}
+
+func TestRebalance_NodeWeights(t *testing.T) {
+	replicas := int32(6)
+	ctx := context.Background()
+	weights := map[string]float64{"node-1": 4, "node-2": 1, "node-3": 1}
+
+	newState := func(placement ...string) (*ReplicaState, *fake.Clientset) {
+		replicaSet := &appsv1.ReplicaSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "rs", Namespace: "default"},
+			Status:     appsv1.ReplicaSetStatus{Replicas: replicas},
+			Spec:       appsv1.ReplicaSetSpec{Replicas: &replicas},
+		}
+		state := &ReplicaState{Replicaset: replicaSet, Nodes: []*corev1.Node{
+			node("node-1", capacity("4", "4Gi")), node("node-2", capacity("1", "1Gi")), node("node-3", capacity("1", "1Gi")),
+		}}
+		client := fake.NewSimpleClientset()
+		for i, n := range placement {
+			p := pod(fmt.Sprintf("pod-%d", i+1), n)
+			state.PodStatus = append(state.PodStatus, &PodStatus{Pod: p})
+			_ = client.Tracker().Add(p)
+		}
+		return state, client
+	}
+	deletedNodes := func(state *ReplicaState) []string {
+		var nodes []string
+		for _, s := range state.PodStatus {
+			if s.deleted {
+				nodes = append(nodes, s.Pod.Spec.NodeName)
+			}
+		}
+		return nodes
+	}
+
+	// Unweighted, the big node is over the average of 2 per node.
+	state, client := newState("node-1", "node-1", "node-1", "node-1", "node-2", "node-3")
+	result, err := NewRebalancer(ctx, state).Rebalance(ctx, client)
+	assert.NoError(t, err)
+	assert.True(t, result)
+
+	// Weighted, the big node legitimately hosts 4 of the 6 pods.
+	state, client = newState("node-1", "node-1", "node-1", "node-1", "node-2", "node-3")
+	result, err = NewRebalancer(ctx, state, WithNodeWeights(weights)).Rebalance(ctx, client)
+	assert.NoError(t, err)
+	assert.False(t, result)
+
+	// Weighted, a small node hosting more pods than the big one is trimmed.
+	state, client = newState("node-1", "node-1", "node-2", "node-2", "node-2", "node-3")
+	result, err = NewRebalancer(ctx, state, WithNodeWeights(weights)).Rebalance(ctx, client)
+	assert.NoError(t, err)
+	assert.True(t, result)
+	assert.Equal(t, []string{"node-2"}, deletedNodes(state))
+}
+
+func TestRebalance_NodeWeightsEvenCounts(t *testing.T) {
+	replicas := int32(10)
+	weights := map[string]float64{"big": 4, "small": 1}
+
+	newState := func() (*ReplicaState, *fake.Clientset) {
+		replicaSet := &appsv1.ReplicaSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "rs", Namespace: "default"},
+			Status:     appsv1.ReplicaSetStatus{Replicas: replicas},
+			Spec:       appsv1.ReplicaSetSpec{Replicas: &replicas},
+		}
+		state := &ReplicaState{Replicaset: replicaSet, Nodes: []*corev1.Node{
+			node("big", capacity("4", "4Gi")), node("small", capacity("1", "1Gi")),
+		}}
+		client := fake.NewSimpleClientset()
+		for i := 0; i < int(replicas); i++ {
+			n := "big"
+			if i%2 == 1 {
+				n = "small"
+			}
+			p := pod(fmt.Sprintf("pod-%d", i+1), n)
+			state.PodStatus = append(state.PodStatus, &PodStatus{Pod: p})
+			_ = client.Tracker().Add(p)
+		}
+		return state, client
+	}
+	deletedNodes := func(state *ReplicaState) []string {
+		var nodes []string
+		for _, s := range state.PodStatus {
+			if s.deleted {
+				nodes = append(nodes, s.Pod.Spec.NodeName)
+			}
+		}
+		return nodes
+	}
+
+	// Unweighted, 5 pods on each node are balanced.
+	ctx := context.Background()
+	state, client := newState()
+	result, err := NewRebalancer(ctx, state).Rebalance(ctx, client)
+	assert.NoError(t, err)
+	assert.False(t, result)
+
+	// Weighted, the small node is 3 pods over its share of 2, whatever the tolerance.
+	for _, tolerance := range []int{0, 1} {
+		state, client = newState()
+		result, err = NewRebalancer(ctx, state, WithNodeWeights(weights), WithReplicaTolerance(tolerance)).Rebalance(ctx, client)
+		assert.NoError(t, err)
+		assert.True(t, result, "tolerance %d", tolerance)
+		assert.Equal(t, []string{"small", "small"}, deletedNodes(state), "tolerance %d", tolerance)
+	}
+}
+
+func TestRebalance_Verify(t *testing.T) {
+	replicas := int32(8)
+	ctx := context.Background()
+	owned := func(p *corev1.Pod) {
+		p.UID = types.UID(p.Name)
+		p.OwnerReferences = []metav1.OwnerReference{{Kind: "ReplicaSet", Name: "rs", UID: "rs-uid"}}
+	}
+
+	newState := func(replace bool) (*ReplicaState, *fake.Clientset) {
+		replicaSet := &appsv1.ReplicaSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "rs", Namespace: "default", UID: "rs-uid"},
+			Status:     appsv1.ReplicaSetStatus{Replicas: replicas},
+			Spec:       appsv1.ReplicaSetSpec{Replicas: &replicas},
+		}
+		state := &ReplicaState{Replicaset: replicaSet, Nodes: []*corev1.Node{
+			node("node-1", capacity("1", "1Gi")), node("node-2", capacity("1", "1Gi")), node("node-3", capacity("1", "1Gi")),
+		}}
+		client := fake.NewSimpleClientset()
+		for i, n := range []string{"node-1", "node-1", "node-1", "node-1", "node-1", "node-1", "node-2", "node-3"} {
+			p := pod(fmt.Sprintf("pod-%d", i+1), n, owned)
+			state.PodStatus = append(state.PodStatus, &PodStatus{Pod: p})
+			_ = client.Tracker().Add(p)
+		}
+		if replace {
+			// The replica set controller brings up a ready replacement for each deleted pod.
+			client.PrependReactor("delete", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				name := action.(k8stesting.DeleteAction).GetName()
+				return false, nil, client.Tracker().Add(pod("new-"+name, "node-2", owned))
+			})
+		}
+		return state, client
+	}
+	deletes := func(client *fake.Clientset) int {
+		n := 0
+		for _, a := range client.Actions() {
+			if a.GetVerb() == "delete" {
+				n++
+			}
+		}
+		return n
+	}
+
+	// Without a ready replacement, further deletions are aborted.
+	state, client := newState(false)
+	result, err := NewRebalancer(ctx, state, WithVerify(20*time.Millisecond)).Rebalance(ctx, client)
+	assert.ErrorIs(t, err, ErrReplacementNotReady)
+	assert.True(t, result)
+	assert.Equal(t, 1, deletes(client))
+
+	// With ready replacements, the rebalance proceeds up to the rebalance rate.
+	state, client = newState(true)
+	result, err = NewRebalancer(ctx, state, WithVerify(time.Second)).Rebalance(ctx, client)
+	assert.NoError(t, err)
+	assert.True(t, result)
+	assert.Equal(t, 2, deletes(client))
+}
+
+func TestRebalance_PDB(t *testing.T) {
+	replicas := int32(4)
+	ctx := context.Background()
+
+	newState := func(allowed int32) (*ReplicaState, *fake.Clientset) {
+		replicaSet := &appsv1.ReplicaSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "rs", Namespace: "default"},
+			Status:     appsv1.ReplicaSetStatus{Replicas: replicas},
+			Spec:       appsv1.ReplicaSetSpec{Replicas: &replicas},
+		}
+		labeled := func(p *corev1.Pod) { p.Labels = map[string]string{"app": "web"} }
+		state := &ReplicaState{
+			Replicaset: replicaSet,
+			Nodes:      []*corev1.Node{node("node-1", capacity("100m", "100Mi")), node("node-2", capacity("100m", "100Mi"))},
+		}
+		client := fake.NewSimpleClientset(&policyv1.PodDisruptionBudget{
+			ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+			Spec:       policyv1.PodDisruptionBudgetSpec{Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}}},
+			Status:     policyv1.PodDisruptionBudgetStatus{DisruptionsAllowed: allowed},
+		})
+		for i := 1; i <= 4; i++ {
+			p := pod(fmt.Sprintf("pod-%d", i), "node-1", labeled)
+			state.PodStatus = append(state.PodStatus, &PodStatus{Pod: p})
+			_ = client.Tracker().Add(p)
+		}
+		return state, client
+	}
+
+	// The budget allows no disruption.
+	state, client := newState(0)
+	result, err := NewRebalancer(ctx, state, WithPDBCache(kube.NewPDBCache(client))).Rebalance(ctx, client)
+	assert.NoError(t, err)
+	assert.False(t, result)
+
+	// The budget allows a disruption.
+	state, client = newState(1)
+	report := &RebalanceReport{}
+	result, err = NewRebalancer(ctx, state, WithThreshold(0), WithPDBCache(kube.NewPDBCache(client))).
+		RebalanceWithReport(ctx, client, report)
+	assert.NoError(t, err)
+	assert.True(t, result)
+	assert.Len(t, report.Last().Deleted, 1)
+}
+
+func TestRebalance_PreferPressuredNodes(t *testing.T) {
+	replicas := int32(8)
+	ctx := context.Background()
+
+	newState := func() (*ReplicaState, *fake.Clientset) {
+		replicaSet := &appsv1.ReplicaSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "rs", Namespace: "default"},
+			Status:     appsv1.ReplicaSetStatus{Replicas: replicas},
+			Spec:       appsv1.ReplicaSetSpec{Replicas: &replicas},
+		}
+		pressured := node("node-3", capacity("100m", "100Mi"))
+		pressured.Status.Conditions = []corev1.NodeCondition{
+			{Type: corev1.NodeMemoryPressure, Status: corev1.ConditionTrue},
+		}
+		nodes := []*corev1.Node{
+			node("node-1", capacity("100m", "100Mi")),
+			node("node-2", capacity("100m", "100Mi")),
+			pressured,
+		}
+		pods := []*corev1.Pod{
+			pod("pod-1", "node-1"), pod("pod-2", "node-1"), pod("pod-3", "node-1"),
+			pod("pod-4", "node-2"), pod("pod-5", "node-2"), pod("pod-6", "node-2"),
+			pod("pod-7", "node-3"), pod("pod-8", "node-3"),
+		}
+		state := &ReplicaState{Replicaset: replicaSet, Nodes: nodes}
+		client := fake.NewSimpleClientset()
+		for _, p := range pods {
+			state.PodStatus = append(state.PodStatus, &PodStatus{Pod: p})
+			_ = client.Tracker().Add(p)
+		}
+		return state, client
+	}
+
+	// By default the pressured node is not the most populated and nothing is deleted.
+	state, client := newState()
+	result, err := NewRebalancer(ctx, state).Rebalance(ctx, client)
+	assert.NoError(t, err)
+	assert.False(t, result)
+
+	// The pods on the pressured node are deleted first within the rebalance rate.
+	state, client = newState()
+	report := &RebalanceReport{}
+	result, err = NewRebalancer(ctx, state, WithPreferPressuredNodes(true)).RebalanceWithReport(ctx, client, report)
+	assert.NoError(t, err)
+	assert.True(t, result)
+	assert.ElementsMatch(t, []string{"pod-7", "pod-8"}, report.Last().Deleted)
+}
+
+func TestRebalance_RespectAntiAffinity(t *testing.T) {
+	replicas := int32(5)
+	ctx := context.Background()
+	hostname := func(n *corev1.Node) { n.Labels = map[string]string{"kubernetes.io/hostname": n.Name} }
+	antiAffinity := func(p *corev1.Pod) {
+		p.Labels = map[string]string{"app": "web"}
+		p.Spec.Affinity = &corev1.Affinity{PodAntiAffinity: &corev1.PodAntiAffinity{
+			RequiredDuringSchedulingIgnoredDuringExecution: []corev1.PodAffinityTerm{{
+				LabelSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}},
+				TopologyKey:   "kubernetes.io/hostname",
+			}},
+		}}
+	}
+
+	newState := func() (*ReplicaState, *fake.Clientset) {
+		replicaSet := &appsv1.ReplicaSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "rs", Namespace: "default"},
+			Status:     appsv1.ReplicaSetStatus{Replicas: replicas},
+			Spec:       appsv1.ReplicaSetSpec{Replicas: &replicas},
+		}
+		nodes := []*corev1.Node{
+			node("node-1", capacity("100m", "100Mi"), hostname),
+			node("node-2", capacity("100m", "100Mi"), hostname),
+			node("node-3", capacity("100m", "100Mi"), hostname),
+		}
+		pods := []*corev1.Pod{
+			pod("pod-1", "node-1", antiAffinity), pod("pod-2", "node-1", antiAffinity), pod("pod-3", "node-1", antiAffinity),
+			pod("pod-4", "node-2", antiAffinity), pod("pod-5", "node-3", antiAffinity),
+		}
+		state := &ReplicaState{Replicaset: replicaSet, Nodes: nodes}
+		client := fake.NewSimpleClientset()
+		for _, p := range pods {
+			state.PodStatus = append(state.PodStatus, &PodStatus{Pod: p})
+			_ = client.Tracker().Add(p)
+		}
+		return state, client
+	}
+
+	// Without the option, a pod on the hot node is deleted.
+	state, client := newState()
+	result, err := NewRebalancer(ctx, state).Rebalance(ctx, client)
+	assert.NoError(t, err)
+	assert.True(t, result)
+
+	// Every other node already hosts a pod that the anti-affinity rejects.
+	state, client = newState()
+	result, err = NewRebalancer(ctx, state, WithRespectAntiAffinity(true)).Rebalance(ctx, client)
+	assert.NoError(t, err)
+	assert.False(t, result)
+}
+
+func TestRebalance_CheckHeadroom(t *testing.T) {
+	replicas := int32(6)
+	ctx := context.Background()
+	request := func(cpu string) func(p *corev1.Pod) {
+		return func(p *corev1.Pod) {
+			p.Spec.Containers = []corev1.Container{{Resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse(cpu)},
+			}}}
+		}
+	}
+
+	newState := func(cpu string) (*ReplicaState, *fake.Clientset) {
+		replicaSet := &appsv1.ReplicaSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "rs", Namespace: "default"},
+			Status:     appsv1.ReplicaSetStatus{Replicas: replicas},
+			Spec:       appsv1.ReplicaSetSpec{Replicas: &replicas},
+		}
+		nodes := []*corev1.Node{
+			node("node-1", capacity("300m", "100Mi")),
+			node("node-2", capacity("100m", "100Mi")),
+			node("node-3", capacity("100m", "100Mi")),
+		}
+		pods := []*corev1.Pod{
+			pod("pod-1", "node-1", request(cpu)), pod("pod-2", "node-1", request(cpu)),
+			pod("pod-3", "node-1", request(cpu)), pod("pod-4", "node-1", request(cpu)),
+			pod("pod-5", "node-2", request(cpu)), pod("pod-6", "node-3", request(cpu)),
+		}
+		state := &ReplicaState{Replicaset: replicaSet, Nodes: nodes}
+		client := fake.NewSimpleClientset()
+		for _, p := range pods {
+			state.PodStatus = append(state.PodStatus, &PodStatus{Pod: p})
+			_ = client.Tracker().Add(p)
+		}
+		return state, client
+	}
+
+	// Without the option, a pod on the hot node is deleted even if it cannot fit anywhere else.
+	state, client := newState("60m")
+	result, err := NewRebalancer(ctx, state).Rebalance(ctx, client)
+	assert.NoError(t, err)
+	assert.True(t, result)
+
+	// The other nodes have only 40m free for a pod requesting 60m.
+	state, client = newState("60m")
+	result, err = NewRebalancer(ctx, state, WithCheckHeadroom(true)).Rebalance(ctx, client)
+	assert.NoError(t, err)
+	assert.False(t, result)
+
+	// The other nodes have 60m free for a pod requesting 40m.
+	state, client = newState("40m")
+	result, err = NewRebalancer(ctx, state, WithCheckHeadroom(true)).Rebalance(ctx, client)
+	assert.NoError(t, err)
+	assert.True(t, result)
+}
+
 func TestDeletePodOnNode(t *testing.T) {
 	// Create a test ReplicaState
 	replicaState := &ReplicaState{
@@ -243,7 +1038,7 @@ func TestDeletePodOnNode(t *testing.T) {
 	}
 
 	// Call the deletePodOnNode function
-	err := rebalancer.deletePodOnNode(ctx, client, "node-1")
+	ok, err := rebalancer.deletePodOnNode(ctx, client, "node-1")
 
 	// Check for any errors
 	if err != nil {
@@ -251,9 +1046,17 @@ func TestDeletePodOnNode(t *testing.T) {
 	}
 
 	// Check if the Pod was marked as deleted
-	if !rebalancer.current.PodStatus[0].deleted {
+	if !ok || !rebalancer.current.PodStatus[0].deleted {
 		t.Errorf("PodStatus[0] was not marked as deleted")
 	}
+
+	// Not ready pods are never deleted
+	replicaState.PodStatus[1].Pod.Status.Phase = corev1.PodRunning
+	replicaState.PodStatus[1].Pod.Status.ContainerStatuses = []corev1.ContainerStatus{{Ready: false}}
+	ok, err = rebalancer.deletePodOnNode(ctx, client, "node-2")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	assert.False(t, rebalancer.current.PodStatus[1].deleted)
 }
 
 func TestGetNodeWithMaxPods(t *testing.T) {
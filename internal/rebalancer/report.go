@@ -0,0 +1,140 @@
+/*
+MIT License
+
+Copyright (c) 2024 Norihiro Seto
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package rebalancer
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// RebalanceReport represents the decisions made while rebalancing replica sets.
+type RebalanceReport struct {
+	ReplicaSets []ReplicaSetReport
+}
+
+// ReplicaSetReport represents the rebalancing decision for a replica set.
+// Before and After map a node name to the number of pods of the replica set on that node.
+// BeforeCV and AfterCV are the coefficients of variation of the pods per node, counting
+// the nodes without pods as well. Lower values mean more even distributions.
+type ReplicaSetReport struct {
+	Namespace string
+	Name      string
+	Before    map[string]int
+	After     map[string]int
+	BeforeCV  float64
+	AfterCV   float64
+	Deleted   []string
+}
+
+// add appends the replica set report to the report.
+func (r *RebalanceReport) add(rs ReplicaSetReport) {
+	if r == nil {
+		return
+	}
+	r.ReplicaSets = append(r.ReplicaSets, rs)
+}
+
+// WriteTable writes the replica set reports as aligned columns of the namespace, name,
+// pods per node before and after rebalancing, and the deleted pods.
+func (r *RebalanceReport) WriteTable(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "NAMESPACE\tREPLICASET\tBEFORE\tAFTER\tACTION")
+	if r != nil {
+		for _, rs := range r.ReplicaSets {
+			action := "none"
+			if len(rs.Deleted) > 0 {
+				action = "deleted " + strings.Join(rs.Deleted, ",")
+			}
+			_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", rs.Namespace, rs.Name,
+				formatDistribution(rs.Before), formatDistribution(rs.After), action)
+		}
+	}
+	return tw.Flush()
+}
+
+// formatDistribution formats the pods per node as node=count pairs sorted by the node name.
+func formatDistribution(counts map[string]int) string {
+	if len(counts) == 0 {
+		return "-"
+	}
+	nodes := make([]string, 0, len(counts))
+	for n := range counts {
+		nodes = append(nodes, n)
+	}
+	sort.Strings(nodes)
+	pairs := make([]string, 0, len(nodes))
+	for _, n := range nodes {
+		pairs = append(pairs, fmt.Sprintf("%s=%d", n, counts[n]))
+	}
+	return strings.Join(pairs, ",")
+}
+
+// coefficientOfVariation returns the population standard deviation of the pods per node
+// divided by their mean. The nodes missing in counts are counted as having no pods.
+// It returns 0 when there are no pods.
+func coefficientOfVariation(counts map[string]int, nodes []string) float64 {
+	values := make([]float64, 0, len(nodes)+len(counts))
+	seen := make(map[string]bool, len(nodes))
+	for _, n := range nodes {
+		if seen[n] {
+			continue
+		}
+		seen[n] = true
+		values = append(values, float64(counts[n]))
+	}
+	for n, c := range counts {
+		if !seen[n] {
+			values = append(values, float64(c))
+		}
+	}
+	if len(values) == 0 {
+		return 0
+	}
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+	}
+	mean := sum / float64(len(values))
+	if mean == 0 {
+		return 0
+	}
+	variance := 0.0
+	for _, v := range values {
+		variance += (v - mean) * (v - mean)
+	}
+	return math.Sqrt(variance/float64(len(values))) / mean
+}
+
+// Last returns the most recently added replica set report, or nil if there is none.
+func (r *RebalanceReport) Last() *ReplicaSetReport {
+	if r == nil || len(r.ReplicaSets) == 0 {
+		return nil
+	}
+	return &r.ReplicaSets[len(r.ReplicaSets)-1]
+}
@@ -0,0 +1,148 @@
+/*
+MIT License
+
+Copyright (c) 2024 Norihiro Seto
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package rebalancer
+
+import (
+	"context"
+	"math"
+
+	"github.com/norseto/k8s-watchdogs/pkg/generics"
+	"github.com/norseto/k8s-watchdogs/pkg/kube"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// PodFilter reports whether a pod is counted in the state of its replica set.
+type PodFilter func(ctx context.Context, pod *corev1.Pod) bool
+
+// DefaultPodFilter counts ready running pods that can be rebalanced.
+func DefaultPodFilter(_ context.Context, pod *corev1.Pod) bool {
+	return kube.IsPodReadyRunning(*pod) && kube.CanBeRebalanced(pod)
+}
+
+// BuildReplicaStates groups the pods by their owning replica sets and returns a state
+// for each replica set that owns at least one pod, in the order the pods are given.
+// Pods rejected by any of the filters or not owned by any of the replica sets are ignored.
+// Without filters, DefaultPodFilter is used. Every state shares the given nodes.
+func BuildReplicaStates(ctx context.Context, pods []corev1.Pod, nodes []*corev1.Node, replicas []*appsv1.ReplicaSet, filters ...PodFilter) []*ReplicaState {
+	if len(filters) == 0 {
+		filters = []PodFilter{DefaultPodFilter}
+	}
+	var states []*ReplicaState
+	rsMap := make(map[types.UID]*ReplicaState)
+
+	for i := range pods {
+		po := &pods[i]
+		if !matchAll(ctx, po, filters) {
+			continue
+		}
+		for _, rs := range replicas {
+			if !kube.IsPodOwnedBy(rs, po) {
+				continue
+			}
+			state, ok := rsMap[rs.UID]
+			if !ok {
+				state = &ReplicaState{Replicaset: rs, Nodes: nodes}
+				rsMap[rs.UID] = state
+				states = append(states, state)
+			}
+			state.PodStatus = append(state.PodStatus, &PodStatus{Pod: po.DeepCopy()})
+			break
+		}
+	}
+	return states
+}
+
+// PodsPerNode returns the number of non-deleted pods of the replica set per node name.
+// Nodes without pods are not included.
+func (s *ReplicaState) PodsPerNode() map[string]int {
+	pods := generics.Convert(s.PodStatus, func(s *PodStatus) *corev1.Pod { return s.Pod },
+		func(s *PodStatus) bool { return s != nil && !s.deleted })
+	return kube.CountPodsPerNode(pods)
+}
+
+// PodSpread returns the difference between the most and the least numbers of non-deleted pods
+// on the nodes of the replica state, counting the nodes without pods as zero.
+func (s *ReplicaState) PodSpread() int {
+	counts := s.PodsPerNode()
+	minCount, maxCount := -1, 0
+	for _, n := range s.Nodes {
+		if n == nil {
+			continue
+		}
+		c := counts[n.Name]
+		if minCount < 0 || c < minCount {
+			minCount = c
+		}
+		maxCount = max(maxCount, c)
+	}
+	if minCount < 0 {
+		return 0
+	}
+	return maxCount - minCount
+}
+
+// WeightedPodSpread returns the difference between the most and the least deviations of the
+// non-deleted pods on the nodes from their shares of the pods proportional to the node weights.
+// Nodes without a positive weight are weighted by 1. Without weights it equals PodSpread.
+func (s *ReplicaState) WeightedPodSpread(weights map[string]float64) float64 {
+	if weights == nil {
+		return float64(s.PodSpread())
+	}
+	counts := s.PodsPerNode()
+	nodes := generics.Filter(s.Nodes, func(n *corev1.Node) bool { return n != nil })
+	if len(nodes) == 0 {
+		return 0
+	}
+	weight := func(n *corev1.Node) float64 {
+		if w := weights[n.Name]; w > 0 {
+			return w
+		}
+		return 1
+	}
+	total, sum := 0, 0.0
+	for _, n := range nodes {
+		total += counts[n.Name]
+		sum += weight(n)
+	}
+	minDev, maxDev := math.Inf(1), math.Inf(-1)
+	for _, n := range nodes {
+		dev := float64(counts[n.Name]) - float64(total)*weight(n)/sum
+		minDev, maxDev = math.Min(minDev, dev), math.Max(maxDev, dev)
+	}
+	// Round off the floating point error so that equal weights give the same spread as PodSpread.
+	return math.Round((maxDev-minDev)*1e6) / 1e6
+}
+
+// matchAll reports whether the pod passes all the filters.
+func matchAll(ctx context.Context, pod *corev1.Pod, filters []PodFilter) bool {
+	for _, f := range filters {
+		if !f(ctx, pod) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,105 @@
+/*
+MIT License
+
+Copyright (c) 2024 Norihiro Seto
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package rebalancer
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestBuildReplicaStates(t *testing.T) {
+	ctx := context.Background()
+	rs1 := &appsv1.ReplicaSet{ObjectMeta: metav1.ObjectMeta{Name: "rs-1", UID: "rs-1"}}
+	rs2 := &appsv1.ReplicaSet{ObjectMeta: metav1.ObjectMeta{Name: "rs-2", UID: "rs-2"}}
+	nodes := []*corev1.Node{node("node-1"), node("node-2")}
+
+	owned := func(uid types.UID) func(p *corev1.Pod) {
+		return func(p *corev1.Pod) {
+			p.OwnerReferences = []metav1.OwnerReference{{Kind: "ReplicaSet", Name: string(uid), UID: uid}}
+		}
+	}
+	ready := func(p *corev1.Pod) {
+		p.Status.Phase = corev1.PodRunning
+		p.Status.Conditions = []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}}
+	}
+	pods := []corev1.Pod{
+		*pod("pod-1", "node-1", owned("rs-2"), ready),
+		*pod("pod-2", "node-1", owned("rs-1"), ready),
+		*pod("pod-3", "node-2", owned("rs-2"), ready),
+		*pod("unowned", "node-2", ready),
+		*pod("other-owner", "node-2", owned("rs-3"), ready),
+		*pod("pending", "node-2", owned("rs-1"), func(p *corev1.Pod) { p.Status.Phase = corev1.PodPending }),
+	}
+
+	states := BuildReplicaStates(ctx, pods, nodes, []*appsv1.ReplicaSet{rs1, rs2})
+	if assert.Len(t, states, 2) {
+		assert.Equal(t, "rs-2", states[0].Replicaset.Name)
+		assert.Equal(t, []string{"pod-1", "pod-3"}, podNames(states[0]))
+		assert.Equal(t, "rs-1", states[1].Replicaset.Name)
+		assert.Equal(t, []string{"pod-2"}, podNames(states[1]))
+		assert.Equal(t, nodes, states[1].Nodes)
+	}
+
+	// Custom filters replace the default one.
+	all := func(context.Context, *corev1.Pod) bool { return true }
+	states = BuildReplicaStates(ctx, pods, nodes, []*appsv1.ReplicaSet{rs1}, all)
+	if assert.Len(t, states, 1) {
+		assert.Equal(t, []string{"pod-2", "pending"}, podNames(states[0]))
+	}
+
+	// No replica sets own the pods.
+	assert.Empty(t, BuildReplicaStates(ctx, pods[3:4], nodes, []*appsv1.ReplicaSet{rs1, rs2}))
+}
+
+func podNames(state *ReplicaState) []string {
+	var names []string
+	for _, s := range state.PodStatus {
+		names = append(names, s.Pod.Name)
+	}
+	return names
+}
+
+func TestWeightedPodSpread(t *testing.T) {
+	state := &ReplicaState{Nodes: []*corev1.Node{node("node-1"), node("node-2"), nil}}
+	for i, n := range []string{"node-1", "node-1", "node-1", "node-2"} {
+		state.PodStatus = append(state.PodStatus, &PodStatus{Pod: pod(fmt.Sprintf("pod-%d", i), n)})
+	}
+
+	assert.Equal(t, 2, state.PodSpread())
+	assert.Equal(t, 2.0, state.WeightedPodSpread(nil))
+	assert.Equal(t, 2.0, state.WeightedPodSpread(map[string]float64{"node-1": 2, "node-2": 2}))
+	// 3 and 1 pods are the exact shares of the nodes weighted 3 and 1.
+	assert.Equal(t, 0.0, state.WeightedPodSpread(map[string]float64{"node-1": 3, "node-2": 1}))
+	// An unweighted node is weighted by 1, so node-2 is 2 pods under its share of 3 of 4 pods.
+	assert.Equal(t, 4.0, state.WeightedPodSpread(map[string]float64{"node-2": 3}))
+	assert.Equal(t, 0.0, (&ReplicaState{}).WeightedPodSpread(map[string]float64{"node-1": 1}))
+}
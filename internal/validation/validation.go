@@ -0,0 +1,141 @@
+/*
+MIT License
+
+Copyright (c) 2024 Norihiro Seto
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package validation
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	k8svalidation "k8s.io/apimachinery/pkg/util/validation"
+)
+
+// Error is returned when a value fails validation. It lets callers tell
+// validation failures apart from API errors with errors.As.
+type Error struct {
+	// Field is the name of the validated value, e.g. "namespace".
+	Field string
+	// Value is the rejected value. It is omitted from the message when empty.
+	Value string
+	// Reason describes why the value was rejected.
+	Reason string
+}
+
+// Error implements the error interface.
+func (e *Error) Error() string {
+	if e.Value == "" {
+		return e.Field + " " + e.Reason
+	}
+	return fmt.Sprintf("invalid %s %q: %s", e.Field, e.Value, e.Reason)
+}
+
+// ValidateResourceName checks that the name is a valid Kubernetes resource name
+// (RFC 1123 subdomain).
+func ValidateResourceName(name string) error {
+	if name == "" {
+		return &Error{Field: "resource name", Reason: "must not be empty"}
+	}
+	if errs := k8svalidation.IsDNS1123Subdomain(name); len(errs) > 0 {
+		return &Error{Field: "resource name", Value: name, Reason: strings.Join(errs, "; ")}
+	}
+	return nil
+}
+
+// ValidateNamespace checks that the namespace is empty (all namespaces) or
+// a valid Kubernetes namespace name (RFC 1123 label).
+func ValidateNamespace(namespace string) error {
+	if namespace == "" {
+		return nil
+	}
+	if errs := k8svalidation.IsDNS1123Label(namespace); len(errs) > 0 {
+		return &Error{Field: "namespace", Value: namespace, Reason: strings.Join(errs, "; ")}
+	}
+	return nil
+}
+
+// ValidateAnnotationKey checks that the key is empty (the default key) or
+// a valid annotation key, that is a qualified name with an optional DNS subdomain prefix.
+func ValidateAnnotationKey(key string) error {
+	if key == "" {
+		return nil
+	}
+	if errs := k8svalidation.IsQualifiedName(key); len(errs) > 0 {
+		return &Error{Field: "annotation key", Value: key, Reason: strings.Join(errs, "; ")}
+	}
+	return nil
+}
+
+// ValidateLabelKey checks that the key is empty or a valid label key,
+// that is a qualified name with an optional DNS subdomain prefix.
+func ValidateLabelKey(key string) error {
+	if key == "" {
+		return nil
+	}
+	if errs := k8svalidation.IsQualifiedName(key); len(errs) > 0 {
+		return &Error{Field: "label key", Value: key, Reason: strings.Join(errs, "; ")}
+	}
+	return nil
+}
+
+// ValidateConditionType checks that the type is empty or a valid custom pod condition type,
+// that is a qualified name with an optional DNS subdomain prefix as in readiness gates.
+func ValidateConditionType(conditionType string) error {
+	if conditionType == "" {
+		return nil
+	}
+	if errs := k8svalidation.IsQualifiedName(conditionType); len(errs) > 0 {
+		return &Error{Field: "condition type", Value: conditionType, Reason: strings.Join(errs, "; ")}
+	}
+	return nil
+}
+
+// MaxReasonLength is the maximum length of a reason recorded in an annotation.
+const MaxReasonLength = 256
+
+// ValidateReason checks that the reason is not longer than MaxReasonLength characters.
+func ValidateReason(reason string) error {
+	if n := utf8.RuneCountInString(reason); n > MaxReasonLength {
+		return &Error{Field: "reason",
+			Reason: fmt.Sprintf("is too long: %d characters, must be at most %d", n, MaxReasonLength)}
+	}
+	return nil
+}
+
+// MaxFieldManagerLength is the maximum length of a field manager accepted by the API server.
+const MaxFieldManagerLength = 128
+
+// ValidateFieldManager checks that the field manager is empty (no server-side apply) or
+// printable and not longer than MaxFieldManagerLength characters.
+func ValidateFieldManager(manager string) error {
+	if n := utf8.RuneCountInString(manager); n > MaxFieldManagerLength {
+		return &Error{Field: "field manager",
+			Reason: fmt.Sprintf("is too long: %d characters, must be at most %d", n, MaxFieldManagerLength)}
+	}
+	if strings.IndexFunc(manager, func(r rune) bool { return !unicode.IsPrint(r) }) >= 0 {
+		return &Error{Field: "field manager", Value: manager, Reason: "must only contain printable characters"}
+	}
+	return nil
+}
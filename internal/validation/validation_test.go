@@ -0,0 +1,149 @@
+/*
+MIT License
+
+Copyright (c) 2024 Norihiro Seto
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateResourceName(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		wantErr bool
+	}{
+		{"Valid", "node-1", false},
+		{"ValidWithDots", "ip-10-0-0-1.ec2.internal", false},
+		{"Empty", "", true},
+		{"UpperCase", "Node-1", true},
+		{"InvalidCharacter", "node_1", true},
+		{"TooLong", strings.Repeat("a", 254), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateResourceName(tt.input)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestValidateNamespace(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		wantErr bool
+	}{
+		{"AllNamespaces", "", false},
+		{"Valid", "kube-system", false},
+		{"Dots", "kube.system", true},
+		{"UpperCase", "Default", true},
+		{"TooLong", strings.Repeat("a", 64), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateNamespace(tt.input)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestValidateAnnotationKey(t *testing.T) {
+	assert.NoError(t, ValidateAnnotationKey(""))
+	assert.NoError(t, ValidateAnnotationKey("kubectl.kubernetes.io/restartedAt"))
+	assert.NoError(t, ValidateAnnotationKey("restarted-at"))
+	assert.Error(t, ValidateAnnotationKey("example.com/"))
+	assert.Error(t, ValidateAnnotationKey("Example_.com/restarted at"))
+	assert.Error(t, ValidateAnnotationKey(strings.Repeat("a", 64)))
+}
+
+func TestValidateLabelKey(t *testing.T) {
+	assert.NoError(t, ValidateLabelKey(""))
+	assert.NoError(t, ValidateLabelKey("node.kubernetes.io/instance-type"))
+	assert.Error(t, ValidateLabelKey("node.kubernetes.io/"))
+	assert.Error(t, ValidateLabelKey("instance type"))
+}
+
+func TestValidateConditionType(t *testing.T) {
+	assert.NoError(t, ValidateConditionType(""))
+	assert.NoError(t, ValidateConditionType("Ready"))
+	assert.NoError(t, ValidateConditionType("example.com/warmed-up"))
+	assert.Error(t, ValidateConditionType("warmed up"))
+}
+
+func TestValidateReason(t *testing.T) {
+	assert.NoError(t, ValidateReason(""))
+	assert.NoError(t, ValidateReason("rotate credentials"))
+	assert.NoError(t, ValidateReason(strings.Repeat("あ", MaxReasonLength)))
+	assert.Error(t, ValidateReason(strings.Repeat("a", MaxReasonLength+1)))
+}
+
+func TestValidateFieldManager(t *testing.T) {
+	assert.NoError(t, ValidateFieldManager(""))
+	assert.NoError(t, ValidateFieldManager("k8s-watchdogs"))
+	assert.Error(t, ValidateFieldManager(strings.Repeat("a", MaxFieldManagerLength+1)))
+	assert.Error(t, ValidateFieldManager("watch\tdogs"))
+}
+
+func TestError(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		wantField string
+		wantMsg   string
+	}{
+		{"EmptyResourceName", ValidateResourceName(""), "resource name", "resource name must not be empty"},
+		{"ResourceName", ValidateResourceName("node_1"), "resource name", `invalid resource name "node_1": `},
+		{"Namespace", ValidateNamespace("Default"), "namespace", `invalid namespace "Default": `},
+		{"AnnotationKey", ValidateAnnotationKey("example.com/"), "annotation key", `invalid annotation key "example.com/": `},
+		{"Reason", ValidateReason(strings.Repeat("a", MaxReasonLength+1)), "reason",
+			fmt.Sprintf("reason is too long: %d characters, must be at most %d", MaxReasonLength+1, MaxReasonLength)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var verr *Error
+			wrapped := fmt.Errorf("wrapped: %w", tt.err)
+			if assert.True(t, errors.As(wrapped, &verr)) {
+				assert.Equal(t, tt.wantField, verr.Field)
+			}
+			assert.True(t, strings.HasPrefix(tt.err.Error(), tt.wantMsg), tt.err.Error())
+		})
+	}
+}
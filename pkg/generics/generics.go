@@ -24,6 +24,11 @@ SOFTWARE.
 
 package generics
 
+import (
+	"cmp"
+	"slices"
+)
+
 // Contains checks that the string is Contains in the specified list
 func Contains[T comparable](s T, list []T) bool {
 	for _, v := range list {
@@ -75,6 +80,54 @@ func Convert[T any, V any](items []T, converter func(T) V, filter func(T) bool)
 	return result
 }
 
+// Filter returns the items that satisfy the predicate, keeping their order.
+// It returns nil when no item satisfies the predicate.
+func Filter[T any](items []T, pred func(T) bool) []T {
+	var result []T
+	Each(items, func(item T) {
+		if pred(item) {
+			result = append(result, item)
+		}
+	})
+	return result
+}
+
+// Partition splits the items into the ones that satisfy the predicate and the others,
+// keeping their order. Either result is nil when it has no items.
+func Partition[T any](items []T, pred func(T) bool) (yes, no []T) {
+	Each(items, func(item T) {
+		if pred(item) {
+			yes = append(yes, item)
+		} else {
+			no = append(no, item)
+		}
+	})
+	return yes, no
+}
+
+// Find returns the first item that satisfies the predicate.
+// The second return value is false when no item satisfies the predicate.
+func Find[T any](items []T, pred func(T) bool) (T, bool) {
+	var found T
+	ok := false
+	Each(items, func(item T) {
+		if !ok && pred(item) {
+			found, ok = item, true
+		}
+	})
+	return found, ok
+}
+
+// Reduce folds the items into a single value, starting from initial and
+// applying fn to the accumulator and each item in order.
+func Reduce[T any, A any](items []T, initial A, fn func(A, T) A) A {
+	acc := initial
+	for i := 0; i < len(items); i++ {
+		acc = fn(acc, items[i])
+	}
+	return acc
+}
+
 // Each applies the given action function to each item in the items slice.
 // The action function takes one argument of type T and has no return value.
 // Example usage:
@@ -109,3 +162,34 @@ func EachE[T any](items []T, action func(T) error) error {
 	}
 	return nil
 }
+
+// RoundRobin picks up to limit items, taking one item from each group in turn.
+// Items are grouped by the key function and groups are visited in ascending key order,
+// keeping the original order within each group, so the result is deterministic.
+// If limit is less than 1 or not less than the number of items, items is returned as is.
+func RoundRobin[T any, K cmp.Ordered](items []T, limit int, key func(T) K) []T {
+	if limit < 1 || len(items) <= limit {
+		return items
+	}
+
+	groups := make(map[K][]T)
+	var keys []K
+	Each(items, func(item T) {
+		k := key(item)
+		if _, ok := groups[k]; !ok {
+			keys = append(keys, k)
+		}
+		groups[k] = append(groups[k], item)
+	})
+	slices.Sort(keys)
+
+	result := make([]T, 0, limit)
+	for round := 0; len(result) < limit; round++ {
+		for _, k := range keys {
+			if round < len(groups[k]) && len(result) < limit {
+				result = append(result, groups[k][round])
+			}
+		}
+	}
+	return result
+}
@@ -0,0 +1,122 @@
+/*
+MIT License
+
+Copyright (c) 2024 Norihiro Seto
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package generics
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func isEven(i int) bool { return i%2 == 0 }
+
+func TestFilter(t *testing.T) {
+	tests := []struct {
+		name     string
+		items    []int
+		expected []int
+	}{
+		{"Empty", []int{}, nil},
+		{"Nil", nil, nil},
+		{"AllMatch", []int{2, 4, 6}, []int{2, 4, 6}},
+		{"NoneMatch", []int{1, 3, 5}, nil},
+		{"SomeMatch", []int{1, 2, 3, 4}, []int{2, 4}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, Filter(tt.items, isEven))
+		})
+	}
+}
+
+func TestPartition(t *testing.T) {
+	tests := []struct {
+		name  string
+		items []int
+		yes   []int
+		no    []int
+	}{
+		{"Empty", []int{}, nil, nil},
+		{"Nil", nil, nil, nil},
+		{"AllMatch", []int{2, 4, 6}, []int{2, 4, 6}, nil},
+		{"NoneMatch", []int{1, 3, 5}, nil, []int{1, 3, 5}},
+		{"SomeMatch", []int{1, 2, 3, 4}, []int{2, 4}, []int{1, 3}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			yes, no := Partition(tt.items, isEven)
+			assert.Equal(t, tt.yes, yes)
+			assert.Equal(t, tt.no, no)
+		})
+	}
+}
+
+func TestFind(t *testing.T) {
+	tests := []struct {
+		name     string
+		items    []int
+		expected int
+		found    bool
+	}{
+		{"Empty", []int{}, 0, false},
+		{"Nil", nil, 0, false},
+		{"AllMatch", []int{2, 4, 6}, 2, true},
+		{"NoneMatch", []int{1, 3, 5}, 0, false},
+		{"SomeMatch", []int{1, 3, 4, 6}, 4, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, found := Find(tt.items, isEven)
+			assert.Equal(t, tt.expected, got)
+			assert.Equal(t, tt.found, found)
+		})
+	}
+}
+
+func TestReduce(t *testing.T) {
+	sum := func(acc, i int) int { return acc + i }
+	assert.Equal(t, 0, Reduce([]int{}, 0, sum))
+	assert.Equal(t, 10, Reduce(nil, 10, sum))
+	assert.Equal(t, 15, Reduce([]int{1, 2, 3, 4, 5}, 0, sum))
+
+	concat := func(acc string, s string) string { return acc + s }
+	assert.Equal(t, "abc", Reduce([]string{"a", "b", "c"}, "", concat))
+	assert.Equal(t, ">ab", Reduce([]string{"a", "b"}, ">", concat))
+
+	count := func(acc int, s string) int { return acc + len(s) }
+	assert.Equal(t, 5, Reduce([]string{"ab", "cde"}, 0, count))
+}
+
+func TestRoundRobin(t *testing.T) {
+	items := []string{"a1", "a2", "a3", "b1", "c1", "c2"}
+	key := func(s string) string { return s[:1] }
+
+	assert.Equal(t, items, RoundRobin(items, 0, key))
+	assert.Equal(t, items, RoundRobin(items, 6, key))
+	assert.Equal(t, []string{"a1", "b1", "c1", "a2"}, RoundRobin(items, 4, key))
+}
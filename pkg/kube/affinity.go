@@ -0,0 +1,75 @@
+/*
+MIT License
+
+Copyright (c) 2024 Norihiro Seto
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package kube
+
+import (
+	"github.com/norseto/k8s-watchdogs/pkg/generics"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// SatisfiesPodAntiAffinity checks if the pod can be placed on the node without violating
+// its required pod anti-affinity against the already placed pods.
+// nodes is used to look up the topology domains of the placed pods.
+// Terms with an invalid label selector or a topology key the node does not have are ignored.
+// Only pods in the same namespace as the pod are considered unless the term names namespaces.
+func SatisfiesPodAntiAffinity(pod *corev1.Pod, node *corev1.Node, placed []*corev1.Pod, nodes []*corev1.Node) bool {
+	if pod.Spec.Affinity == nil || pod.Spec.Affinity.PodAntiAffinity == nil {
+		return true
+	}
+	nodeMap := generics.MakItemMap(nodes, func(n *corev1.Node) string { return n.Name })
+	for _, term := range pod.Spec.Affinity.PodAntiAffinity.RequiredDuringSchedulingIgnoredDuringExecution {
+		domain, ok := node.Labels[term.TopologyKey]
+		if !ok {
+			continue
+		}
+		selector, err := metav1.LabelSelectorAsSelector(term.LabelSelector)
+		if err != nil {
+			continue
+		}
+		for _, other := range placed {
+			if other.UID == pod.UID && other.Name == pod.Name {
+				continue
+			}
+			if !inTermNamespaces(pod, other, term) || !selector.Matches(labels.Set(other.Labels)) {
+				continue
+			}
+			otherNode, ok := nodeMap[other.Spec.NodeName]
+			if ok && otherNode.Labels[term.TopologyKey] == domain {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// inTermNamespaces checks if the other pod is in the namespaces of the affinity term.
+func inTermNamespaces(pod, other *corev1.Pod, term corev1.PodAffinityTerm) bool {
+	if len(term.Namespaces) == 0 {
+		return other.Namespace == pod.Namespace
+	}
+	return generics.Contains(other.Namespace, term.Namespaces)
+}
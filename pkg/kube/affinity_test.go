@@ -0,0 +1,81 @@
+/*
+MIT License
+
+Copyright (c) 2024 Norihiro Seto
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package kube
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestSatisfiesPodAntiAffinity(t *testing.T) {
+	const hostname = "kubernetes.io/hostname"
+	const zone = "topology.kubernetes.io/zone"
+	newNode := func(name, z string) *corev1.Node {
+		return &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: name,
+			Labels: map[string]string{hostname: name, zone: z}}}
+	}
+	newPod := func(name, node, app string) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", Labels: map[string]string{"app": app}},
+			Spec:       corev1.PodSpec{NodeName: node},
+		}
+	}
+	withAntiAffinity := func(pod *corev1.Pod, topologyKey string) *corev1.Pod {
+		pod.Spec.Affinity = &corev1.Affinity{PodAntiAffinity: &corev1.PodAntiAffinity{
+			RequiredDuringSchedulingIgnoredDuringExecution: []corev1.PodAffinityTerm{{
+				LabelSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}},
+				TopologyKey:   topologyKey,
+			}},
+		}}
+		return pod
+	}
+
+	nodes := []*corev1.Node{newNode("node-1", "a"), newNode("node-2", "a"), newNode("node-3", "b")}
+	placed := []*corev1.Pod{newPod("web-1", "node-1", "web"), newPod("db-1", "node-3", "db")}
+
+	tests := []struct {
+		name     string
+		pod      *corev1.Pod
+		node     *corev1.Node
+		expected bool
+	}{
+		{"NoAffinity", newPod("web-2", "", "web"), nodes[0], true},
+		{"SameHost", withAntiAffinity(newPod("web-2", "", "web"), hostname), nodes[0], false},
+		{"OtherHost", withAntiAffinity(newPod("web-2", "", "web"), hostname), nodes[1], true},
+		{"SameZone", withAntiAffinity(newPod("web-2", "", "web"), zone), nodes[1], false},
+		{"OtherZone", withAntiAffinity(newPod("web-2", "", "web"), zone), nodes[2], true},
+		{"Itself", withAntiAffinity(newPod("web-1", "node-1", "web"), hostname), nodes[0], true},
+		{"MissingTopologyKey", withAntiAffinity(newPod("web-2", "", "web"), "example.com/rack"), nodes[0], true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, SatisfiesPodAntiAffinity(tt.pod, tt.node, placed, nodes))
+		})
+	}
+}
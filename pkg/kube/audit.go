@@ -0,0 +1,61 @@
+/*
+MIT License
+
+Copyright (c) 2024 Norihiro Seto
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package kube
+
+import (
+	"context"
+
+	"github.com/norseto/k8s-watchdogs/pkg/logger"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// AuditDeletionMessage is the message of the audit log entry of a pod deletion.
+const AuditDeletionMessage = "audit: deleting pod"
+
+// AuditPodDeletion logs an audit entry of the pod deletion at info level.
+// It should be called right before the pod is deleted so that every command
+// records the same set of fields.
+func AuditPodDeletion(ctx context.Context, pod *corev1.Pod, reason, command string) {
+	logger.FromContext(ctx).Info(AuditDeletionMessage, AuditFields(pod, reason, command)...)
+}
+
+// AuditFields returns the key and value pairs of the audit log entry of the pod deletion.
+// The owner is the controller of the pod in the form of kind/name, or empty if not controlled.
+func AuditFields(pod *corev1.Pod, reason, command string) []any {
+	owner := ""
+	if ref := metav1.GetControllerOf(pod); ref != nil {
+		owner = ref.Kind + "/" + ref.Name
+	}
+	return []any{
+		"uid", string(pod.UID),
+		"namespace", pod.Namespace,
+		"name", pod.Name,
+		"node", pod.Spec.NodeName,
+		"owner", owner,
+		"reason", reason,
+		"command", command,
+	}
+}
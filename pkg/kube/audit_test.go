@@ -0,0 +1,63 @@
+/*
+MIT License
+
+Copyright (c) 2024 Norihiro Seto
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package kube
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-logr/logr/funcr"
+	"github.com/norseto/k8s-watchdogs/pkg/logger"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestAuditPodDeletion(t *testing.T) {
+	controller := true
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "default", Name: "web-1", UID: "uid-1",
+			OwnerReferences: []metav1.OwnerReference{
+				{Kind: "Node", Name: "node-1"},
+				{Kind: "ReplicaSet", Name: "web", Controller: &controller},
+			},
+		},
+		Spec: corev1.PodSpec{NodeName: "node-1"},
+	}
+
+	var lines []string
+	log := funcr.New(func(prefix, args string) { lines = append(lines, args) }, funcr.Options{})
+	ctx := logger.WithContext(context.Background(), log)
+
+	AuditPodDeletion(ctx, pod, "evicted", "clean-evicted")
+	assert.Equal(t, []string{`"level"=0 "msg"="audit: deleting pod" "uid"="uid-1" "namespace"="default" ` +
+		`"name"="web-1" "node"="node-1" "owner"="ReplicaSet/web" "reason"="evicted" "command"="clean-evicted"`}, lines)
+
+	pod.OwnerReferences = nil
+	fields := AuditFields(pod, "oldest", "delete-oldest")
+	assert.Equal(t, []any{"uid", "uid-1", "namespace", "default", "name", "web-1", "node", "node-1",
+		"owner", "", "reason", "oldest", "command", "delete-oldest"}, fields)
+}
@@ -35,29 +35,78 @@ import (
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
 )
 
 // Options represents the configuration options for a kubernetes client.
 type Options struct {
 	configFilePath string
+	token          string
+	server         string
+	insecure       bool
+	caFile         string
+	as             string
+	asGroups       []string
 }
 
+const (
+	tokenUsage    = "bearer token for authentication to the API server. Requires --server"
+	serverUsage   = "address and port of the Kubernetes API server"
+	insecureUsage = "if true, the server's certificate will not be checked for validity when using --token"
+	caUsage       = "path to a cert file for the certificate authority of the API server when using --token"
+	asUsage       = "username to impersonate for the operation. RBAC must allow the user to impersonate it"
+	asGroupUsage  = "group to impersonate for the operation, this flag can be repeated to specify multiple groups. Requires --as"
+	allowUsage    = "absolute path prefix a kubeconfig file must be under, this flag can be repeated to allow multiple prefixes"
+	denyUsage     = "absolute path prefix a kubeconfig file must not be under in addition to /proc and /sys, " +
+		"this flag can be repeated to deny multiple prefixes"
+	fallbackUsage = "absolute path of a kubeconfig file used when neither --kubeconfig, KUBECONFIG nor HOME is set, " +
+		"e.g. in distroless containers. This flag can be repeated and the first valid file is used"
+)
+
 // BindFlags adds the "kubeconfig" flag to the given FlagSet.
 // It binds the value of the flag to the configFilePath field of the Options struct.
 // The flag is used to specify the absolute path to the kubeconfig file.
+// It also adds the "token", "server", "insecure-skip-tls-verify", "certificate-authority",
+// "as", "as-group", "kubeconfig-allow-prefix", "kubeconfig-deny-prefix" and "kubeconfig-fallback" flags.
 func (o *Options) BindFlags(fs *flag.FlagSet) {
 	fs.StringVar(&o.configFilePath, "kubeconfig", "", "absolute path to the kubeconfig file")
+	fs.StringVar(&o.token, "token", "", tokenUsage)
+	fs.StringVar(&o.server, "server", "", serverUsage)
+	fs.BoolVar(&o.insecure, "insecure-skip-tls-verify", false, insecureUsage)
+	fs.StringVar(&o.caFile, "certificate-authority", "", caUsage)
+	fs.StringVar(&o.as, "as", "", asUsage)
+	fs.Func("as-group", asGroupUsage, func(v string) error {
+		o.asGroups = append(o.asGroups, v)
+		return nil
+	})
+	fs.Var(newPrefixListValue(nil, SetPathPrefixAllowList), "kubeconfig-allow-prefix", allowUsage)
+	fs.Var(newPrefixListValue(defaultPathDenyList, SetPathPrefixDenyList), "kubeconfig-deny-prefix", denyUsage)
+	fs.Var(newPrefixListValue(nil, SetFallbackConfigPaths), "kubeconfig-fallback", fallbackUsage)
 }
 
 // BindPFlags adds the "kubeconfig" flag to the given FlagSet.
 // It binds the value of the flag to the configFilePath field of the Options struct.
 // The flag is used to specify the absolute path to the kubeconfig file.
+// It also adds the "token", "server", "insecure-skip-tls-verify", "certificate-authority",
+// "as", "as-group", "kubeconfig-allow-prefix", "kubeconfig-deny-prefix" and "kubeconfig-fallback" flags.
 func (o *Options) BindPFlags(fs *pflag.FlagSet) {
 	fs.StringVar(&o.configFilePath, "kubeconfig", "", "absolute path to the kubeconfig file")
 	_ = fs.MarkHidden("kubeconfig")
+	fs.StringVar(&o.token, "token", "", tokenUsage)
+	fs.StringVar(&o.server, "server", "", serverUsage)
+	fs.BoolVar(&o.insecure, "insecure-skip-tls-verify", false, insecureUsage)
+	fs.StringVar(&o.caFile, "certificate-authority", "", caUsage)
+	fs.StringVar(&o.as, "as", "", asUsage)
+	fs.StringArrayVar(&o.asGroups, "as-group", nil, asGroupUsage)
+	fs.Var(newPrefixListValue(nil, SetPathPrefixAllowList), "kubeconfig-allow-prefix", allowUsage)
+	fs.Var(newPrefixListValue(defaultPathDenyList, SetPathPrefixDenyList), "kubeconfig-deny-prefix", denyUsage)
+	fs.Var(newPrefixListValue(nil, SetFallbackConfigPaths), "kubeconfig-fallback", fallbackUsage)
 }
 
 // GetConfigFilePath retrieves the kubeconfig file path.
+// It is the kubeconfig flag, KUBECONFIG or $HOME/.kube/config in this order.
+// Without HOME, the first valid path set with SetFallbackConfigPaths, e.g. by the
+// "kubeconfig-fallback" flag, is used if any.
 func (o *Options) GetConfigFilePath() string {
 	if o.configFilePath != "" {
 		return o.configFilePath
@@ -69,7 +118,7 @@ func (o *Options) GetConfigFilePath() string {
 		path := filepath.Join(home, ".kube", "config")
 		return path
 	}
-	return ""
+	return fallbackConfigPath()
 }
 
 type contextKey struct{}
@@ -93,14 +142,49 @@ func WithContext(ctx context.Context, opts *Options) context.Context {
 
 // NewRESTConfig creates a new Kubernetes REST config based on the provided options.
 // It takes an `opts` pointer to an `Options` struct which contains the path to the kubeconfig file.
+// If the `opts` contains both a token and a server, the config is built directly from them
+// without reading any kubeconfig file. A token without a server is an error.
+// The certificate authority file is validated like a kubeconfig file and cannot be combined
+// with insecure-skip-tls-verify.
 // If the `opts` contains a non-empty kubeconfig file path, it uses `clientcmd.BuildConfigFromFlags` to build the config.
+// If the path is a list of kubeconfig files, each file is validated with ValidateConfigPath
+// and the files are merged in order like kubectl does.
 // If the config is not specified or there is an error building it, it falls back to using `rest.InClusterConfig`.
+// If the `opts` contains a user to impersonate, it is set to the created config.
+// RBAC must permit the authenticated user to impersonate the user and groups.
 // The function returns the created REST config and an error if there was a failure.
-func NewRESTConfig(opts *Options) (config *rest.Config, err error) {
+func NewRESTConfig(opts *Options) (*rest.Config, error) {
+	if len(opts.asGroups) > 0 && opts.as == "" {
+		return nil, fmt.Errorf("--as-group requires --as")
+	}
+	config, err := newRESTConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+	if opts.as != "" {
+		config.Impersonate = rest.ImpersonationConfig{UserName: opts.as, Groups: opts.asGroups}
+	}
+	return config, nil
+}
+
+// newRESTConfig creates a new Kubernetes REST config without impersonation.
+func newRESTConfig(opts *Options) (config *rest.Config, err error) {
+	if opts.token != "" {
+		if opts.server == "" {
+			return nil, fmt.Errorf("--token requires --server")
+		}
+		return newTokenRESTConfig(opts)
+	}
+
 	kubeconfig := opts.GetConfigFilePath()
 
-	if kubeconfig != "" {
-		config, err = clientcmd.BuildConfigFromFlags("", kubeconfig)
+	if paths := filepath.SplitList(kubeconfig); len(paths) > 1 {
+		config, err = newMergedRESTConfig(paths, opts.server)
+		if err != nil {
+			return nil, err
+		}
+	} else if kubeconfig != "" {
+		config, err = clientcmd.BuildConfigFromFlags(opts.server, kubeconfig)
 	}
 
 	if config == nil || err != nil {
@@ -109,6 +193,43 @@ func NewRESTConfig(opts *Options) (config *rest.Config, err error) {
 	return
 }
 
+// newMergedRESTConfig creates a REST config by merging the given kubeconfig files.
+// Earlier files take precedence over later ones.
+func newMergedRESTConfig(paths []string, server string) (*rest.Config, error) {
+	for _, path := range paths {
+		if err := ValidateConfigPath(path); err != nil {
+			return nil, err
+		}
+	}
+	rules := &clientcmd.ClientConfigLoadingRules{Precedence: paths}
+	overrides := &clientcmd.ConfigOverrides{ClusterInfo: clientcmdapi.Cluster{Server: server}}
+	config, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, overrides).ClientConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load kubeconfig files %v: %w", paths, err)
+	}
+	return config, nil
+}
+
+// newTokenRESTConfig creates a REST config from the token, server and TLS settings in the options.
+func newTokenRESTConfig(opts *Options) (*rest.Config, error) {
+	if opts.caFile != "" {
+		if opts.insecure {
+			return nil, fmt.Errorf("--certificate-authority cannot be used with --insecure-skip-tls-verify")
+		}
+		if err := ValidateConfigPath(opts.caFile); err != nil {
+			return nil, fmt.Errorf("invalid certificate authority: %w", err)
+		}
+	}
+	return &rest.Config{
+		Host:        opts.server,
+		BearerToken: opts.token,
+		TLSClientConfig: rest.TLSClientConfig{
+			Insecure: opts.insecure,
+			CAFile:   opts.caFile,
+		},
+	}, nil
+}
+
 // NewClientset creates a new Kubernetes clientset.
 // It takes an `opts` pointer to an `Options` struct which contains the path to the kubeconfig file.
 // It returns a `*kubernetes.Clientset` and an `error` if there was a failure.
@@ -27,7 +27,9 @@ package client
 import (
 	"context"
 	"flag"
+	"fmt"
 	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -132,3 +134,129 @@ func TestWithContext(t *testing.T) {
 		t.Errorf("Expected nil options, but got %v", value)
 	}
 }
+
+func TestNewRESTConfig_Token(t *testing.T) {
+	t.Run("token and server", func(t *testing.T) {
+		opts := &Options{token: "secret", server: "https://example.com:6443", insecure: true}
+		config, err := NewRESTConfig(opts)
+		if err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+		if config.Host != "https://example.com:6443" || config.BearerToken != "secret" || !config.Insecure {
+			t.Errorf("Unexpected config %+v", config)
+		}
+	})
+
+	t.Run("token without server", func(t *testing.T) {
+		opts := &Options{token: "secret"}
+		if _, err := NewRESTConfig(opts); err == nil {
+			t.Errorf("Expected error, but got nil")
+		}
+	})
+
+	t.Run("bind flags", func(t *testing.T) {
+		opts := &Options{}
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		opts.BindFlags(fs)
+		err := fs.Parse([]string{"--token=secret", "--server=https://example.com", "--insecure-skip-tls-verify"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if opts.token != "secret" || opts.server != "https://example.com" || !opts.insecure {
+			t.Errorf("Unexpected options %+v", opts)
+		}
+	})
+}
+
+func TestNewRESTConfig_MultipleKubeconfig(t *testing.T) {
+	dir := t.TempDir()
+	first := writeKubeconfig(t, dir, "first", fmt.Sprintf(testKubeconfig, "first")+"current-context: first\n")
+	second := writeKubeconfig(t, dir, "second", fmt.Sprintf(testKubeconfig, "second")+"current-context: second\n")
+	t.Setenv("KUBECONFIG", first+string(filepath.ListSeparator)+second)
+
+	config, err := NewRESTConfig(&Options{})
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	if config.Host != "https://first.example.com" || config.BearerToken != "first-token" {
+		t.Errorf("Unexpected config %+v", config)
+	}
+
+	t.Setenv("KUBECONFIG", first+string(filepath.ListSeparator)+filepath.Join(dir, "missing"))
+	if _, err := NewRESTConfig(&Options{}); err == nil {
+		t.Errorf("Expected error for missing kubeconfig, but got nil")
+	}
+}
+
+func TestNewRESTConfig_Impersonate(t *testing.T) {
+	base := Options{token: "secret", server: "https://example.com"}
+
+	opts := base
+	opts.as, opts.asGroups = "alice", []string{"dev", "ops"}
+	config, err := NewRESTConfig(&opts)
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	if config.Impersonate.UserName != "alice" || len(config.Impersonate.Groups) != 2 {
+		t.Errorf("Unexpected impersonation %+v", config.Impersonate)
+	}
+
+	opts = base
+	opts.asGroups = []string{"dev"}
+	if _, err := NewRESTConfig(&opts); err == nil {
+		t.Errorf("Expected error for --as-group without --as, but got nil")
+	}
+
+	opts = Options{}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	opts.BindFlags(fs)
+	if err := fs.Parse([]string{"--as=alice", "--as-group=dev", "--as-group=ops"}); err != nil {
+		t.Fatal(err)
+	}
+	if opts.as != "alice" || len(opts.asGroups) != 2 || opts.asGroups[1] != "ops" {
+		t.Errorf("Unexpected options %+v", opts)
+	}
+}
+
+func TestNewRESTConfig_CertificateAuthority(t *testing.T) {
+	dir := t.TempDir()
+	ca := writeKubeconfig(t, dir, "ca.crt", "dummy")
+	base := Options{token: "secret", server: "https://example.com"}
+
+	opts := base
+	opts.caFile = ca
+	config, err := NewRESTConfig(&opts)
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	if config.CAFile != ca || config.Insecure {
+		t.Errorf("Unexpected TLS config %+v", config.TLSClientConfig)
+	}
+
+	opts.insecure = true
+	if _, err := NewRESTConfig(&opts); err == nil {
+		t.Errorf("Expected error for --certificate-authority with --insecure-skip-tls-verify, but got nil")
+	}
+
+	opts = base
+	opts.caFile = filepath.Join(dir, "missing.crt")
+	if _, err := NewRESTConfig(&opts); err == nil {
+		t.Errorf("Expected error for missing certificate authority, but got nil")
+	}
+
+	opts = base
+	opts.caFile = "/proc/self/status"
+	if _, err := NewRESTConfig(&opts); err == nil {
+		t.Errorf("Expected error for denied certificate authority path, but got nil")
+	}
+
+	opts = Options{}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	opts.BindFlags(fs)
+	if err := fs.Parse([]string{"--certificate-authority=" + ca}); err != nil {
+		t.Fatal(err)
+	}
+	if opts.caFile != ca {
+		t.Errorf("Unexpected options %+v", opts)
+	}
+}
@@ -0,0 +1,181 @@
+/*
+MIT License
+
+Copyright (c) 2024 Norihiro Seto
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package client
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// defaultPathDenyList is the path prefixes a kubeconfig file must not be under by default.
+var defaultPathDenyList = []string{"/proc", "/sys"}
+
+var (
+	pathMu        sync.RWMutex
+	pathAllowList []string
+	pathDenyList  = defaultPathDenyList
+	fallbackPaths []string
+)
+
+// SetPathPrefixAllowList sets the path prefixes a kubeconfig file must be under.
+// An empty list allows every path that is not denied.
+func SetPathPrefixAllowList(prefixes []string) {
+	pathMu.Lock()
+	defer pathMu.Unlock()
+	pathAllowList = append([]string(nil), prefixes...)
+}
+
+// SetPathPrefixDenyList sets the path prefixes a kubeconfig file must not be under.
+// It defaults to /proc and /sys.
+func SetPathPrefixDenyList(prefixes []string) {
+	pathMu.Lock()
+	defer pathMu.Unlock()
+	pathDenyList = append([]string(nil), prefixes...)
+}
+
+// prefixListValue is a repeatable flag value of absolute paths, such as path prefixes.
+// Each time the flag is set, the base paths followed by the paths given so far are passed to apply.
+type prefixListValue struct {
+	base     []string
+	prefixes []string
+	apply    func([]string)
+}
+
+// newPrefixListValue returns a prefixListValue that applies the prefixes with the setter.
+func newPrefixListValue(base []string, apply func([]string)) *prefixListValue {
+	return &prefixListValue{base: base, apply: apply}
+}
+
+// Set validates that the path is absolute and applies it.
+func (v *prefixListValue) Set(prefix string) error {
+	if !filepath.IsAbs(prefix) {
+		return fmt.Errorf("path %q must be absolute", prefix)
+	}
+	v.prefixes = append(v.prefixes, filepath.Clean(prefix))
+	v.apply(append(append([]string(nil), v.base...), v.prefixes...))
+	return nil
+}
+
+// String returns the prefixes given so far separated by commas.
+func (v *prefixListValue) String() string {
+	if v == nil {
+		return ""
+	}
+	return strings.Join(v.prefixes, ",")
+}
+
+// Type returns the type name of the flag value shown in the usage.
+func (v *prefixListValue) Type() string {
+	return "stringArray"
+}
+
+// SetFallbackConfigPaths sets the kubeconfig file candidates used when neither the kubeconfig
+// flag, KUBECONFIG nor HOME is set, e.g. in distroless containers. Candidates are tried in
+// order and the first one that passes ValidateConfigPath is used. It defaults to none and is
+// set by the "kubeconfig-fallback" flag.
+func SetFallbackConfigPaths(paths []string) {
+	pathMu.Lock()
+	defer pathMu.Unlock()
+	fallbackPaths = append([]string(nil), paths...)
+}
+
+// fallbackConfigPath returns the first fallback kubeconfig file that passes ValidateConfigPath,
+// or an empty string if none does.
+func fallbackConfigPath() string {
+	pathMu.RLock()
+	candidates := fallbackPaths
+	pathMu.RUnlock()
+
+	for _, path := range candidates {
+		if ValidateConfigPath(path) == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+// ValidateConfigPath checks that the kubeconfig file path is not under any denied prefix,
+// is under one of the allowed prefixes if any are set, and is a regular file.
+func ValidateConfigPath(path string) error {
+	return validatePath("kubeconfig", path)
+}
+
+// ValidateFilePath checks the path of a file read by a command, such as a list of targets,
+// in the same way as ValidateConfigPath.
+func ValidateFilePath(path string) error {
+	return validatePath("file", path)
+}
+
+// validatePath checks the path against the allowed and denied prefixes and that it is a regular file.
+// The kind describes the file in the error messages.
+func validatePath(kind, path string) error {
+	if path == "" {
+		return fmt.Errorf("%s path must not be empty", kind)
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return fmt.Errorf("invalid %s path %q: %w", kind, path, err)
+	}
+
+	pathMu.RLock()
+	allow, deny := pathAllowList, pathDenyList
+	pathMu.RUnlock()
+
+	for _, prefix := range deny {
+		if hasPathPrefix(abs, prefix) {
+			return fmt.Errorf("%s path %q is under denied prefix %q", kind, path, prefix)
+		}
+	}
+	if len(allow) > 0 {
+		allowed := false
+		for _, prefix := range allow {
+			if hasPathPrefix(abs, prefix) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return fmt.Errorf("%s path %q is not under any allowed prefix", kind, path)
+		}
+	}
+
+	info, err := os.Stat(abs)
+	if err != nil {
+		return fmt.Errorf("invalid %s path %q: %w", kind, path, err)
+	}
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("%s path %q is not a regular file", kind, path)
+	}
+	return nil
+}
+
+// hasPathPrefix checks if path is prefix itself or under the prefix directory.
+func hasPathPrefix(path, prefix string) bool {
+	prefix = filepath.Clean(prefix)
+	return path == prefix || strings.HasPrefix(path, strings.TrimSuffix(prefix, string(filepath.Separator))+string(filepath.Separator))
+}
@@ -0,0 +1,184 @@
+/*
+MIT License
+
+Copyright (c) 2024 Norihiro Seto
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package client
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/pflag"
+)
+
+const testKubeconfig = `apiVersion: v1
+kind: Config
+clusters:
+- name: %[1]s
+  cluster:
+    server: https://%[1]s.example.com
+users:
+- name: %[1]s
+  user:
+    token: %[1]s-token
+contexts:
+- name: %[1]s
+  context:
+    cluster: %[1]s
+    user: %[1]s
+`
+
+func writeKubeconfig(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestValidateConfigPath(t *testing.T) {
+	dir := t.TempDir()
+	file := writeKubeconfig(t, dir, "config", "")
+	defer SetPathPrefixAllowList(nil)
+	defer SetPathPrefixDenyList([]string{"/proc", "/sys"})
+
+	tests := []struct {
+		name    string
+		allow   []string
+		deny    []string
+		path    string
+		wantErr bool
+	}{
+		{"Valid", nil, []string{"/proc", "/sys"}, file, false},
+		{"Empty", nil, nil, "", true},
+		{"Missing", nil, nil, filepath.Join(dir, "missing"), true},
+		{"Directory", nil, nil, dir, true},
+		{"Denied", nil, []string{dir}, file, true},
+		{"DeniedDefault", nil, []string{"/proc", "/sys"}, "/proc/self/environ", true},
+		{"Allowed", []string{dir}, nil, file, false},
+		{"NotAllowed", []string{filepath.Join(dir, "other")}, nil, file, true},
+		{"SiblingPrefix", []string{dir + "-other"}, nil, file, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			SetPathPrefixAllowList(tt.allow)
+			SetPathPrefixDenyList(tt.deny)
+			err := ValidateConfigPath(tt.path)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateConfigPath(%q) error = %v, wantErr %v", tt.path, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestGetConfigFilePath_Fallback(t *testing.T) {
+	dir := t.TempDir()
+	fallback := writeKubeconfig(t, dir, "config", "")
+	t.Setenv("KUBECONFIG", "")
+	t.Setenv("HOME", "")
+	defer SetFallbackConfigPaths(nil)
+
+	opts := &Options{}
+	if got := opts.GetConfigFilePath(); got != "" {
+		t.Errorf("expected no path without fallbacks, got %q", got)
+	}
+
+	SetFallbackConfigPaths([]string{filepath.Join(dir, "missing"), dir, fallback})
+	if got := opts.GetConfigFilePath(); got != fallback {
+		t.Errorf("expected %q, got %q", fallback, got)
+	}
+
+	SetPathPrefixDenyList([]string{dir})
+	defer SetPathPrefixDenyList([]string{"/proc", "/sys"})
+	if got := opts.GetConfigFilePath(); got != "" {
+		t.Errorf("expected denied fallback to be skipped, got %q", got)
+	}
+
+	t.Setenv("HOME", "/home/mock")
+	if got := opts.GetConfigFilePath(); got != "/home/mock/.kube/config" {
+		t.Errorf("expected HOME to take precedence, got %q", got)
+	}
+}
+
+func TestBindPFlags_PathPrefixes(t *testing.T) {
+	dir := t.TempDir()
+	file := writeKubeconfig(t, dir, "config", "")
+	defer SetPathPrefixAllowList(nil)
+	defer SetPathPrefixDenyList([]string{"/proc", "/sys"})
+
+	fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	(&Options{}).BindPFlags(fs)
+	if err := fs.Parse([]string{"--kubeconfig-allow-prefix=relative"}); err == nil {
+		t.Errorf("expected relative allow prefix to be rejected")
+	}
+
+	fs = pflag.NewFlagSet("test", pflag.ContinueOnError)
+	(&Options{}).BindPFlags(fs)
+	if err := fs.Parse([]string{"--kubeconfig-allow-prefix=" + filepath.Join(dir, "other")}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ValidateConfigPath(file); err == nil {
+		t.Errorf("expected %q not to be allowed", file)
+	}
+	if err := fs.Parse([]string{"--kubeconfig-allow-prefix=" + dir}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ValidateConfigPath(file); err != nil {
+		t.Errorf("expected %q to be allowed, got %v", file, err)
+	}
+
+	if err := fs.Parse([]string{"--kubeconfig-deny-prefix=" + dir}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ValidateConfigPath(file); err == nil {
+		t.Errorf("expected %q to be denied", file)
+	}
+	if got := pathDenyList; len(got) != 3 || got[0] != "/proc" || got[1] != "/sys" || got[2] != dir {
+		t.Errorf("expected the default deny list to be kept, got %v", got)
+	}
+}
+
+func TestBindPFlags_Fallback(t *testing.T) {
+	dir := t.TempDir()
+	fallback := writeKubeconfig(t, dir, "config", "")
+	t.Setenv("KUBECONFIG", "")
+	t.Setenv("HOME", "")
+	defer SetFallbackConfigPaths(nil)
+
+	fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	opts := &Options{}
+	opts.BindPFlags(fs)
+	if err := fs.Parse([]string{"--kubeconfig-fallback=relative"}); err == nil {
+		t.Errorf("expected relative fallback to be rejected")
+	}
+	args := []string{"--kubeconfig-fallback=" + filepath.Join(dir, "missing"), "--kubeconfig-fallback=" + fallback}
+	if err := fs.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	if got := opts.GetConfigFilePath(); got != fallback {
+		t.Errorf("expected %q, got %q", fallback, got)
+	}
+}
@@ -26,24 +26,175 @@ package kube
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"time"
 
 	appsv1 "k8s.io/api/apps/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/kubernetes"
 )
 
 const (
-	restartPatchTemplate = `{"spec":{"template":{"metadata":{"annotations":{"kubectl.kubernetes.io/restartedAt":"%v"}}}}}`
+	restartedAtAnnotation = "kubectl.kubernetes.io/restartedAt"
+
+	// DefaultRestartAnnotationKey is the pod template annotation that records the restart time
+	// unless another key is given with WithRestartAnnotationKey.
+	DefaultRestartAnnotationKey = restartedAtAnnotation
+
+	// RestartReasonAnnotation is the pod template annotation that records why a restart happened.
+	RestartReasonAnnotation = "watchdogs.norseto.dev/restart-reason"
+
+	// DefaultRolloutPollInterval is the interval of polling the rollout status of a workload.
+	DefaultRolloutPollInterval = 2 * time.Second
 )
 
+// now returns the current time. It is replaced in tests.
+var now = time.Now
+
+// RestartOption configures a restart.
+type RestartOption func(*restartSettings)
+
+type restartSettings struct {
+	reason        string
+	annotationKey string
+	dryRun        bool
+	fieldManager  string
+}
+
+// WithRestartReason records the reason in the RestartReasonAnnotation of the pod template.
+func WithRestartReason(reason string) RestartOption {
+	return func(s *restartSettings) {
+		s.reason = reason
+	}
+}
+
+// WithRestartAnnotationKey records the restart time in the pod template annotation of the key
+// instead of DefaultRestartAnnotationKey. An empty key keeps the default.
+func WithRestartAnnotationKey(key string) RestartOption {
+	return func(s *restartSettings) {
+		s.annotationKey = key
+	}
+}
+
+// WithRestartDryRun sends the restart patch as a server-side dry run so that nothing is persisted.
+func WithRestartDryRun(dryRun bool) RestartOption {
+	return func(s *restartSettings) {
+		s.dryRun = dryRun
+	}
+}
+
+// WithRestartFieldManager sends the restart as a server-side apply owned by the field manager
+// instead of a strategic merge patch. An empty manager keeps the strategic merge patch.
+func WithRestartFieldManager(manager string) RestartOption {
+	return func(s *restartSettings) {
+		s.fieldManager = manager
+	}
+}
+
+// IsRestartDryRun checks if the options make the restart a dry run.
+func IsRestartDryRun(opts ...RestartOption) bool {
+	settings := &restartSettings{}
+	for _, opt := range opts {
+		opt(settings)
+	}
+	return settings.dryRun
+}
+
+// makeRestartPatchOptions makes the options of the restart patch.
+// A server-side apply forces the ownership of the restart annotations to the field manager.
+func makeRestartPatchOptions(opts []RestartOption) metav1.PatchOptions {
+	settings := &restartSettings{}
+	for _, opt := range opts {
+		opt(settings)
+	}
+	ret := metav1.PatchOptions{FieldManager: "kubectl-rollout"}
+	if settings.fieldManager != "" {
+		force := true
+		ret.FieldManager, ret.Force = settings.fieldManager, &force
+	}
+	if settings.dryRun {
+		ret.DryRun = []string{metav1.DryRunAll}
+	}
+	return ret
+}
+
+// makeRestartPatch makes the patch that restarts the workload of the kind with the given pod template annotations.
+// It is a strategic merge patch, or an apply patch when a field manager is given with WithRestartFieldManager.
+// It returns nil when the patch would be a no-op, that is, the restart time annotation already equals
+// the current timestamp (e.g. a rerun within the same second) and the reason is unchanged.
+func makeRestartPatch(kind string, obj metav1.Object, annotations map[string]string, opts []RestartOption) (types.PatchType, []byte, error) {
+	settings := &restartSettings{annotationKey: DefaultRestartAnnotationKey}
+	for _, opt := range opts {
+		opt(settings)
+	}
+	if settings.annotationKey == "" {
+		settings.annotationKey = DefaultRestartAnnotationKey
+	}
+
+	timestamp := now().Format(time.RFC3339)
+	patched := map[string]string{settings.annotationKey: timestamp}
+	if settings.reason != "" {
+		patched[RestartReasonAnnotation] = settings.reason
+	}
+	noop := true
+	for k, v := range patched {
+		if annotations[k] != v {
+			noop = false
+		}
+	}
+	if noop {
+		return "", nil, nil
+	}
+
+	patchType := types.StrategicMergePatchType
+	patch := map[string]any{"spec": map[string]any{"template": map[string]any{
+		"metadata": map[string]any{"annotations": patched}}}}
+	if settings.fieldManager != "" {
+		// An apply patch must identify the object it applies to.
+		patchType = types.ApplyPatchType
+		patch["apiVersion"] = appsv1.SchemeGroupVersion.String()
+		patch["kind"] = kind
+		patch["metadata"] = map[string]any{"name": obj.GetName(), "namespace": obj.GetNamespace()}
+	}
+	data, err := json.Marshal(patch)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to make restart patch: %w", err)
+	}
+	return patchType, data, nil
+}
+
 // RestartDeployment restarts a deployment by updating its template metadata annotations with the current time.
-func RestartDeployment(ctx context.Context, client kubernetes.Interface, dep *appsv1.Deployment) error {
-	data := fmt.Sprintf(restartPatchTemplate, time.Now().Format(time.RFC3339))
-	_, err := client.AppsV1().Deployments(dep.Namespace).Patch(ctx, dep.Name,
-		types.StrategicMergePatchType, []byte(data),
-		metav1.PatchOptions{FieldManager: "kubectl-rollout"})
-	return err
+// It returns false without patching when the restartedAt annotation already equals the
+// current timestamp (e.g. a rerun within the same second), since such a patch would be a no-op.
+func RestartDeployment(ctx context.Context, client kubernetes.Interface, dep *appsv1.Deployment, opts ...RestartOption) (bool, error) {
+	patchType, data, err := makeRestartPatch(kindDeployment, dep, dep.Spec.Template.Annotations, opts)
+	if data == nil || err != nil {
+		return false, err
+	}
+	_, err = client.AppsV1().Deployments(dep.Namespace).Patch(ctx, dep.Name,
+		patchType, data, makeRestartPatchOptions(opts))
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// WaitForDeploymentRollout polls the deployment every interval until its rollout completes
+// as checked by IsDeploymentRollingOut. It returns an error when the context is done first
+// or the deployment cannot be fetched.
+func WaitForDeploymentRollout(ctx context.Context, client kubernetes.Interface, namespace, name string, interval time.Duration) error {
+	err := wait.PollUntilContextCancel(ctx, interval, true, func(ctx context.Context) (bool, error) {
+		dep, err := client.AppsV1().Deployments(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		return !IsDeploymentRollingOut(dep), nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to wait for the rollout of deployment %s/%s: %w", namespace, name, err)
+	}
+	return nil
 }
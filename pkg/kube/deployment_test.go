@@ -26,13 +26,17 @@ package kube
 
 import (
 	"context"
+	"encoding/json"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 	appsv1 "k8s.io/api/apps/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
 )
 
 func TestRestartDeployment(t *testing.T) {
@@ -54,8 +58,9 @@ func TestRestartDeployment(t *testing.T) {
 	assert.NoError(t, err)
 
 	// Call the RestartDeployment function
-	err = RestartDeployment(ctx, client, dep)
+	restarted, err := RestartDeployment(ctx, client, dep)
 	assert.NoError(t, err)
+	assert.True(t, restarted)
 
 	// Get the updated deployment
 	updatedDep, err := client.AppsV1().Deployments(dep.Namespace).Get(ctx, dep.Name, metav1.GetOptions{})
@@ -73,3 +78,202 @@ func TestRestartDeployment(t *testing.T) {
 	err = client.AppsV1().Deployments(dep.Namespace).Delete(ctx, dep.Name, metav1.DeleteOptions{})
 	assert.NoError(t, err)
 }
+
+func TestRestartDeployment_NoOp(t *testing.T) {
+	ctx := context.TODO()
+	client := fake.NewSimpleClientset(&appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-deployment", Namespace: "test-namespace"},
+	})
+
+	fixed := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	now = func() time.Time { return fixed }
+	defer func() { now = time.Now }()
+
+	dep, err := client.AppsV1().Deployments("test-namespace").Get(ctx, "test-deployment", metav1.GetOptions{})
+	assert.NoError(t, err)
+	restarted, err := RestartDeployment(ctx, client, dep)
+	assert.NoError(t, err)
+	assert.True(t, restarted)
+
+	// A rerun within the same second is reported as a no-op.
+	now = func() time.Time { return fixed.Add(500 * time.Millisecond) }
+	dep, err = client.AppsV1().Deployments("test-namespace").Get(ctx, "test-deployment", metav1.GetOptions{})
+	assert.NoError(t, err)
+	client.ClearActions()
+	restarted, err = RestartDeployment(ctx, client, dep)
+	assert.NoError(t, err)
+	assert.False(t, restarted)
+	assert.Empty(t, client.Actions())
+
+	// A later restart is applied again.
+	now = func() time.Time { return fixed.Add(2 * time.Second) }
+	restarted, err = RestartDeployment(ctx, client, dep)
+	assert.NoError(t, err)
+	assert.True(t, restarted)
+}
+
+func TestRestartDeployment_Reason(t *testing.T) {
+	ctx := context.TODO()
+	client := fake.NewSimpleClientset(&appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-deployment", Namespace: "test-namespace"},
+	})
+
+	fixed := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	now = func() time.Time { return fixed }
+	defer func() { now = time.Now }()
+
+	dep, err := client.AppsV1().Deployments("test-namespace").Get(ctx, "test-deployment", metav1.GetOptions{})
+	assert.NoError(t, err)
+	client.ClearActions()
+	restarted, err := RestartDeployment(ctx, client, dep, WithRestartReason("rotate credentials"))
+	assert.NoError(t, err)
+	assert.True(t, restarted)
+
+	actions := client.Actions()
+	if assert.Len(t, actions, 1) {
+		var patch struct {
+			Spec struct {
+				Template struct {
+					Metadata struct {
+						Annotations map[string]string `json:"annotations"`
+					} `json:"metadata"`
+				} `json:"template"`
+			} `json:"spec"`
+		}
+		assert.NoError(t, json.Unmarshal(actions[0].(k8stesting.PatchAction).GetPatch(), &patch))
+		assert.Equal(t, map[string]string{
+			"kubectl.kubernetes.io/restartedAt": "2024-01-02T03:04:05Z",
+			RestartReasonAnnotation:             "rotate credentials",
+		}, patch.Spec.Template.Metadata.Annotations)
+	}
+
+	// Only a different reason within the same second is applied.
+	dep, err = client.AppsV1().Deployments("test-namespace").Get(ctx, "test-deployment", metav1.GetOptions{})
+	assert.NoError(t, err)
+	restarted, err = RestartDeployment(ctx, client, dep, WithRestartReason("rotate credentials"))
+	assert.NoError(t, err)
+	assert.False(t, restarted)
+	restarted, err = RestartDeployment(ctx, client, dep, WithRestartReason("another reason"))
+	assert.NoError(t, err)
+	assert.True(t, restarted)
+}
+
+func TestRestartDeployment_AnnotationKey(t *testing.T) {
+	ctx := context.TODO()
+	client := fake.NewSimpleClientset(&appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-deployment", Namespace: "test-namespace"},
+	})
+
+	dep, err := client.AppsV1().Deployments("test-namespace").Get(ctx, "test-deployment", metav1.GetOptions{})
+	assert.NoError(t, err)
+	restarted, err := RestartDeployment(ctx, client, dep, WithRestartAnnotationKey("example.com/restarted-at"))
+	assert.NoError(t, err)
+	assert.True(t, restarted)
+
+	dep, err = client.AppsV1().Deployments("test-namespace").Get(ctx, "test-deployment", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Contains(t, dep.Spec.Template.Annotations, "example.com/restarted-at")
+	assert.NotContains(t, dep.Spec.Template.Annotations, DefaultRestartAnnotationKey)
+
+	// An empty key keeps the default.
+	restarted, err = RestartDeployment(ctx, client, dep, WithRestartAnnotationKey(""))
+	assert.NoError(t, err)
+	assert.True(t, restarted)
+	dep, err = client.AppsV1().Deployments("test-namespace").Get(ctx, "test-deployment", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Contains(t, dep.Spec.Template.Annotations, DefaultRestartAnnotationKey)
+}
+
+func TestRestartDeployment_DryRun(t *testing.T) {
+	ctx := context.TODO()
+	client := fake.NewSimpleClientset(&appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-deployment", Namespace: "test-namespace"},
+	})
+	dep, err := client.AppsV1().Deployments("test-namespace").Get(ctx, "test-deployment", metav1.GetOptions{})
+	assert.NoError(t, err)
+
+	assert.False(t, IsRestartDryRun())
+	assert.True(t, IsRestartDryRun(WithRestartDryRun(true)))
+
+	client.ClearActions()
+	_, err = RestartDeployment(ctx, client, dep, WithRestartDryRun(true))
+	assert.NoError(t, err)
+	actions := client.Actions()
+	if assert.Len(t, actions, 1) {
+		opts := actions[0].(k8stesting.PatchActionImpl).GetPatchOptions()
+		assert.Equal(t, []string{metav1.DryRunAll}, opts.DryRun)
+	}
+
+	client.ClearActions()
+	_, err = RestartDeployment(ctx, client, dep)
+	assert.NoError(t, err)
+	actions = client.Actions()
+	if assert.Len(t, actions, 1) {
+		assert.Empty(t, actions[0].(k8stesting.PatchActionImpl).GetPatchOptions().DryRun)
+	}
+}
+
+func TestWaitForDeploymentRollout(t *testing.T) {
+	replicas := int32(2)
+	dep := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default", Generation: 2},
+		Spec:       appsv1.DeploymentSpec{Replicas: &replicas},
+		Status: appsv1.DeploymentStatus{
+			ObservedGeneration: 2, Replicas: 2, UpdatedReplicas: 2, AvailableReplicas: 2,
+		},
+	}
+	client := fake.NewSimpleClientset(dep)
+	assert.NoError(t, WaitForDeploymentRollout(context.Background(), client, "default", "web", time.Millisecond))
+
+	dep.Status.UpdatedReplicas = 1
+	client = fake.NewSimpleClientset(dep)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	assert.Error(t, WaitForDeploymentRollout(ctx, client, "default", "web", time.Millisecond))
+
+	assert.Error(t, WaitForDeploymentRollout(context.Background(), client, "default", "missing", time.Millisecond))
+}
+
+func TestRestartDeployment_FieldManager(t *testing.T) {
+	ctx := context.TODO()
+	dep := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "test-deployment", Namespace: "test-namespace"}}
+	client := fake.NewSimpleClientset(dep)
+	// The tracker of the fake clientset does not support apply patches, so the patch is only recorded.
+	client.PrependReactor("patch", "deployments", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, dep, nil
+	})
+
+	restarted, err := RestartDeployment(ctx, client, dep, WithRestartFieldManager("watchdogs"), WithRestartDryRun(true))
+	assert.NoError(t, err)
+	assert.True(t, restarted)
+	actions := client.Actions()
+	if assert.Len(t, actions, 1) {
+		patch := actions[0].(k8stesting.PatchActionImpl)
+		assert.Equal(t, types.ApplyPatchType, patch.GetPatchType())
+		opts := patch.GetPatchOptions()
+		assert.Equal(t, "watchdogs", opts.FieldManager)
+		if assert.NotNil(t, opts.Force) {
+			assert.True(t, *opts.Force)
+		}
+		assert.Equal(t, []string{metav1.DryRunAll}, opts.DryRun)
+
+		var applied appsv1.Deployment
+		assert.NoError(t, json.Unmarshal(patch.GetPatch(), &applied))
+		assert.Equal(t, "apps/v1", applied.APIVersion)
+		assert.Equal(t, "Deployment", applied.Kind)
+		assert.Equal(t, "test-deployment", applied.Name)
+		assert.Equal(t, "test-namespace", applied.Namespace)
+		assert.NotEmpty(t, applied.Spec.Template.Annotations[DefaultRestartAnnotationKey])
+	}
+
+	client.ClearActions()
+	_, err = RestartDeployment(ctx, client, dep, WithRestartFieldManager(""))
+	assert.NoError(t, err)
+	actions = client.Actions()
+	if assert.Len(t, actions, 1) {
+		patch := actions[0].(k8stesting.PatchActionImpl)
+		assert.Equal(t, types.StrategicMergePatchType, patch.GetPatchType())
+		assert.Equal(t, "kubectl-rollout", patch.GetPatchOptions().FieldManager)
+		assert.Nil(t, patch.GetPatchOptions().Force)
+	}
+}
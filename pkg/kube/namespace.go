@@ -0,0 +1,100 @@
+/*
+MIT License
+
+Copyright (c) 2024 Norihiro Seto
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package kube
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/norseto/k8s-watchdogs/pkg/generics"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// GetAllNamespaceNames retrieves the names of all namespaces in the Kubernetes cluster.
+func GetAllNamespaceNames(ctx context.Context, client kubernetes.Interface) ([]string, error) {
+	all, err := client.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list namespaces: %w", err)
+	}
+	return generics.Convert(all.Items, func(ns corev1.Namespace) string { return ns.Name }, nil), nil
+}
+
+// NamespaceSet is a set of namespace names.
+type NamespaceSet map[string]struct{}
+
+// NewNamespaceSet returns a NamespaceSet of the given names. Empty names are ignored.
+func NewNamespaceSet(names ...string) NamespaceSet {
+	set := make(NamespaceSet, len(names))
+	for _, n := range names {
+		if n != "" {
+			set[n] = struct{}{}
+		}
+	}
+	return set
+}
+
+// Has checks if the set contains the namespace.
+func (s NamespaceSet) Has(ns string) bool {
+	_, ok := s[ns]
+	return ok
+}
+
+// PodMatchesNamespaceScope checks if the pod is in the namespace scope.
+// Pods in an excluded namespace never match. When include is empty, pods in
+// every other namespace match; otherwise the namespace must be included.
+func PodMatchesNamespaceScope(pod *corev1.Pod, include, exclude NamespaceSet) bool {
+	if pod == nil {
+		return false
+	}
+	return namespaceInScope(pod.Namespace, include, exclude)
+}
+
+// namespaceInScope checks if the namespace is in the scope of include and exclude.
+func namespaceInScope(ns string, include, exclude NamespaceSet) bool {
+	if exclude.Has(ns) {
+		return false
+	}
+	return len(include) == 0 || include.Has(ns)
+}
+
+// NamespaceScope restricts the namespaces targeted when operating across namespaces.
+type NamespaceScope struct {
+	// Include is the namespaces to target. Empty means all namespaces.
+	Include NamespaceSet
+	// Exclude is the namespaces never to target. It takes precedence over Include.
+	Exclude NamespaceSet
+}
+
+// Match checks if the pod is in the scope.
+func (s NamespaceScope) Match(pod *corev1.Pod) bool {
+	return PodMatchesNamespaceScope(pod, s.Include, s.Exclude)
+}
+
+// MatchNamespace checks if the namespace is in the scope.
+func (s NamespaceScope) MatchNamespace(ns string) bool {
+	return namespaceInScope(ns, s.Include, s.Exclude)
+}
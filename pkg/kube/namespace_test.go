@@ -0,0 +1,91 @@
+/*
+MIT License
+
+Copyright (c) 2024 Norihiro Seto
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package kube
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestGetAllNamespaceNames(t *testing.T) {
+	ctx := context.Background()
+	client := fake.NewSimpleClientset(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "kube-system"}},
+	)
+
+	names, err := GetAllNamespaceNames(ctx, client)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"default", "kube-system"}, names)
+}
+
+func TestPodMatchesNamespaceScope(t *testing.T) {
+	pod := func(ns string) *corev1.Pod {
+		return &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod", Namespace: ns}}
+	}
+	tests := []struct {
+		name    string
+		include NamespaceSet
+		exclude NamespaceSet
+		want    map[string]bool
+	}{
+		{
+			name: "no scope",
+			want: map[string]bool{"default": true, "kube-system": true, "app": true},
+		},
+		{
+			name:    "include only",
+			include: NewNamespaceSet("app", ""),
+			want:    map[string]bool{"default": false, "kube-system": false, "app": true},
+		},
+		{
+			name:    "exclude only",
+			exclude: NewNamespaceSet("kube-system"),
+			want:    map[string]bool{"default": true, "kube-system": false, "app": true},
+		},
+		{
+			name:    "combined",
+			include: NewNamespaceSet("app", "kube-system"),
+			exclude: NewNamespaceSet("kube-system"),
+			want:    map[string]bool{"default": false, "kube-system": false, "app": true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scope := NamespaceScope{Include: tt.include, Exclude: tt.exclude}
+			for ns, want := range tt.want {
+				assert.Equal(t, want, PodMatchesNamespaceScope(pod(ns), tt.include, tt.exclude), ns)
+				assert.Equal(t, want, scope.Match(pod(ns)), ns)
+				assert.Equal(t, want, scope.MatchNamespace(ns), ns)
+			}
+		})
+	}
+	assert.False(t, PodMatchesNamespaceScope(nil, nil, nil))
+}
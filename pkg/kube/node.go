@@ -27,18 +27,86 @@ package kube
 import (
 	"context"
 	"fmt"
+	"sort"
+	"sync"
 
 	"github.com/norseto/k8s-watchdogs/pkg/generics"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes"
 )
 
+const (
+	cordonPatch = `{"spec":{"unschedulable":true}}`
+)
+
+// NodeCache holds the nodes fetched during a single command run.
+type NodeCache struct {
+	mu     sync.Mutex
+	nodes  []*corev1.Node
+	loaded bool
+}
+
+type nodeCacheKey struct{}
+
+// WithNodeCache returns a new context holding an empty NodeCache.
+// GetAllNodes called with the returned context lists nodes only once and
+// reuses the result afterward. Call it at the start of each command run.
+func WithNodeCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, nodeCacheKey{}, &NodeCache{})
+}
+
+// nodeCacheFromContext retrieves the *NodeCache from the given context, or nil if absent.
+func nodeCacheFromContext(ctx context.Context) *NodeCache {
+	if v, ok := ctx.Value(nodeCacheKey{}).(*NodeCache); ok {
+		return v
+	}
+	return nil
+}
+
 // GetAllNodes retrieves a list of all nodes in the Kubernetes cluster.
 // It takes a context and a client as arguments.
+// If the context holds a NodeCache, the cached nodes are returned once they have been fetched.
 // It returns a slice of pointers to Node objects and an error.
 func GetAllNodes(ctx context.Context, client kubernetes.Interface) ([]*corev1.Node, error) {
-	all, err := client.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	cache := nodeCacheFromContext(ctx)
+	if cache == nil {
+		return listNodes(ctx, client, "")
+	}
+
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	if !cache.loaded {
+		nodes, err := listNodes(ctx, client, "")
+		if err != nil {
+			return nil, err
+		}
+		cache.nodes = nodes
+		cache.loaded = true
+	}
+	return generics.Convert(cache.nodes,
+		func(item *corev1.Node) *corev1.Node { return item.DeepCopy() }, nil), nil
+}
+
+// GetNodesBySelector retrieves a list of the nodes in the Kubernetes cluster that match the label selector,
+// e.g. node-role.kubernetes.io/worker. The selector is passed to the API server.
+// An empty selector retrieves all nodes with GetAllNodes. Nodes listed with a selector are not cached.
+func GetNodesBySelector(ctx context.Context, client kubernetes.Interface, selector string) ([]*corev1.Node, error) {
+	if selector == "" {
+		return GetAllNodes(ctx, client)
+	}
+	if _, err := labels.Parse(selector); err != nil {
+		return nil, fmt.Errorf("invalid node selector %q: %w", selector, err)
+	}
+	return listNodes(ctx, client, selector)
+}
+
+// listNodes lists the nodes in the Kubernetes cluster that match the label selector.
+// An empty selector lists all nodes.
+func listNodes(ctx context.Context, client kubernetes.Interface, selector string) ([]*corev1.Node, error) {
+	all, err := client.CoreV1().Nodes().List(ctx, metav1.ListOptions{LabelSelector: selector})
 	if err != nil {
 		return nil, fmt.Errorf("failed to list nodes: %w", err)
 	}
@@ -47,6 +115,54 @@ func GetAllNodes(ctx context.Context, client kubernetes.Interface) ([]*corev1.No
 	return nodes, nil
 }
 
+// CordonNode marks the node as unschedulable by patching spec.unschedulable.
+func CordonNode(ctx context.Context, client kubernetes.Interface, name string) error {
+	_, err := client.CoreV1().Nodes().Patch(ctx, name, types.StrategicMergePatchType,
+		[]byte(cordonPatch), metav1.PatchOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to cordon node: %s, %w", name, err)
+	}
+	return nil
+}
+
+// CountPodsPerNode returns the number of pods per node name. Nil pods are ignored
+// and pods not scheduled yet are counted under the empty node name.
+func CountPodsPerNode(pods []*corev1.Pod) map[string]int {
+	return generics.MakeMap(pods,
+		func(p *corev1.Pod) string { return p.Spec.NodeName },
+		func(_ *corev1.Pod, v int) int { return v + 1 },
+		func(p *corev1.Pod) bool { return p != nil })
+}
+
+// SortNodesByPodCount returns the nodes ordered by descending pod count in counts,
+// breaking ties by node name so that the order is deterministic.
+// Nodes missing in counts have zero pods and nil nodes are dropped. The given slice is not modified.
+func SortNodesByPodCount(nodes []*corev1.Node, counts map[string]int) []*corev1.Node {
+	sorted := generics.Filter(nodes, func(n *corev1.Node) bool { return n != nil })
+	sort.SliceStable(sorted, func(i, j int) bool {
+		ci, cj := counts[sorted[i].Name], counts[sorted[j].Name]
+		if ci != cj {
+			return ci > cj
+		}
+		return sorted[i].Name < sorted[j].Name
+	})
+	return sorted
+}
+
+// IsNodeUnderPressure checks if the node reports the MemoryPressure or DiskPressure condition.
+func IsNodeUnderPressure(node *corev1.Node) bool {
+	if node == nil {
+		return false
+	}
+	for _, c := range node.Status.Conditions {
+		if (c.Type == corev1.NodeMemoryPressure || c.Type == corev1.NodeDiskPressure) &&
+			c.Status == corev1.ConditionTrue {
+			return true
+		}
+	}
+	return false
+}
+
 // CanSchedule checks if a given pod can be scheduled on a node based on various conditions.
 func CanSchedule(node *corev1.Node, podSpec *corev1.PodSpec) bool {
 	// Check schedultability
@@ -85,13 +201,19 @@ func CanSchedule(node *corev1.Node, podSpec *corev1.PodSpec) bool {
 // It takes a slice of node pointers and a pod spec as arguments.
 // It returns a new slice of node pointers that can schedule the pod spec.
 func FilterScheduleable(nodes []*corev1.Node, podSpec *corev1.PodSpec) []*corev1.Node {
+	return FilterScheduleableWithDefaultRequest(nodes, podSpec, nil)
+}
+
+// FilterScheduleableWithDefaultRequest filters the nodes like FilterScheduleable, but uses the
+// quantities in defaults for the resources the pod does not request.
+func FilterScheduleableWithDefaultRequest(nodes []*corev1.Node, podSpec *corev1.PodSpec, defaults corev1.ResourceList) []*corev1.Node {
 	var list []*corev1.Node
-	request := GetPodRequestResources(*podSpec)
+	request := GetPodRequestResourcesWithDefault(*podSpec, defaults)
 
 	for _, node := range nodes {
 		capacity, err := GetNodeResourceCapacity(node)
 		if err != nil || capacity.Cpu().Cmp(*request.Cpu()) < 0 ||
-			capacity.Memory().Cmp(*request.Memory()) < 0 {
+			capacity.Memory().Cmp(*request.Memory()) < 0 || !hasExtendedResources(capacity, request) {
 			continue
 		}
 		if CanSchedule(node, podSpec) {
@@ -101,6 +223,20 @@ func FilterScheduleable(nodes []*corev1.Node, podSpec *corev1.PodSpec) []*corev1
 	return list
 }
 
+// hasExtendedResources checks if the capacity has enough of every extended resource in the request.
+func hasExtendedResources(capacity, request corev1.ResourceList) bool {
+	for name, q := range request {
+		if !IsExtendedResourceName(name) || q.IsZero() {
+			continue
+		}
+		available, ok := capacity[name]
+		if !ok || available.Cmp(q) < 0 {
+			return false
+		}
+	}
+	return true
+}
+
 // toleratesAllTaints checks whether a given node can tolerate all the taints specified in a pod's spec.
 //
 // Parameters:
@@ -188,6 +324,7 @@ func nodeSelectorTermMatches(node *corev1.Node, term *corev1.NodeSelectorTerm) b
 }
 
 // GetNodeResourceCapacity retrieves the allocatable resource capacity of a node.
+// Allocatable extended resources are included as well.
 // It takes a pointer to a Node object as an argument.
 // It returns a ResourceList and an error.
 func GetNodeResourceCapacity(node *corev1.Node) (corev1.ResourceList, error) {
@@ -199,8 +336,14 @@ func GetNodeResourceCapacity(node *corev1.Node) (corev1.ResourceList, error) {
 	if !found {
 		return nil, fmt.Errorf("node %s has no allocatable memory", node.Name)
 	}
-	return corev1.ResourceList{
+	ret := corev1.ResourceList{
 		corev1.ResourceCPU:    cpu,
 		corev1.ResourceMemory: mem,
-	}, nil
+	}
+	for name, q := range node.Status.Allocatable {
+		if IsExtendedResourceName(name) {
+			ret[name] = q
+		}
+	}
+	return ret, nil
 }
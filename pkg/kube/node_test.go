@@ -34,7 +34,9 @@ import (
 	"github.com/stretchr/testify/assert"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
 )
 
 func TestGetAllNodes(t *testing.T) {
@@ -58,6 +60,71 @@ func TestGetAllNodes(t *testing.T) {
 	})
 }
 
+func TestGetAllNodesWithCache(t *testing.T) {
+	client := fake.NewSimpleClientset(&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}})
+	listCalls := 0
+	client.PrependReactor("list", "nodes", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		listCalls++
+		return false, nil, nil
+	})
+
+	ctx := WithNodeCache(context.Background())
+	for i := 0; i < 3; i++ {
+		nodes, err := GetAllNodes(ctx, client)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(nodes))
+	}
+	assert.Equal(t, 1, listCalls)
+
+	// A new run gets a fresh cache.
+	ctx = WithNodeCache(context.Background())
+	_, err := GetAllNodes(ctx, client)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, listCalls)
+
+	// Without a cache every call lists nodes.
+	_, err = GetAllNodes(context.Background(), client)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, listCalls)
+}
+
+func TestGetNodesBySelector(t *testing.T) {
+	client := fake.NewSimpleClientset(
+		&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "worker-1", Labels: map[string]string{"role": "worker"}}},
+		&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "worker-2", Labels: map[string]string{"role": "worker"}}},
+		&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "control-plane", Labels: map[string]string{"role": "master"}}},
+	)
+	ctx := WithNodeCache(context.Background())
+
+	nodes, err := GetNodesBySelector(ctx, client, "role=worker")
+	assert.NoError(t, err)
+	var names []string
+	for _, n := range nodes {
+		names = append(names, n.Name)
+	}
+	assert.ElementsMatch(t, []string{"worker-1", "worker-2"}, names)
+
+	// The selected nodes are not cached as all nodes.
+	nodes, err = GetNodesBySelector(ctx, client, "")
+	assert.NoError(t, err)
+	assert.Len(t, nodes, 3)
+
+	_, err = GetNodesBySelector(ctx, client, "role in (worker")
+	assert.Error(t, err)
+}
+
+func TestCordonNode(t *testing.T) {
+	ctx := context.Background()
+	client := fake.NewSimpleClientset(&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}})
+
+	assert.NoError(t, CordonNode(ctx, client, "node-1"))
+	node, err := client.CoreV1().Nodes().Get(ctx, "node-1", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.True(t, node.Spec.Unschedulable)
+
+	assert.Error(t, CordonNode(ctx, client, "missing"))
+}
+
 func TestCanSchedule(t *testing.T) {
 	t.Run("ReturnsFalseForNonToleratedTaints", func(t *testing.T) {
 		node := &corev1.Node{Spec: corev1.NodeSpec{Taints: []corev1.Taint{{Effect: "NoSchedule"}}}}
@@ -149,6 +216,25 @@ func TestFilterScheduleable(t *testing.T) {
 
 	node3 := &corev1.Node{Spec: corev1.NodeSpec{Taints: []corev1.Taint{{Effect: "NoSchedule"}}}}
 
+	gpuNode := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "gpu-node"},
+		Status: corev1.NodeStatus{
+			Allocatable: corev1.ResourceList{
+				corev1.ResourceCPU:    *resource.NewMilliQuantity(2000, resource.DecimalSI),
+				corev1.ResourceMemory: *resource.NewQuantity(10e9, resource.BinarySI),
+				"nvidia.com/gpu":      resource.MustParse("2"),
+			},
+		},
+	}
+	gpuPodSpec := func(gpus string) *corev1.PodSpec {
+		return &corev1.PodSpec{Containers: []corev1.Container{{
+			Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{
+				corev1.ResourceCPU: resource.MustParse("100m"),
+				"nvidia.com/gpu":   resource.MustParse(gpus),
+			}},
+		}}}
+	}
+
 	tests := []struct {
 		name      string
 		nodes     []*corev1.Node
@@ -170,6 +256,27 @@ func TestFilterScheduleable(t *testing.T) {
 			wantNodes: []string{"node1"},
 			wantErr:   false,
 		},
+		{
+			name:      "gpu pod to gpu nodes only",
+			nodes:     []*corev1.Node{node1, gpuNode, node2},
+			podSpec:   gpuPodSpec("1"),
+			wantNodes: []string{"gpu-node"},
+			wantErr:   false,
+		},
+		{
+			name:      "not enough gpus",
+			nodes:     []*corev1.Node{node1, gpuNode},
+			podSpec:   gpuPodSpec("4"),
+			wantNodes: []string{},
+			wantErr:   false,
+		},
+		{
+			name:      "non gpu pod to any nodes",
+			nodes:     []*corev1.Node{node1, gpuNode},
+			podSpec:   &corev1.PodSpec{},
+			wantNodes: []string{"node1", "gpu-node"},
+			wantErr:   false,
+		},
 	}
 
 	for _, tt := range tests {
@@ -252,3 +359,71 @@ func TestGetNodeResourceCapacity(t *testing.T) {
 		}
 	})
 }
+
+func TestCountPodsPerNode(t *testing.T) {
+	pod := func(node string) *corev1.Pod { return &corev1.Pod{Spec: corev1.PodSpec{NodeName: node}} }
+	counts := CountPodsPerNode([]*corev1.Pod{pod("node-1"), nil, pod("node-2"), pod("node-1"), pod("")})
+	assert.Equal(t, map[string]int{"node-1": 2, "node-2": 1, "": 1}, counts)
+	assert.Empty(t, CountPodsPerNode(nil))
+	assert.Empty(t, CountPodsPerNode([]*corev1.Pod{nil}))
+}
+
+func TestSortNodesByPodCount(t *testing.T) {
+	node := func(name string) *corev1.Node {
+		return &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: name}}
+	}
+	nodes := []*corev1.Node{node("node-c"), nil, node("node-b"), node("node-a"), node("node-d")}
+	counts := map[string]int{"node-a": 2, "node-b": 3, "node-c": 2, "unknown": 9}
+
+	sorted := SortNodesByPodCount(nodes, counts)
+	var names []string
+	for _, n := range sorted {
+		names = append(names, n.Name)
+	}
+	assert.Equal(t, []string{"node-b", "node-a", "node-c", "node-d"}, names)
+	assert.Equal(t, "node-c", nodes[0].Name, "input slice must not be modified")
+	assert.Empty(t, SortNodesByPodCount(nil, counts))
+}
+
+func TestFilterScheduleableWithDefaultRequest(t *testing.T) {
+	newNode := func(name, cpu string) *corev1.Node {
+		return &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Status: corev1.NodeStatus{
+				Allocatable: corev1.ResourceList{
+					corev1.ResourceCPU:    resource.MustParse(cpu),
+					corev1.ResourceMemory: resource.MustParse("1Gi"),
+				},
+			},
+		}
+	}
+	nodes := []*corev1.Node{newNode("small", "50m"), newNode("large", "2")}
+	podSpec := &corev1.PodSpec{Containers: []corev1.Container{{Name: "no-request"}}}
+
+	assert.Len(t, FilterScheduleable(nodes, podSpec), 2)
+	assert.Len(t, FilterScheduleableWithDefaultRequest(nodes, podSpec, nil), 2)
+
+	defaults := corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("100m")}
+	schedulables := FilterScheduleableWithDefaultRequest(nodes, podSpec, defaults)
+	if assert.Len(t, schedulables, 1) {
+		assert.Equal(t, "large", schedulables[0].Name)
+	}
+
+	// Declared requests are not replaced by the default.
+	podSpec.Containers[0].Resources.Requests = corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("10m")}
+	assert.Len(t, FilterScheduleableWithDefaultRequest(nodes, podSpec, defaults), 2)
+}
+
+func TestIsNodeUnderPressure(t *testing.T) {
+	withCondition := func(typ corev1.NodeConditionType, status corev1.ConditionStatus) *corev1.Node {
+		return &corev1.Node{Status: corev1.NodeStatus{Conditions: []corev1.NodeCondition{
+			{Type: corev1.NodeReady, Status: corev1.ConditionTrue},
+			{Type: typ, Status: status},
+		}}}
+	}
+	assert.True(t, IsNodeUnderPressure(withCondition(corev1.NodeMemoryPressure, corev1.ConditionTrue)))
+	assert.True(t, IsNodeUnderPressure(withCondition(corev1.NodeDiskPressure, corev1.ConditionTrue)))
+	assert.False(t, IsNodeUnderPressure(withCondition(corev1.NodeMemoryPressure, corev1.ConditionFalse)))
+	assert.False(t, IsNodeUnderPressure(withCondition(corev1.NodePIDPressure, corev1.ConditionTrue)))
+	assert.False(t, IsNodeUnderPressure(nil))
+}
@@ -0,0 +1,115 @@
+/*
+MIT License
+
+Copyright (c) 2024 Norihiro Seto
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package kube
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+)
+
+// PDBCache looks up the PodDisruptionBudgets matching pods, listing them once per namespace.
+// It is meant to live for a single run, as the disruptions allowed are not refreshed.
+type PDBCache struct {
+	client kubernetes.Interface
+	mu     sync.Mutex
+	pdbs   map[string][]*policyv1.PodDisruptionBudget
+}
+
+// NewPDBCache returns a new PDBCache using the client.
+func NewPDBCache(client kubernetes.Interface) *PDBCache {
+	return &PDBCache{client: client, pdbs: map[string][]*policyv1.PodDisruptionBudget{}}
+}
+
+// DisruptionAllowed checks if every PodDisruptionBudget matching the pod currently allows a disruption,
+// that is its status.disruptionsAllowed is positive. A pod without matching budgets can be disrupted.
+func (c *PDBCache) DisruptionAllowed(ctx context.Context, pod *corev1.Pod) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	matched, err := c.matching(ctx, pod)
+	if err != nil {
+		return false, err
+	}
+	for _, pdb := range matched {
+		if pdb.Status.DisruptionsAllowed < 1 {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// RecordDisruption decrements the disruptions allowed by the budgets matching the pod,
+// so that successive checks in the same run account for the pods already deleted.
+func (c *PDBCache) RecordDisruption(ctx context.Context, pod *corev1.Pod) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	matched, err := c.matching(ctx, pod)
+	if err != nil {
+		return err
+	}
+	for _, pdb := range matched {
+		if pdb.Status.DisruptionsAllowed > 0 {
+			pdb.Status.DisruptionsAllowed--
+		}
+	}
+	return nil
+}
+
+// matching returns the budgets in the namespace of the pod whose selectors match the pod labels.
+// A nil selector matches no pod and an empty selector matches every pod, as in policy/v1.
+func (c *PDBCache) matching(ctx context.Context, pod *corev1.Pod) ([]*policyv1.PodDisruptionBudget, error) {
+	pdbs, ok := c.pdbs[pod.Namespace]
+	if !ok {
+		list, err := c.client.PolicyV1().PodDisruptionBudgets(pod.Namespace).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list pod disruption budgets in %s: %w", pod.Namespace, err)
+		}
+		for i := range list.Items {
+			pdbs = append(pdbs, list.Items[i].DeepCopy())
+		}
+		c.pdbs[pod.Namespace] = pdbs
+	}
+
+	var matched []*policyv1.PodDisruptionBudget
+	for _, pdb := range pdbs {
+		if pdb.Spec.Selector == nil {
+			continue
+		}
+		selector, err := metav1.LabelSelectorAsSelector(pdb.Spec.Selector)
+		if err != nil {
+			continue
+		}
+		if selector.Matches(labels.Set(pod.Labels)) {
+			matched = append(matched, pdb)
+		}
+	}
+	return matched, nil
+}
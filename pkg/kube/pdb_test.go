@@ -0,0 +1,83 @@
+/*
+MIT License
+
+Copyright (c) 2024 Norihiro Seto
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package kube
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestPDBCache(t *testing.T) {
+	ctx := context.Background()
+	pdb := func(name string, selector *metav1.LabelSelector, allowed int32) *policyv1.PodDisruptionBudget {
+		return &policyv1.PodDisruptionBudget{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name},
+			Spec:       policyv1.PodDisruptionBudgetSpec{Selector: selector},
+			Status:     policyv1.PodDisruptionBudgetStatus{DisruptionsAllowed: allowed},
+		}
+	}
+	client := fake.NewSimpleClientset(
+		pdb("web", &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}}, 1),
+		pdb("db", &metav1.LabelSelector{MatchLabels: map[string]string{"app": "db"}}, 0),
+		pdb("none", nil, 0),
+	)
+	pod := func(app string) *corev1.Pod {
+		return &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: app, Labels: map[string]string{"app": app}}}
+	}
+
+	cache := NewPDBCache(client)
+	allowed, err := cache.DisruptionAllowed(ctx, pod("web"))
+	assert.NoError(t, err)
+	assert.True(t, allowed)
+
+	allowed, err = cache.DisruptionAllowed(ctx, pod("db"))
+	assert.NoError(t, err)
+	assert.False(t, allowed)
+
+	// Pods without matching budgets can be disrupted.
+	allowed, err = cache.DisruptionAllowed(ctx, pod("cache"))
+	assert.NoError(t, err)
+	assert.True(t, allowed)
+
+	// A recorded disruption uses up the budget.
+	assert.NoError(t, cache.RecordDisruption(ctx, pod("web")))
+	allowed, err = cache.DisruptionAllowed(ctx, pod("web"))
+	assert.NoError(t, err)
+	assert.False(t, allowed)
+
+	lists := 0
+	for _, a := range client.Actions() {
+		if a.GetVerb() == "list" {
+			lists++
+		}
+	}
+	assert.Equal(t, 1, lists)
+}
@@ -27,19 +27,50 @@ package kube
 import (
 	"context"
 	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/norseto/k8s-watchdogs/pkg/generics"
+	"github.com/norseto/k8s-watchdogs/pkg/logger"
 	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
 )
 
 const (
-	reasonEvicted = "Evicted"
+	kindDaemonSet   = "DaemonSet"
+	kindStatefulSet = "StatefulSet"
+
+	// ReasonEvicted is the status reason of pods evicted by the kubelet.
+	ReasonEvicted = "Evicted"
+
+	// DefaultPodListLimit is the page size used by ListAllPods when the
+	// given ListOptions do not specify a limit.
+	DefaultPodListLimit int64 = 500
+
+	// SafeToEvictAnnotation is the annotation used by the cluster-autoscaler to mark
+	// pods that must not be evicted when its value is "false".
+	SafeToEvictAnnotation = "cluster-autoscaler.kubernetes.io/safe-to-evict"
 )
 
+// RebalanceOpts represents options for CanBeRebalancedWithOpts.
+type RebalanceOpts struct {
+	// AnnotationKey is an additional annotation that marks a pod as not evictable
+	// when its value is "false". SafeToEvictAnnotation is always honored.
+	AnnotationKey string
+}
+
 // IsPodReadyRunning checks if a given Pod is both ready and running.
+// A pod with spec.readinessGates is only considered ready when all of its
+// custom readiness gate conditions are satisfied as well.
 func IsPodReadyRunning(po corev1.Pod) bool {
 	phase := po.Status.Phase
 	if phase != corev1.PodRunning && phase != "" {
@@ -50,17 +81,54 @@ func IsPodReadyRunning(po corev1.Pod) bool {
 			return false
 		}
 	}
+	return PodReadinessGatesSatisfied(po)
+}
+
+// PodReadinessGatesSatisfied checks if all readiness gate conditions of a given Pod
+// are present in its status with a True value.
+// A pod without readiness gates always satisfies them.
+func PodReadinessGatesSatisfied(po corev1.Pod) bool {
+	for _, gate := range po.Spec.ReadinessGates {
+		satisfied := false
+		for _, cond := range po.Status.Conditions {
+			if cond.Type == gate.ConditionType {
+				satisfied = cond.Status == corev1.ConditionTrue
+				break
+			}
+		}
+		if !satisfied {
+			return false
+		}
+	}
 	return true
 }
 
+// IsPodRunningWithCondition checks if a given Pod is running and has the condition of the type
+// with a True value. It is used instead of IsPodReadyRunning when readiness is defined by a
+// custom condition, e.g. a readiness gate, rather than the built-in Ready condition.
+func IsPodRunningWithCondition(po corev1.Pod, conditionType corev1.PodConditionType) bool {
+	phase := po.Status.Phase
+	if phase != corev1.PodRunning && phase != "" {
+		return false
+	}
+	for _, cond := range po.Status.Conditions {
+		if cond.Type == conditionType {
+			return cond.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
 // GetPodRequestResources calculates the maximum CPU and memory resources requested by the containers in a given PodSpec.
 // It iterates over each container in the PodSpec and checks if it has requested resources.
 // If so, it compares the requested CPU and memory
 // with the previously calculated maximums, and updates them if necessary.
+// Extended resources (e.g. nvidia.com/gpu) requested by the containers are carried the same way.
 // Finally, it returns the maximum CPU and memory resources as a corev1.ResourceList.
 func GetPodRequestResources(podSpec corev1.PodSpec) corev1.ResourceList {
 	maxCpu := *resource.NewQuantity(0, resource.DecimalSI)
 	maxMem := *resource.NewQuantity(0, resource.DecimalSI)
+	extended := corev1.ResourceList{}
 	for _, c := range podSpec.Containers {
 		if c.Resources.Requests == nil {
 			continue
@@ -71,23 +139,257 @@ func GetPodRequestResources(podSpec corev1.PodSpec) corev1.ResourceList {
 		if c.Resources.Requests.Memory().Cmp(maxMem) > 0 {
 			maxMem = c.Resources.Requests.Memory().DeepCopy()
 		}
+		for name, q := range c.Resources.Requests {
+			if !IsExtendedResourceName(name) {
+				continue
+			}
+			if current, ok := extended[name]; !ok || q.Cmp(current) > 0 {
+				extended[name] = q.DeepCopy()
+			}
+		}
 	}
 
 	ret := corev1.ResourceList{
 		corev1.ResourceCPU:    maxCpu,
 		corev1.ResourceMemory: maxMem,
 	}
+	for name, q := range extended {
+		ret[name] = q
+	}
+	return ret
+}
+
+// GetPodRequestResourcesWithDefault calculates the requested resources like GetPodRequestResources,
+// but substitutes the quantities in defaults for the resources the pod does not request.
+// This keeps scheduling checks meaningful for pods that declare no requests.
+func GetPodRequestResourcesWithDefault(podSpec corev1.PodSpec, defaults corev1.ResourceList) corev1.ResourceList {
+	ret := GetPodRequestResources(podSpec)
+	for name, q := range defaults {
+		if current, ok := ret[name]; !ok || current.IsZero() {
+			ret[name] = q.DeepCopy()
+		}
+	}
 	return ret
 }
 
+// IsExtendedResourceName checks if the resource name is an extended resource,
+// that is a fully qualified name outside the kubernetes.io domain (e.g. nvidia.com/gpu).
+func IsExtendedResourceName(name corev1.ResourceName) bool {
+	n := string(name)
+	if strings.HasPrefix(n, corev1.DefaultResourceRequestsPrefix) {
+		return false
+	}
+	domain, _, found := strings.Cut(n, "/")
+	return found && domain != "kubernetes.io" && !strings.HasSuffix(domain, ".kubernetes.io")
+}
+
+// PriorityClassFilter selects pods by their priority class name.
+type PriorityClassFilter struct {
+	// Include is the list of priority class names to select. Empty selects every pod.
+	Include []string
+	// Exclude is the list of priority class names to reject.
+	Exclude []string
+}
+
+// Match checks if the Pod's priority class name is selected by the filter.
+func (f PriorityClassFilter) Match(pod *corev1.Pod) bool {
+	name := pod.Spec.PriorityClassName
+	if generics.Contains(name, f.Exclude) {
+		return false
+	}
+	return len(f.Include) == 0 || generics.Contains(name, f.Include)
+}
+
+// CanBeRebalanced checks if a given Pod may be deleted to rebalance pods across nodes.
+// It returns false when the Pod has the SafeToEvictAnnotation set to "false".
+func CanBeRebalanced(pod *corev1.Pod) bool {
+	return CanBeRebalancedWithOpts(pod, RebalanceOpts{})
+}
+
+// CanBeRebalancedWithOpts checks if a given Pod may be deleted to rebalance pods across nodes.
+// In addition to SafeToEvictAnnotation, it returns false when the Pod has
+// opts.AnnotationKey set to "false".
+func CanBeRebalancedWithOpts(pod *corev1.Pod, opts RebalanceOpts) bool {
+	ok, _ := CanBeRebalancedReasonWithOpts(pod, opts)
+	return ok
+}
+
+// CanBeRebalancedReason is the same as CanBeRebalanced but also returns
+// a human readable reason when the Pod cannot be rebalanced.
+func CanBeRebalancedReason(pod *corev1.Pod) (bool, string) {
+	return CanBeRebalancedReasonWithOpts(pod, RebalanceOpts{})
+}
+
+// CanBeRebalancedReasonWithOpts is the same as CanBeRebalancedWithOpts but also returns
+// a human readable reason when the Pod cannot be rebalanced.
+// Pods owned by a DaemonSet are never rebalanced since they are bound to their node,
+// and terminating pods are never rebalanced to avoid deleting them twice.
+func CanBeRebalancedReasonWithOpts(pod *corev1.Pod, opts RebalanceOpts) (bool, string) {
+	if IsPodTerminating(pod) {
+		return false, "terminating"
+	}
+	if IsOwnedByDaemonSet(pod) {
+		return false, "owned by DaemonSet"
+	}
+	keys := []string{SafeToEvictAnnotation}
+	if opts.AnnotationKey != "" {
+		keys = append(keys, opts.AnnotationKey)
+	}
+	for _, key := range keys {
+		if v, ok := pod.Annotations[key]; ok && strings.EqualFold(v, "false") {
+			return false, fmt.Sprintf("annotation %s is %q", key, v)
+		}
+	}
+	return true, ""
+}
+
 // DeletePod deletes a pod using the Kubernetes client.
 func DeletePod(ctx context.Context, client kubernetes.Interface, pod corev1.Pod) error {
-	if err := client.CoreV1().Pods(pod.Namespace).Delete(ctx, pod.Name, metav1.DeleteOptions{}); err != nil {
+	return DeletePodWithPolicy(ctx, client, pod, nil)
+}
+
+// DeletePodWithPolicy deletes a pod like DeletePod with the propagation policy of the dependents.
+// A nil policy leaves it to the server default.
+func DeletePodWithPolicy(ctx context.Context, client kubernetes.Interface, pod corev1.Pod, policy *metav1.DeletionPropagation) error {
+	opts := metav1.DeleteOptions{PropagationPolicy: policy}
+	if err := client.CoreV1().Pods(pod.Namespace).Delete(ctx, pod.Name, opts); err != nil {
 		return fmt.Errorf("failed to delete Pod: %s, %w", pod.Name, err)
 	}
 	return nil
 }
 
+// ParsePropagationPolicy parses the value as a propagation policy: Background, Foreground or Orphan.
+// An empty value returns nil, which leaves the policy to the server default.
+func ParsePropagationPolicy(value string) (*metav1.DeletionPropagation, error) {
+	if value == "" {
+		return nil, nil
+	}
+	policy := metav1.DeletionPropagation(value)
+	switch policy {
+	case metav1.DeletePropagationBackground, metav1.DeletePropagationForeground, metav1.DeletePropagationOrphan:
+		return &policy, nil
+	}
+	return nil, fmt.Errorf("invalid propagation policy %q, must be one of %s, %s or %s", value,
+		metav1.DeletePropagationBackground, metav1.DeletePropagationForeground, metav1.DeletePropagationOrphan)
+}
+
+// DefaultDeleteRetryBackoff is the initial wait between attempts of DeletePodWithRetry.
+const DefaultDeleteRetryBackoff = 500 * time.Millisecond
+
+// DeletePodWithRetry deletes a pod like DeletePod, making up to attempts attempts.
+// Conflicts, server errors and throttling are retried with an exponential backoff
+// starting at backoff; other errors, including NotFound, are returned at once.
+// An attempts less than 2 makes a single attempt.
+func DeletePodWithRetry(ctx context.Context, client kubernetes.Interface, pod corev1.Pod, attempts int, backoff time.Duration) error {
+	return DeletePodWithPolicyAndRetry(ctx, client, pod, nil, attempts, backoff)
+}
+
+// DeletePodWithPolicyAndRetry deletes a pod like DeletePodWithPolicy, retrying like DeletePodWithRetry.
+func DeletePodWithPolicyAndRetry(ctx context.Context, client kubernetes.Interface, pod corev1.Pod,
+	policy *metav1.DeletionPropagation, attempts int, backoff time.Duration) error {
+	if attempts < 1 {
+		attempts = 1
+	}
+	b := wait.Backoff{Steps: attempts, Duration: backoff, Factor: 2, Jitter: 0.1}
+	return retry.OnError(b, isRetriableDeleteError, func() error {
+		return DeletePodWithPolicy(ctx, client, pod, policy)
+	})
+}
+
+// isRetriableDeleteError checks if a failed delete may succeed when retried.
+func isRetriableDeleteError(err error) bool {
+	return apierrors.IsConflict(err) || apierrors.IsInternalError(err) ||
+		apierrors.IsServerTimeout(err) || apierrors.IsTimeout(err) ||
+		apierrors.IsTooManyRequests(err) || apierrors.IsServiceUnavailable(err)
+}
+
+// EvictPod evicts a pod using the Eviction API so that PodDisruptionBudgets are honored.
+// If gracePeriodSeconds is not nil, it overrides the pod's termination grace period.
+// The policy/v1beta1 Eviction API is used when the server advertises it instead of policy/v1,
+// or is older than 1.22 when it advertises neither.
+func EvictPod(ctx context.Context, client kubernetes.Interface, pod corev1.Pod, gracePeriodSeconds *int64) error {
+	meta := metav1.ObjectMeta{Name: pod.Name, Namespace: pod.Namespace}
+	opts := &metav1.DeleteOptions{GracePeriodSeconds: gracePeriodSeconds}
+
+	var err error
+	if evictionVersion(ctx, client) == policyv1beta1.SchemeGroupVersion.Version {
+		eviction := &policyv1beta1.Eviction{ObjectMeta: meta, DeleteOptions: opts}
+		err = client.PolicyV1beta1().Evictions(pod.Namespace).Evict(ctx, eviction)
+	} else {
+		eviction := &policyv1.Eviction{ObjectMeta: meta, DeleteOptions: opts}
+		err = client.PolicyV1().Evictions(pod.Namespace).Evict(ctx, eviction)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to evict Pod: %s, %w", pod.Name, err)
+	}
+	return nil
+}
+
+// EvictionCache holds the Eviction API version chosen during a single command run.
+type EvictionCache struct {
+	mu      sync.Mutex
+	version string
+}
+
+type evictionCacheKey struct{}
+
+// WithEvictionCache returns a new context holding an empty EvictionCache.
+// EvictPod called with the returned context chooses the Eviction API only once and
+// reuses the choice afterward. Call it at the start of each command run.
+func WithEvictionCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, evictionCacheKey{}, &EvictionCache{})
+}
+
+// evictionCacheFromContext retrieves the *EvictionCache from the given context, or nil if absent.
+func evictionCacheFromContext(ctx context.Context) *EvictionCache {
+	if v, ok := ctx.Value(evictionCacheKey{}).(*EvictionCache); ok {
+		return v
+	}
+	return nil
+}
+
+// evictionVersion returns the policy group version of the Eviction API to use with the client.
+// If the context holds an EvictionCache, the version is chosen once and reused afterward.
+func evictionVersion(ctx context.Context, client kubernetes.Interface) string {
+	cache := evictionCacheFromContext(ctx)
+	if cache == nil {
+		return detectEvictionVersion(ctx, client)
+	}
+
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	if cache.version == "" {
+		cache.version = detectEvictionVersion(ctx, client)
+	}
+	return cache.version
+}
+
+// detectEvictionVersion returns the policy group version of the pods/eviction subresource
+// advertised by the server. When the subresource does not tell its version, policy/v1beta1 is
+// used for servers older than 1.22, where policy/v1 Eviction went GA, and policy/v1 otherwise,
+// including when the server version is unknown.
+func detectEvictionVersion(ctx context.Context, client kubernetes.Interface) string {
+	log := logger.FromContext(ctx)
+
+	if resources, err := client.Discovery().ServerResourcesForGroupVersion("v1"); err == nil {
+		for _, r := range resources.APIResources {
+			if r.Name == "pods/eviction" && r.Kind == "Eviction" && r.Group == policyv1.GroupName && r.Version != "" {
+				log.Info("eviction API", "version", policyv1.GroupName+"/"+r.Version, "source", "discovery")
+				return r.Version
+			}
+		}
+	}
+	version := policyv1.SchemeGroupVersion.Version
+	ok, err := ServerAtLeast(ctx, client, 1, 22)
+	if err != nil {
+		log.V(1).Info("cannot get server version, use default eviction API", "error", err)
+	} else if !ok {
+		version = policyv1beta1.SchemeGroupVersion.Version
+	}
+	log.Info("eviction API", "version", policyv1.GroupName+"/"+version, "source", "server version")
+	return version
+}
+
 // toleratesTaint checks if a given PodSpec tolerates a specific Taint.
 func toleratesTaint(podSpec *corev1.PodSpec, taint corev1.Taint) bool {
 	for _, toleration := range podSpec.Tolerations {
@@ -102,20 +404,113 @@ func toleratesTaint(podSpec *corev1.PodSpec, taint corev1.Taint) bool {
 // It returns true if the Pod's phase is "Failed" and the reason is "Evicted",
 // otherwise, it returns false.
 func IsEvictedPod(pod *corev1.Pod) bool {
+	return IsFailedPodWithReason(pod, []string{ReasonEvicted})
+}
+
+// IsFailedPodWithReason checks if the Pod's phase is "Failed" and its status reason is one of the reasons.
+// Empty reasons match no pod.
+func IsFailedPodWithReason(pod *corev1.Pod, reasons []string) bool {
 	status := pod.Status
-	if status.Phase == corev1.PodFailed && status.Reason == reasonEvicted {
-		return true
+	return status.Phase == corev1.PodFailed && slices.Contains(reasons, status.Reason)
+}
+
+// IsOwnedByDaemonSet checks if the pod has a DaemonSet owner reference.
+func IsOwnedByDaemonSet(pod *corev1.Pod) bool {
+	return isOwnedByKind(pod, kindDaemonSet)
+}
+
+// IsOwnedByStatefulSet checks if the pod has a StatefulSet owner reference.
+func IsOwnedByStatefulSet(pod *corev1.Pod) bool {
+	return isOwnedByKind(pod, kindStatefulSet)
+}
+
+// isOwnedByKind checks if the pod has an owner reference of the kind.
+func isOwnedByKind(pod *corev1.Pod, kind string) bool {
+	for _, o := range pod.OwnerReferences {
+		if o.Kind == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// IsUnschedulablePod checks if a given Pod is pending because the scheduler could not
+// place it, that is its PodScheduled condition is False with the reason "Unschedulable".
+func IsUnschedulablePod(pod *corev1.Pod) bool {
+	if pod.Status.Phase != corev1.PodPending {
+		return false
+	}
+	for _, c := range pod.Status.Conditions {
+		if c.Type == corev1.PodScheduled {
+			return c.Status == corev1.ConditionFalse && c.Reason == corev1.PodReasonUnschedulable
+		}
 	}
 	return false
 }
 
+// IsCompletedStandalonePod checks if a given Pod has succeeded and is not managed by a controller,
+// e.g. a Pod run manually that nothing will garbage collect. Pods owned by a Job are excluded.
+func IsCompletedStandalonePod(pod *corev1.Pod) bool {
+	return pod.Status.Phase == corev1.PodSucceeded && metav1.GetControllerOf(pod) == nil
+}
+
+// IsPodTerminating checks if the pod is being deleted, e.g. running its PreStop hooks
+// within the termination grace period. A pod past its deletion deadline is still
+// terminating until the kubelet removes it.
+func IsPodTerminating(pod *corev1.Pod) bool {
+	return pod.DeletionTimestamp != nil
+}
+
+// PodStartTime returns the start time of the pod.
+// It falls back to the creation timestamp when the pod has no Status.StartTime yet.
+func PodStartTime(pod *corev1.Pod) time.Time {
+	if pod.Status.StartTime != nil {
+		return pod.Status.StartTime.Time
+	}
+	return pod.CreationTimestamp.Time
+}
+
+// PodLastTransitionTime returns the latest last transition time of the pod conditions,
+// which tells when the pod last changed its state, e.g. failed by an eviction.
+// It falls back to the creation timestamp when the pod has no conditions.
+func PodLastTransitionTime(pod *corev1.Pod) time.Time {
+	var latest time.Time
+	for _, c := range pod.Status.Conditions {
+		if c.LastTransitionTime.After(latest) {
+			latest = c.LastTransitionTime.Time
+		}
+	}
+	if latest.IsZero() {
+		return pod.CreationTimestamp.Time
+	}
+	return latest
+}
+
 // FilterPods filters the given list of Pods using the provided filter function and returns a list of filtered Pods.
 func FilterPods(list *corev1.PodList, filter func(*corev1.Pod) bool) []*corev1.Pod {
-	var filtered []*corev1.Pod
-	generics.Each(list.Items, func(item corev1.Pod) {
-		if filter(&item) {
-			filtered = append(filtered, &item)
+	pods := generics.Convert(list.Items, func(item corev1.Pod) *corev1.Pod { return &item }, nil)
+	return generics.Filter(pods, filter)
+}
+
+// ListAllPods lists all pods in a namespace, paging through the results with
+// ListOptions.Limit and continue tokens. If opts.Limit is not set,
+// DefaultPodListLimit is used as the page size.
+func ListAllPods(ctx context.Context, client kubernetes.Interface, namespace string, opts metav1.ListOptions) ([]corev1.Pod, error) {
+	if opts.Limit <= 0 {
+		opts.Limit = DefaultPodListLimit
+	}
+	opts.Continue = ""
+
+	var pods []corev1.Pod
+	for {
+		list, err := client.CoreV1().Pods(namespace).List(ctx, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list pods in %q: %w", namespace, err)
 		}
-	})
-	return filtered
+		pods = append(pods, list.Items...)
+		if list.Continue == "" {
+			return pods, nil
+		}
+		opts.Continue = list.Continue
+	}
 }
@@ -27,12 +27,23 @@ package kube
 import (
 	"context"
 	"testing"
+	"time"
 
+	"github.com/go-logr/logr/funcr"
+	"github.com/norseto/k8s-watchdogs/pkg/logger"
 	"github.com/stretchr/testify/assert"
 	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/version"
+	fakediscovery "k8s.io/client-go/discovery/fake"
 	testclient "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
 )
 
 func TestIsPodReadyRunning(t *testing.T) {
@@ -55,6 +66,65 @@ func TestIsPodReadyRunning(t *testing.T) {
 	}
 }
 
+func TestIsPodRunningWithCondition(t *testing.T) {
+	const gate = corev1.PodConditionType("example.com/warmed-up")
+	pod := func(phase corev1.PodPhase, conditions ...corev1.PodCondition) corev1.Pod {
+		return corev1.Pod{Status: corev1.PodStatus{Phase: phase, Conditions: conditions,
+			ContainerStatuses: []corev1.ContainerStatus{{Ready: false}}}}
+	}
+
+	tests := []struct {
+		description string
+		pod         corev1.Pod
+		expected    bool
+	}{
+		{"Condition true", pod(corev1.PodRunning, corev1.PodCondition{Type: gate, Status: corev1.ConditionTrue}), true},
+		{"Condition false", pod(corev1.PodRunning, corev1.PodCondition{Type: gate, Status: corev1.ConditionFalse}), false},
+		{"Condition missing", pod(corev1.PodRunning, corev1.PodCondition{Type: corev1.PodReady, Status: corev1.ConditionTrue}), false},
+		{"Pod not running", pod(corev1.PodPending, corev1.PodCondition{Type: gate, Status: corev1.ConditionTrue}), false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			assert.Equal(t, test.expected, IsPodRunningWithCondition(test.pod, gate))
+		})
+	}
+}
+
+func TestPodReadinessGatesSatisfied(t *testing.T) {
+	const gate = corev1.PodConditionType("example.com/load-balancer-ready")
+	withGate := func(conditions ...corev1.PodCondition) corev1.Pod {
+		return corev1.Pod{
+			Spec: corev1.PodSpec{ReadinessGates: []corev1.PodReadinessGate{{ConditionType: gate}}},
+			Status: corev1.PodStatus{
+				Phase:             corev1.PodRunning,
+				ContainerStatuses: []corev1.ContainerStatus{{Ready: true}},
+				Conditions:        conditions,
+			},
+		}
+	}
+
+	tests := []struct {
+		description string
+		pod         corev1.Pod
+		expected    bool
+	}{
+		{"No readiness gates", corev1.Pod{}, true},
+		{"Gate satisfied", withGate(corev1.PodCondition{Type: gate, Status: corev1.ConditionTrue}), true},
+		{"Gate unsatisfied", withGate(corev1.PodCondition{Type: gate, Status: corev1.ConditionFalse}), false},
+		{"Gate condition missing", withGate(corev1.PodCondition{Type: corev1.PodReady, Status: corev1.ConditionTrue}), false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			assert.Equal(t, test.expected, PodReadinessGatesSatisfied(test.pod))
+			if test.pod.Status.Phase == corev1.PodRunning {
+				assert.Equal(t, test.expected, IsPodReadyRunning(test.pod))
+			}
+		})
+	}
+}
+
 // TestDeletePod tests the DeletePod function
 func TestDeletePod(t *testing.T) {
 	ctx := context.TODO()
@@ -90,6 +160,93 @@ func TestDeletePod(t *testing.T) {
 	assert.Equal(t, 0, len(pods.Items))
 }
 
+func TestDeletePodWithPolicy(t *testing.T) {
+	ctx := context.Background()
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "my-pod"}}
+	client := testclient.NewSimpleClientset(pod)
+
+	policy := metav1.DeletePropagationForeground
+	assert.NoError(t, DeletePodWithPolicy(ctx, client, *pod, &policy))
+
+	actions := client.Actions()
+	if assert.Len(t, actions, 1) {
+		opts := actions[0].(k8stesting.DeleteActionImpl).GetDeleteOptions()
+		if assert.NotNil(t, opts.PropagationPolicy) {
+			assert.Equal(t, policy, *opts.PropagationPolicy)
+		}
+	}
+}
+
+func TestParsePropagationPolicy(t *testing.T) {
+	policy, err := ParsePropagationPolicy("")
+	assert.NoError(t, err)
+	assert.Nil(t, policy)
+
+	for _, v := range []metav1.DeletionPropagation{
+		metav1.DeletePropagationBackground, metav1.DeletePropagationForeground, metav1.DeletePropagationOrphan,
+	} {
+		policy, err = ParsePropagationPolicy(string(v))
+		assert.NoError(t, err)
+		if assert.NotNil(t, policy) {
+			assert.Equal(t, v, *policy)
+		}
+	}
+
+	_, err = ParsePropagationPolicy("foreground")
+	assert.Error(t, err)
+}
+
+func TestDeletePodWithRetry(t *testing.T) {
+	ctx := context.Background()
+	pod := corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "test-pod", Namespace: "default"}}
+	gr := schema.GroupResource{Resource: "pods"}
+
+	tests := []struct {
+		name      string
+		errs      []error
+		attempts  int
+		wantErr   bool
+		wantCalls int
+	}{
+		{name: "success", attempts: 3, wantCalls: 1},
+		{name: "conflict retried", errs: []error{
+			apierrors.NewConflict(gr, pod.Name, nil),
+			apierrors.NewInternalError(assert.AnError),
+		}, attempts: 3, wantCalls: 3},
+		{name: "attempts exhausted", errs: []error{
+			apierrors.NewConflict(gr, pod.Name, nil),
+			apierrors.NewConflict(gr, pod.Name, nil),
+		}, attempts: 2, wantErr: true, wantCalls: 2},
+		{name: "single attempt", errs: []error{
+			apierrors.NewServiceUnavailable("unavailable"),
+		}, attempts: 0, wantErr: true, wantCalls: 1},
+		{name: "not found is not retried", errs: []error{
+			apierrors.NewNotFound(gr, pod.Name),
+		}, attempts: 3, wantErr: true, wantCalls: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := testclient.NewSimpleClientset(pod.DeepCopy())
+			calls := 0
+			client.PrependReactor("delete", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				calls++
+				if calls <= len(tt.errs) {
+					return true, nil, tt.errs[calls-1]
+				}
+				return false, nil, nil
+			})
+
+			err := DeletePodWithRetry(ctx, client, pod, tt.attempts, time.Millisecond)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.wantCalls, calls)
+		})
+	}
+}
+
 func TestToleratesTaint(t *testing.T) {
 	myTaint := corev1.Taint{
 		Key:   "myTaint",
@@ -144,7 +301,7 @@ func TestIsEvicted(t *testing.T) {
 			pod: corev1.Pod{
 				Status: corev1.PodStatus{
 					Phase:  corev1.PodFailed,
-					Reason: reasonEvicted,
+					Reason: ReasonEvicted,
 				},
 			},
 			expected: true,
@@ -200,3 +357,375 @@ func TestGetPodRequestResources(t *testing.T) {
 		t.Errorf("Memory resource mismatch, expected: %v, got: %v", expected.Memory(), result.Memory())
 	}
 }
+
+func TestGetPodRequestResourcesWithDefault(t *testing.T) {
+	defaults := corev1.ResourceList{
+		corev1.ResourceCPU:    resource.MustParse("100m"),
+		corev1.ResourceMemory: resource.MustParse("128Mi"),
+	}
+	spec := corev1.PodSpec{Containers: []corev1.Container{{
+		Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{
+			corev1.ResourceMemory: resource.MustParse("1Gi"),
+		}},
+	}}}
+
+	got := GetPodRequestResourcesWithDefault(spec, defaults)
+	assert.Equal(t, "100m", got.Cpu().String())
+	assert.Equal(t, "1Gi", got.Memory().String())
+
+	got = GetPodRequestResourcesWithDefault(spec, nil)
+	assert.True(t, got.Cpu().IsZero())
+}
+
+func TestIsExtendedResourceName(t *testing.T) {
+	tests := []struct {
+		name     corev1.ResourceName
+		expected bool
+	}{
+		{corev1.ResourceCPU, false},
+		{corev1.ResourceEphemeralStorage, false},
+		{"hugepages-2Mi", false},
+		{"kubernetes.io/batch-cpu", false},
+		{"example.kubernetes.io/foo", false},
+		{"requests.nvidia.com/gpu", false},
+		{"nvidia.com/gpu", true},
+		{"example.com/dongle", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.name), func(t *testing.T) {
+			assert.Equal(t, tt.expected, IsExtendedResourceName(tt.name))
+		})
+	}
+
+	spec := corev1.PodSpec{Containers: []corev1.Container{
+		{Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{"nvidia.com/gpu": resource.MustParse("1")}}},
+		{Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{"nvidia.com/gpu": resource.MustParse("2")}}},
+	}}
+	gpu := GetPodRequestResources(spec)["nvidia.com/gpu"]
+	assert.Equal(t, int64(2), gpu.Value())
+}
+
+func TestCanBeRebalanced(t *testing.T) {
+	withAnnotations := func(annotations map[string]string) *corev1.Pod {
+		return &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod", Annotations: annotations}}
+	}
+
+	tests := []struct {
+		name     string
+		pod      *corev1.Pod
+		opts     RebalanceOpts
+		expected bool
+	}{
+		{"NoAnnotation", withAnnotations(nil), RebalanceOpts{}, true},
+		{"SafeToEvictFalse", withAnnotations(map[string]string{SafeToEvictAnnotation: "false"}), RebalanceOpts{}, false},
+		{"SafeToEvictTrue", withAnnotations(map[string]string{SafeToEvictAnnotation: "true"}), RebalanceOpts{}, true},
+		{"CustomAnnotationFalse", withAnnotations(map[string]string{"example.com/no-rebalance": "false"}),
+			RebalanceOpts{AnnotationKey: "example.com/no-rebalance"}, false},
+		{"CustomAnnotationIgnoredWithoutOpts", withAnnotations(map[string]string{"example.com/no-rebalance": "false"}),
+			RebalanceOpts{}, true},
+		{"DefaultHonoredWithCustomKey", withAnnotations(map[string]string{SafeToEvictAnnotation: "False"}),
+			RebalanceOpts{AnnotationKey: "example.com/no-rebalance"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, CanBeRebalancedWithOpts(tt.pod, tt.opts))
+			if tt.opts.AnnotationKey == "" {
+				assert.Equal(t, tt.expected, CanBeRebalanced(tt.pod))
+			}
+		})
+	}
+}
+
+func TestCanBeRebalancedReason(t *testing.T) {
+	tests := []struct {
+		name     string
+		pod      *corev1.Pod
+		expected bool
+		reason   string
+	}{
+		{"Rebalanceable", &corev1.Pod{}, true, ""},
+		{"Terminating", &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+			DeletionTimestamp: &metav1.Time{Time: time.Now()}}}, false, "terminating"},
+		{"OwnedByDaemonSet", &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+			OwnerReferences: []metav1.OwnerReference{{Kind: "DaemonSet", Name: "ds"}}}}, false, "owned by DaemonSet"},
+		{"SafeToEvictFalse", &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+			Annotations: map[string]string{SafeToEvictAnnotation: "false"}}}, false,
+			`annotation cluster-autoscaler.kubernetes.io/safe-to-evict is "false"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ok, reason := CanBeRebalancedReason(tt.pod)
+			assert.Equal(t, tt.expected, ok)
+			assert.Equal(t, tt.reason, reason)
+			assert.Equal(t, tt.expected, CanBeRebalanced(tt.pod))
+		})
+	}
+}
+
+func TestEvictPod(t *testing.T) {
+	advertise := func(version string) []*metav1.APIResourceList {
+		return []*metav1.APIResourceList{{
+			GroupVersion: "v1",
+			APIResources: []metav1.APIResource{
+				{Name: "pods", Kind: "Pod", Namespaced: true},
+				{Name: "pods/eviction", Kind: "Eviction", Group: "policy", Version: version, Namespaced: true},
+			},
+		}}
+	}
+
+	tests := []struct {
+		name      string
+		resources []*metav1.APIResourceList
+		server    *version.Info
+		expected  string
+	}{
+		{"NoDiscovery", nil, nil, "v1"},
+		{"PolicyV1", advertise("v1"), nil, "v1"},
+		{"PolicyV1beta1", advertise("v1beta1"), nil, "v1beta1"},
+		{"DiscoveryWins", advertise("v1"), &version.Info{Major: "1", Minor: "21"}, "v1"},
+		{"NoVersionAdvertised", advertise(""), &version.Info{Major: "1", Minor: "21"}, "v1beta1"},
+		{"ServerV1", nil, &version.Info{Major: "1", Minor: "22"}, "v1"},
+		{"ServerV1beta1", nil, &version.Info{Major: "1", Minor: "21"}, "v1beta1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var lines []string
+			ctx := logger.WithContext(context.TODO(), funcr.New(func(_, args string) {
+				lines = append(lines, args)
+			}, funcr.Options{}))
+			pod := corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "my-pod"}}
+			client := testclient.NewSimpleClientset(&pod)
+			client.Resources = tt.resources
+			client.Discovery().(*fakediscovery.FakeDiscovery).FakedServerVersion = tt.server
+
+			grace := int64(10)
+			err := EvictPod(ctx, client, pod, &grace)
+			assert.NoError(t, err)
+			if assert.Len(t, lines, 1) {
+				assert.Contains(t, lines[0], `"msg"="eviction API" "version"="policy/`+tt.expected+`"`)
+			}
+
+			var creates []k8stesting.CreateAction
+			for _, a := range client.Actions() {
+				if a.GetVerb() == "create" {
+					creates = append(creates, a.(k8stesting.CreateAction))
+				}
+			}
+			if !assert.Len(t, creates, 1) {
+				return
+			}
+			assert.Equal(t, "eviction", creates[0].GetSubresource())
+			switch eviction := creates[0].GetObject().(type) {
+			case *policyv1.Eviction:
+				assert.Equal(t, "v1", tt.expected)
+				assert.Equal(t, "my-pod", eviction.Name)
+				assert.Equal(t, int64(10), *eviction.DeleteOptions.GracePeriodSeconds)
+			case *policyv1beta1.Eviction:
+				assert.Equal(t, "v1beta1", tt.expected)
+				assert.Equal(t, "my-pod", eviction.Name)
+				assert.Equal(t, int64(10), *eviction.DeleteOptions.GracePeriodSeconds)
+			default:
+				t.Errorf("unexpected eviction object %T", eviction)
+			}
+		})
+	}
+}
+
+func TestEvictPod_EvictionCache(t *testing.T) {
+	pods := []runtime.Object{
+		&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "pod-a"}},
+		&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "pod-b"}},
+	}
+	evictions := func(ctx context.Context) []runtime.Object {
+		client := testclient.NewSimpleClientset(pods...)
+		disc := client.Discovery().(*fakediscovery.FakeDiscovery)
+		disc.FakedServerVersion = &version.Info{Major: "1", Minor: "21"}
+		assert.NoError(t, EvictPod(ctx, client, *pods[0].(*corev1.Pod), nil))
+		disc.FakedServerVersion = &version.Info{Major: "1", Minor: "30"}
+		assert.NoError(t, EvictPod(ctx, client, *pods[1].(*corev1.Pod), nil))
+
+		var objects []runtime.Object
+		for _, a := range client.Actions() {
+			if a.GetVerb() == "create" {
+				objects = append(objects, a.(k8stesting.CreateAction).GetObject())
+			}
+		}
+		return objects
+	}
+
+	// The run reuses the first choice.
+	cached := evictions(WithEvictionCache(context.TODO()))
+	if assert.Len(t, cached, 2) {
+		assert.IsType(t, &policyv1beta1.Eviction{}, cached[0])
+		assert.IsType(t, &policyv1beta1.Eviction{}, cached[1])
+	}
+
+	// Without a cache, each eviction follows the server.
+	uncached := evictions(context.TODO())
+	if assert.Len(t, uncached, 2) {
+		assert.IsType(t, &policyv1beta1.Eviction{}, uncached[0])
+		assert.IsType(t, &policyv1.Eviction{}, uncached[1])
+	}
+}
+
+func TestPriorityClassFilter(t *testing.T) {
+	withClass := func(name string) *corev1.Pod {
+		return &corev1.Pod{Spec: corev1.PodSpec{PriorityClassName: name}}
+	}
+
+	tests := []struct {
+		name     string
+		filter   PriorityClassFilter
+		pod      *corev1.Pod
+		expected bool
+	}{
+		{"EmptyFilter", PriorityClassFilter{}, withClass("high"), true},
+		{"Included", PriorityClassFilter{Include: []string{"high"}}, withClass("high"), true},
+		{"NotIncluded", PriorityClassFilter{Include: []string{"high"}}, withClass("low"), false},
+		{"NoClassNotIncluded", PriorityClassFilter{Include: []string{"high"}}, withClass(""), false},
+		{"Excluded", PriorityClassFilter{Exclude: []string{"system-cluster-critical"}}, withClass("system-cluster-critical"), false},
+		{"NotExcluded", PriorityClassFilter{Exclude: []string{"system-cluster-critical"}}, withClass("low"), true},
+		{"ExcludeWins", PriorityClassFilter{Include: []string{"high"}, Exclude: []string{"high"}}, withClass("high"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.filter.Match(tt.pod))
+		})
+	}
+}
+
+func TestListAllPods(t *testing.T) {
+	ctx := context.TODO()
+	client := testclient.NewSimpleClientset()
+
+	pages := map[string]*corev1.PodList{
+		"": {
+			ListMeta: metav1.ListMeta{Continue: "page2"},
+			Items:    []corev1.Pod{{ObjectMeta: metav1.ObjectMeta{Name: "pod-1"}}, {ObjectMeta: metav1.ObjectMeta{Name: "pod-2"}}},
+		},
+		"page2": {
+			ListMeta: metav1.ListMeta{Continue: "page3"},
+			Items:    []corev1.Pod{{ObjectMeta: metav1.ObjectMeta{Name: "pod-3"}}, {ObjectMeta: metav1.ObjectMeta{Name: "pod-4"}}},
+		},
+		"page3": {
+			Items: []corev1.Pod{{ObjectMeta: metav1.ObjectMeta{Name: "pod-5"}}},
+		},
+	}
+	var limits []int64
+	client.PrependReactor("list", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		opts := action.(k8stesting.ListActionImpl).GetListOptions()
+		limits = append(limits, opts.Limit)
+		return true, pages[opts.Continue], nil
+	})
+
+	pods, err := ListAllPods(ctx, client, "default", metav1.ListOptions{})
+	assert.NoError(t, err)
+	assert.Len(t, pods, 5)
+	assert.Equal(t, "pod-5", pods[4].Name)
+	assert.Equal(t, []int64{DefaultPodListLimit, DefaultPodListLimit, DefaultPodListLimit}, limits)
+
+	limits = nil
+	_, err = ListAllPods(ctx, client, "default", metav1.ListOptions{Limit: 2})
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{2, 2, 2}, limits)
+}
+
+func TestPodStartTime(t *testing.T) {
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	started := created.Add(time.Minute)
+
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{CreationTimestamp: metav1.NewTime(created)}}
+	assert.Equal(t, created, PodStartTime(pod))
+
+	startTime := metav1.NewTime(started)
+	pod.Status.StartTime = &startTime
+	assert.Equal(t, started, PodStartTime(pod))
+}
+
+func TestPodLastTransitionTime(t *testing.T) {
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{CreationTimestamp: metav1.NewTime(created)}}
+	assert.Equal(t, created, PodLastTransitionTime(pod))
+
+	pod.Status.Conditions = []corev1.PodCondition{
+		{Type: corev1.PodScheduled, LastTransitionTime: metav1.NewTime(created.Add(time.Minute))},
+		{Type: corev1.PodReady, LastTransitionTime: metav1.NewTime(created.Add(time.Hour))},
+		{Type: corev1.ContainersReady, LastTransitionTime: metav1.NewTime(created.Add(time.Second))},
+	}
+	assert.Equal(t, created.Add(time.Hour), PodLastTransitionTime(pod))
+}
+
+func TestIsPodTerminating(t *testing.T) {
+	assert.False(t, IsPodTerminating(&corev1.Pod{}))
+
+	grace := int64(30)
+	deletion := metav1.NewTime(time.Now().Add(time.Duration(grace) * time.Second))
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{DeletionTimestamp: &deletion, DeletionGracePeriodSeconds: &grace}}
+	assert.True(t, IsPodTerminating(pod))
+}
+
+func TestIsUnschedulablePod(t *testing.T) {
+	pending := func(conds ...corev1.PodCondition) *corev1.Pod {
+		return &corev1.Pod{Status: corev1.PodStatus{Phase: corev1.PodPending, Conditions: conds}}
+	}
+	unschedulable := corev1.PodCondition{
+		Type: corev1.PodScheduled, Status: corev1.ConditionFalse, Reason: corev1.PodReasonUnschedulable,
+	}
+
+	assert.True(t, IsUnschedulablePod(pending(unschedulable)))
+	assert.False(t, IsUnschedulablePod(pending()))
+	assert.False(t, IsUnschedulablePod(pending(corev1.PodCondition{Type: corev1.PodScheduled, Status: corev1.ConditionTrue})))
+	assert.False(t, IsUnschedulablePod(pending(corev1.PodCondition{
+		Type: corev1.PodScheduled, Status: corev1.ConditionFalse, Reason: "SchedulingGated",
+	})))
+
+	running := pending(unschedulable)
+	running.Status.Phase = corev1.PodRunning
+	assert.False(t, IsUnschedulablePod(running))
+}
+
+func TestIsCompletedStandalonePod(t *testing.T) {
+	controller := true
+	pod := &corev1.Pod{Status: corev1.PodStatus{Phase: corev1.PodSucceeded}}
+	assert.True(t, IsCompletedStandalonePod(pod))
+
+	owned := pod.DeepCopy()
+	owned.OwnerReferences = []metav1.OwnerReference{{Kind: "Job", Name: "job", Controller: &controller}}
+	assert.False(t, IsCompletedStandalonePod(owned))
+
+	failed := pod.DeepCopy()
+	failed.Status.Phase = corev1.PodFailed
+	assert.False(t, IsCompletedStandalonePod(failed))
+}
+
+func TestIsOwnedBy(t *testing.T) {
+	owned := func(kind string) *corev1.Pod {
+		return &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+			OwnerReferences: []metav1.OwnerReference{{Kind: kind, Name: "owner"}},
+		}}
+	}
+
+	assert.True(t, IsOwnedByDaemonSet(owned("DaemonSet")))
+	assert.False(t, IsOwnedByDaemonSet(owned("StatefulSet")))
+	assert.True(t, IsOwnedByStatefulSet(owned("StatefulSet")))
+	assert.False(t, IsOwnedByStatefulSet(owned("ReplicaSet")))
+	assert.False(t, IsOwnedByDaemonSet(&corev1.Pod{}))
+	assert.False(t, IsOwnedByStatefulSet(&corev1.Pod{}))
+}
+
+func TestIsFailedPodWithReason(t *testing.T) {
+	pod := &corev1.Pod{Status: corev1.PodStatus{Phase: corev1.PodFailed, Reason: "Preempted"}}
+	assert.True(t, IsFailedPodWithReason(pod, []string{ReasonEvicted, "Preempted"}))
+	assert.False(t, IsFailedPodWithReason(pod, []string{ReasonEvicted}))
+	assert.False(t, IsFailedPodWithReason(pod, nil))
+	assert.False(t, IsEvictedPod(pod))
+
+	pod.Status.Phase = corev1.PodRunning
+	assert.False(t, IsFailedPodWithReason(pod, []string{"Preempted"}))
+}
@@ -26,15 +26,27 @@ package kube
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"time"
 
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
 )
 
+const kindDeployment = "Deployment"
+
 // ReplicaSetStatus represents the status of a replica set.
 type ReplicaSetStatus struct {
 	Owners map[types.UID]int
+	// Rollouts records whether each owning deployment is rolling out, keyed by the deployment UID.
+	// It is only set by NewReplicaSetStatusByDeployment.
+	Rollouts map[types.UID]bool
 }
 
 // NewReplicaSetStatus returns a new instance of ReplicaSetStatus interface.
@@ -52,13 +64,62 @@ func NewReplicaSetStatus(rs []*appsv1.ReplicaSet) ReplicaSetStatus {
 	return ret
 }
 
+// NewReplicaSetStatusByDeployment returns a new instance of ReplicaSetStatus like NewReplicaSetStatus,
+// and also fetches each owning deployment once to record its rollout state.
+// IsRollingUpdating uses the recorded state instead of the owner count for those deployments.
+func NewReplicaSetStatusByDeployment(ctx context.Context, client kubernetes.Interface, rs []*appsv1.ReplicaSet) (ReplicaSetStatus, error) {
+	ret := NewReplicaSetStatus(rs)
+	ret.Rollouts = map[types.UID]bool{}
+	fetched := map[types.NamespacedName]bool{}
+	for _, r := range rs {
+		for _, o := range r.OwnerReferences {
+			key := types.NamespacedName{Namespace: r.Namespace, Name: o.Name}
+			if o.Kind != kindDeployment || fetched[key] {
+				continue
+			}
+			fetched[key] = true
+			dep, err := client.AppsV1().Deployments(r.Namespace).Get(ctx, o.Name, metav1.GetOptions{})
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			if err != nil {
+				return ret, fmt.Errorf("failed to get deployment %s: %w", key, err)
+			}
+			ret.Rollouts[dep.UID] = IsDeploymentRollingOut(dep)
+		}
+	}
+	return ret, nil
+}
+
+// IsDeploymentRollingOut checks if the deployment has not completed its rollout yet,
+// in the same way as kubectl rollout status.
+func IsDeploymentRollingOut(dep *appsv1.Deployment) bool {
+	if dep.Generation > dep.Status.ObservedGeneration {
+		return true
+	}
+	replicas := int32(1)
+	if dep.Spec.Replicas != nil {
+		replicas = *dep.Spec.Replicas
+	}
+	status := dep.Status
+	return status.UpdatedReplicas < replicas || status.Replicas > status.UpdatedReplicas ||
+		status.AvailableReplicas < status.UpdatedReplicas
+}
+
 // IsRollingUpdating checks if a ReplicaSet is undergoing rolling updates.
 // It takes a context and a ReplicaSet as parameters.
-// It iterates over the OwnerReferences of the ReplicaSet and checks if any of the OwnerReferences have more than one occurrence in the Owners map.
+// For owners with a recorded rollout state, it returns that state.
+// Otherwise, it iterates over the OwnerReferences of the ReplicaSet and checks if any of the OwnerReferences have more than one occurrence in the Owners map.
 // If it finds such an OwnerReference, it returns true.
 // Otherwise, it returns false.
 func (u *ReplicaSetStatus) IsRollingUpdating(_ context.Context, rs *appsv1.ReplicaSet) bool {
 	for _, o := range rs.OwnerReferences {
+		if rolling, ok := u.Rollouts[o.UID]; ok {
+			if rolling {
+				return true
+			}
+			continue
+		}
 		if u.Owners[o.UID] > 1 {
 			return true
 		}
@@ -98,3 +159,108 @@ func IsPodOwnedBy(rs *appsv1.ReplicaSet, po *corev1.Pod) bool {
 	}
 	return false
 }
+
+// LastRebalancedAnnotation is the replica set annotation that records when it was last rebalanced.
+const LastRebalancedAnnotation = "watchdogs.norseto.org/last-rebalanced"
+
+// GetLastRebalanced returns the time recorded in the LastRebalancedAnnotation of the replica set.
+// It returns false if the annotation is missing or malformed.
+func GetLastRebalanced(rs *appsv1.ReplicaSet) (time.Time, bool) {
+	if rs == nil {
+		return time.Time{}, false
+	}
+	v, ok := rs.Annotations[LastRebalancedAnnotation]
+	if !ok {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+// MarkRebalanced records the time in the LastRebalancedAnnotation of the replica set with a merge patch.
+func MarkRebalanced(ctx context.Context, client kubernetes.Interface, rs *appsv1.ReplicaSet, at time.Time) error {
+	patch := map[string]any{"metadata": map[string]any{"annotations": map[string]string{
+		LastRebalancedAnnotation: at.UTC().Format(time.RFC3339),
+	}}}
+	data, err := json.Marshal(patch)
+	if err != nil {
+		return fmt.Errorf("failed to make rebalanced patch: %w", err)
+	}
+	_, err = client.AppsV1().ReplicaSets(rs.Namespace).Patch(ctx, rs.Name, types.MergePatchType, data, metav1.PatchOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to mark replicaset %s/%s rebalanced: %w", rs.Namespace, rs.Name, err)
+	}
+	return nil
+}
+
+// listReplicaSetPods lists the pods owned by the replica set, using its selector if any.
+func listReplicaSetPods(ctx context.Context, client kubernetes.Interface, rs *appsv1.ReplicaSet) ([]corev1.Pod, error) {
+	opts := metav1.ListOptions{}
+	if rs.Spec.Selector != nil {
+		selector, err := metav1.LabelSelectorAsSelector(rs.Spec.Selector)
+		if err != nil {
+			return nil, fmt.Errorf("invalid selector of replicaset %s/%s: %w", rs.Namespace, rs.Name, err)
+		}
+		opts.LabelSelector = selector.String()
+	}
+	pods, err := client.CoreV1().Pods(rs.Namespace).List(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	var ret []corev1.Pod
+	for _, po := range pods.Items {
+		if IsPodOwnedBy(rs, &po) {
+			ret = append(ret, po)
+		}
+	}
+	return ret, nil
+}
+
+// ReplicaSetPodUIDs returns the UIDs of the pods currently owned by the replica set.
+func ReplicaSetPodUIDs(ctx context.Context, client kubernetes.Interface, rs *appsv1.ReplicaSet) (map[types.UID]bool, error) {
+	pods, err := listReplicaSetPods(ctx, client, rs)
+	if err != nil {
+		return nil, err
+	}
+	ret := make(map[types.UID]bool, len(pods))
+	for _, po := range pods {
+		ret[po.UID] = true
+	}
+	return ret, nil
+}
+
+// WaitForReplacementPod polls the pods of the replica set every interval until one of them that
+// is not in known is ready and running, e.g. the replacement of a deleted pod. It returns an error
+// when the context is done first or the pods cannot be listed.
+func WaitForReplacementPod(ctx context.Context, client kubernetes.Interface, rs *appsv1.ReplicaSet, known map[types.UID]bool, interval time.Duration) error {
+	err := wait.PollUntilContextCancel(ctx, interval, true, func(ctx context.Context) (bool, error) {
+		pods, err := listReplicaSetPods(ctx, client, rs)
+		if err != nil {
+			return false, err
+		}
+		for i := range pods {
+			po := &pods[i]
+			if !known[po.UID] && !IsPodTerminating(po) && IsPodReadyRunning(*po) {
+				return true, nil
+			}
+		}
+		return false, nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to wait for a replacement pod of replicaset %s/%s: %w", rs.Namespace, rs.Name, err)
+	}
+	return nil
+}
+
+// IsScaledDownReplicaSet checks if a given ReplicaSet neither wants nor has any pods, e.g. an old
+// revision of a deployment. ReplicaSets managed by a controller other than a Deployment are excluded.
+func IsScaledDownReplicaSet(rs *appsv1.ReplicaSet) bool {
+	if rs.Spec.Replicas == nil || *rs.Spec.Replicas != 0 || rs.Status.Replicas != 0 {
+		return false
+	}
+	owner := metav1.GetControllerOf(rs)
+	return owner == nil || owner.Kind == kindDeployment
+}
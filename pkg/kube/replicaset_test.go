@@ -27,12 +27,14 @@ package kube
 import (
 	"context"
 	"testing"
+	"time"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -103,6 +105,53 @@ func TestReplicaSetStatus_IsRollingUpdating(t *testing.T) {
 	assert.False(t, rsStatus.IsRollingUpdating(context.Background(), rs))
 }
 
+func TestNewReplicaSetStatusByDeployment(t *testing.T) {
+	ctx := context.Background()
+	newRS := func(name string, replicas int32, owner *appsv1.Deployment) *appsv1.ReplicaSet {
+		return &appsv1.ReplicaSet{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", OwnerReferences: []metav1.OwnerReference{
+				{Kind: "Deployment", Name: owner.Name, UID: owner.UID},
+			}},
+			Spec: appsv1.ReplicaSetSpec{Replicas: &replicas},
+		}
+	}
+	// The old replicaset is already scaled down, but the new pods are not available yet.
+	rolling := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "rolling", Namespace: "default", UID: "rolling", Generation: 2},
+		Spec:       appsv1.DeploymentSpec{Replicas: int32Ptr(3)},
+		Status: appsv1.DeploymentStatus{ObservedGeneration: 2, Replicas: 3, UpdatedReplicas: 3,
+			AvailableReplicas: 1},
+	}
+	complete := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "complete", Namespace: "default", UID: "complete", Generation: 1},
+		Spec:       appsv1.DeploymentSpec{Replicas: int32Ptr(2)},
+		Status: appsv1.DeploymentStatus{ObservedGeneration: 1, Replicas: 2, UpdatedReplicas: 2,
+			AvailableReplicas: 2},
+	}
+	replicas := []*appsv1.ReplicaSet{
+		newRS("rolling-old", 0, rolling), newRS("rolling-new", 3, rolling),
+		newRS("complete-1", 2, complete),
+	}
+	client := fake.NewSimpleClientset(rolling, complete)
+
+	heuristic := NewReplicaSetStatus(replicas)
+	assert.False(t, heuristic.IsRollingUpdating(ctx, replicas[1]))
+	assert.False(t, heuristic.IsRollingUpdating(ctx, replicas[2]))
+
+	accurate, err := NewReplicaSetStatusByDeployment(ctx, client, replicas)
+	assert.NoError(t, err)
+	assert.True(t, accurate.IsRollingUpdating(ctx, replicas[1]))
+	assert.False(t, accurate.IsRollingUpdating(ctx, replicas[2]))
+
+	gets := 0
+	for _, a := range client.Actions() {
+		if a.GetVerb() == "get" && a.GetResource().Resource == "deployments" {
+			gets++
+		}
+	}
+	assert.Equal(t, 2, gets)
+}
+
 func TestIsPodOwnedBy(t *testing.T) {
 	rs := &appsv1.ReplicaSet{
 		ObjectMeta: metav1.ObjectMeta{UID: types.UID("owner-1")},
@@ -130,3 +179,86 @@ func TestIsPodOwnedBy(t *testing.T) {
 func int32Ptr(i int32) *int32 {
 	return &i
 }
+
+func TestMarkRebalanced(t *testing.T) {
+	ctx := context.Background()
+	rs := &appsv1.ReplicaSet{ObjectMeta: metav1.ObjectMeta{Name: "rs", Namespace: "default"}}
+	client := fake.NewSimpleClientset(rs)
+
+	_, ok := GetLastRebalanced(rs)
+	assert.False(t, ok)
+
+	at := time.Date(2024, 1, 2, 3, 4, 5, 0, time.FixedZone("JST", 9*60*60))
+	assert.NoError(t, MarkRebalanced(ctx, client, rs, at))
+	got, err := client.AppsV1().ReplicaSets("default").Get(ctx, "rs", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "2024-01-01T18:04:05Z", got.Annotations[LastRebalancedAnnotation])
+	last, ok := GetLastRebalanced(got)
+	assert.True(t, ok)
+	assert.True(t, at.Equal(last))
+
+	got.Annotations[LastRebalancedAnnotation] = "yesterday"
+	_, ok = GetLastRebalanced(got)
+	assert.False(t, ok)
+
+	assert.Error(t, MarkRebalanced(ctx, client, &appsv1.ReplicaSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "missing", Namespace: "default"}}, at))
+}
+
+func TestWaitForReplacementPod(t *testing.T) {
+	rs := &appsv1.ReplicaSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default", UID: "rs-uid"},
+		Spec:       appsv1.ReplicaSetSpec{Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}}},
+	}
+	newPod := func(name string, ready bool, labels map[string]string) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", UID: types.UID(name), Labels: labels,
+				OwnerReferences: []metav1.OwnerReference{{UID: rs.UID}}},
+			Status: corev1.PodStatus{Phase: corev1.PodRunning,
+				ContainerStatuses: []corev1.ContainerStatus{{Ready: ready}}},
+		}
+	}
+	labels := map[string]string{"app": "web"}
+	known := map[types.UID]bool{"pod-1": true}
+	wait := func(objects ...*corev1.Pod) error {
+		client := fake.NewSimpleClientset()
+		for _, o := range objects {
+			_ = client.Tracker().Add(o)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		return WaitForReplacementPod(ctx, client, rs, known, time.Millisecond)
+	}
+
+	assert.NoError(t, wait(newPod("pod-1", true, labels), newPod("pod-2", true, labels)))
+	// Known pods, not ready pods and pods not matching the selector are not replacements.
+	assert.Error(t, wait(newPod("pod-1", true, labels)))
+	assert.Error(t, wait(newPod("pod-1", true, labels), newPod("pod-2", false, labels)))
+	assert.Error(t, wait(newPod("pod-1", true, labels), newPod("pod-2", true, nil)))
+
+	client := fake.NewSimpleClientset(newPod("pod-1", false, labels), newPod("pod-2", true, nil))
+	uids, err := ReplicaSetPodUIDs(context.Background(), client, rs)
+	assert.NoError(t, err)
+	assert.Equal(t, known, uids)
+}
+
+func TestIsScaledDownReplicaSet(t *testing.T) {
+	controller := true
+	rs := func(spec *int32, status int32, owner string) *appsv1.ReplicaSet {
+		r := &appsv1.ReplicaSet{
+			Spec:   appsv1.ReplicaSetSpec{Replicas: spec},
+			Status: appsv1.ReplicaSetStatus{Replicas: status},
+		}
+		if owner != "" {
+			r.OwnerReferences = []metav1.OwnerReference{{Kind: owner, Name: "owner", Controller: &controller}}
+		}
+		return r
+	}
+
+	assert.True(t, IsScaledDownReplicaSet(rs(int32Ptr(0), 0, "Deployment")))
+	assert.True(t, IsScaledDownReplicaSet(rs(int32Ptr(0), 0, "")))
+	assert.False(t, IsScaledDownReplicaSet(rs(int32Ptr(0), 1, "Deployment")), "still terminating pods")
+	assert.False(t, IsScaledDownReplicaSet(rs(int32Ptr(1), 1, "Deployment")))
+	assert.False(t, IsScaledDownReplicaSet(rs(nil, 0, "Deployment")), "nil replicas default to 1")
+	assert.False(t, IsScaledDownReplicaSet(rs(int32Ptr(0), 0, "Rollout")))
+}
@@ -0,0 +1,171 @@
+/*
+MIT License
+
+Copyright (c) 2024 Norihiro Seto
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package kube
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/norseto/k8s-watchdogs/pkg/logger"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Kinds of resources that can be restarted by RestartResourcesInNamespace.
+const (
+	RestartKindDeployment  = "deployment"
+	RestartKindStatefulSet = "statefulset"
+	RestartKindDaemonSet   = "daemonset"
+)
+
+// RestartableKinds is the list of kinds that can be restarted by RestartResourcesInNamespace.
+var RestartableKinds = []string{RestartKindDeployment, RestartKindStatefulSet, RestartKindDaemonSet}
+
+// SkipAlreadyRestarted is the skip reason of a workload whose restart annotation already
+// equals the current timestamp, e.g. on a rerun within the same second.
+const SkipAlreadyRestarted = "AlreadyRestarted"
+
+// RestartSummary counts the outcomes of restarting workloads.
+type RestartSummary struct {
+	Restarted, Skipped, Errored int
+}
+
+// Add counts the outcome of a restart, e.g. of RestartDeployment.
+func (s *RestartSummary) Add(restarted bool, err error) {
+	switch {
+	case err != nil:
+		s.Errored++
+	case restarted:
+		s.Restarted++
+	default:
+		s.Skipped++
+	}
+}
+
+// Log logs the counts of the outcomes at info level.
+func (s *RestartSummary) Log(ctx context.Context, opts ...RestartOption) {
+	logger.FromContext(ctx).Info("restart summary", "restarted", s.Restarted, "skipped", s.Skipped,
+		"errored", s.Errored, "dryRun", IsRestartDryRun(opts...))
+}
+
+// RestartStatefulSet restarts a statefulset by updating its template metadata annotations with the current time.
+// Like RestartDeployment, it returns false without patching when the restart would be a no-op.
+func RestartStatefulSet(ctx context.Context, client kubernetes.Interface, sts *appsv1.StatefulSet, opts ...RestartOption) (bool, error) {
+	patchType, data, err := makeRestartPatch(kindStatefulSet, sts, sts.Spec.Template.Annotations, opts)
+	if data == nil || err != nil {
+		return false, err
+	}
+	_, err = client.AppsV1().StatefulSets(sts.Namespace).Patch(ctx, sts.Name,
+		patchType, data, makeRestartPatchOptions(opts))
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// RestartDaemonSet restarts a daemonset by updating its template metadata annotations with the current time.
+// Like RestartDeployment, it returns false without patching when the restart would be a no-op.
+func RestartDaemonSet(ctx context.Context, client kubernetes.Interface, ds *appsv1.DaemonSet, opts ...RestartOption) (bool, error) {
+	patchType, data, err := makeRestartPatch(kindDaemonSet, ds, ds.Spec.Template.Annotations, opts)
+	if data == nil || err != nil {
+		return false, err
+	}
+	_, err = client.AppsV1().DaemonSets(ds.Namespace).Patch(ctx, ds.Name,
+		patchType, data, makeRestartPatchOptions(opts))
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// RestartResourcesInNamespace restarts every resource of the given kinds in a namespace.
+// Kinds are case-insensitive and must be in RestartableKinds.
+// It keeps going when a resource fails to restart and returns all errors joined.
+func RestartResourcesInNamespace(ctx context.Context, client kubernetes.Interface, namespace string, kinds []string, opts ...RestartOption) error {
+	var errs []error
+	for _, kind := range kinds {
+		var err error
+		switch strings.ToLower(kind) {
+		case RestartKindDeployment:
+			err = restartDeployments(ctx, client, namespace, opts)
+		case RestartKindStatefulSet:
+			err = restartStatefulSets(ctx, client, namespace, opts)
+		case RestartKindDaemonSet:
+			err = restartDaemonSets(ctx, client, namespace, opts)
+		default:
+			err = fmt.Errorf("unsupported kind %q, must be one of %v", kind, RestartableKinds)
+		}
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+func restartDeployments(ctx context.Context, client kubernetes.Interface, namespace string, opts []RestartOption) error {
+	list, err := client.AppsV1().Deployments(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to list deployments: %w", err)
+	}
+	var errs []error
+	for i := range list.Items {
+		item := &list.Items[i]
+		if _, err := RestartDeployment(ctx, client, item, opts...); err != nil {
+			errs = append(errs, fmt.Errorf("failed to restart deployment %s: %w", item.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func restartStatefulSets(ctx context.Context, client kubernetes.Interface, namespace string, opts []RestartOption) error {
+	list, err := client.AppsV1().StatefulSets(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to list statefulsets: %w", err)
+	}
+	var errs []error
+	for i := range list.Items {
+		item := &list.Items[i]
+		if _, err := RestartStatefulSet(ctx, client, item, opts...); err != nil {
+			errs = append(errs, fmt.Errorf("failed to restart statefulset %s: %w", item.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func restartDaemonSets(ctx context.Context, client kubernetes.Interface, namespace string, opts []RestartOption) error {
+	list, err := client.AppsV1().DaemonSets(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to list daemonsets: %w", err)
+	}
+	var errs []error
+	for i := range list.Items {
+		item := &list.Items[i]
+		if _, err := RestartDaemonSet(ctx, client, item, opts...); err != nil {
+			errs = append(errs, fmt.Errorf("failed to restart daemonset %s: %w", item.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
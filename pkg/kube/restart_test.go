@@ -0,0 +1,99 @@
+/*
+MIT License
+
+Copyright (c) 2024 Norihiro Seto
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package kube
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/go-logr/logr/funcr"
+	"github.com/norseto/k8s-watchdogs/pkg/logger"
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestRestartResourcesInNamespace(t *testing.T) {
+	ctx := context.TODO()
+	meta := func(name string) metav1.ObjectMeta {
+		return metav1.ObjectMeta{Name: name, Namespace: "test-namespace"}
+	}
+	newClient := func() *fake.Clientset {
+		return fake.NewSimpleClientset(
+			&appsv1.Deployment{ObjectMeta: meta("deploy")},
+			&appsv1.StatefulSet{ObjectMeta: meta("sts")},
+			&appsv1.DaemonSet{ObjectMeta: meta("ds")},
+			&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "other"}},
+		)
+	}
+	patched := func(client *fake.Clientset) []string {
+		var result []string
+		for _, a := range client.Actions() {
+			if a.GetVerb() == "patch" {
+				result = append(result, a.GetResource().Resource)
+			}
+		}
+		return result
+	}
+
+	client := newClient()
+	err := RestartResourcesInNamespace(ctx, client, "test-namespace", RestartableKinds)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"deployments", "statefulsets", "daemonsets"}, patched(client))
+
+	sts, err := client.AppsV1().StatefulSets("test-namespace").Get(ctx, "sts", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Contains(t, sts.Spec.Template.Annotations, restartedAtAnnotation)
+	ds, err := client.AppsV1().DaemonSets("test-namespace").Get(ctx, "ds", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Contains(t, ds.Spec.Template.Annotations, restartedAtAnnotation)
+
+	client = newClient()
+	err = RestartResourcesInNamespace(ctx, client, "test-namespace", []string{"StatefulSet"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"statefulsets"}, patched(client))
+
+	client = newClient()
+	err = RestartResourcesInNamespace(ctx, client, "test-namespace", []string{"cronjob", "daemonset"})
+	assert.ErrorContains(t, err, "unsupported kind")
+	assert.Equal(t, []string{"daemonsets"}, patched(client))
+}
+
+func TestRestartSummary(t *testing.T) {
+	s := &RestartSummary{}
+	s.Add(true, nil)
+	s.Add(true, nil)
+	s.Add(false, nil)
+	s.Add(false, errors.New("conflict"))
+	s.Add(false, errors.New("conflict"))
+	assert.Equal(t, RestartSummary{Restarted: 2, Skipped: 1, Errored: 2}, *s)
+
+	var lines []string
+	log := funcr.New(func(prefix, args string) { lines = append(lines, args) }, funcr.Options{})
+	s.Log(logger.WithContext(context.Background(), log), WithRestartDryRun(true))
+	assert.Equal(t, []string{`"level"=0 "msg"="restart summary" "restarted"=2 "skipped"=1 "errored"=2 "dryRun"=true`}, lines)
+}
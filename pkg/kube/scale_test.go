@@ -0,0 +1,55 @@
+/*
+MIT License
+
+Copyright (c) 2024 Norihiro Seto
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package kube
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestScaleDeployment(t *testing.T) {
+	ctx := context.Background()
+	one := int32(1)
+	meta := metav1.ObjectMeta{Name: "test", Namespace: "default"}
+	client := fake.NewSimpleClientset(
+		&appsv1.Deployment{ObjectMeta: meta, Spec: appsv1.DeploymentSpec{Replicas: &one}},
+		&appsv1.StatefulSet{ObjectMeta: meta, Spec: appsv1.StatefulSetSpec{Replicas: &one}},
+	)
+
+	assert.NoError(t, ScaleDeployment(ctx, client, "default", "test", 3))
+	dep, _ := client.AppsV1().Deployments("default").Get(ctx, "test", metav1.GetOptions{})
+	assert.Equal(t, int32(3), *dep.Spec.Replicas)
+
+	assert.NoError(t, ScaleStatefulSet(ctx, client, "default", "test", 0))
+	sts, _ := client.AppsV1().StatefulSets("default").Get(ctx, "test", metav1.GetOptions{})
+	assert.Equal(t, int32(0), *sts.Spec.Replicas)
+
+	assert.Error(t, ScaleDeployment(ctx, client, "default", "missing", 1))
+}
@@ -0,0 +1,60 @@
+/*
+MIT License
+
+Copyright (c) 2024 Norihiro Seto
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package kube
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"k8s.io/client-go/kubernetes"
+)
+
+// ServerAtLeast checks if the version of the API server is at least major.minor.
+// Minor versions with a provider suffix such as "27+" are accepted.
+func ServerAtLeast(_ context.Context, client kubernetes.Interface, major, minor int) (bool, error) {
+	ver, err := client.Discovery().ServerVersion()
+	if err != nil {
+		return false, fmt.Errorf("failed to get server version: %w", err)
+	}
+	srvMajor, err := parseVersionNumber(ver.Major)
+	if err != nil {
+		return false, fmt.Errorf("invalid server major version %q: %w", ver.Major, err)
+	}
+	srvMinor, err := parseVersionNumber(ver.Minor)
+	if err != nil {
+		return false, fmt.Errorf("invalid server minor version %q: %w", ver.Minor, err)
+	}
+	if srvMajor != major {
+		return srvMajor > major, nil
+	}
+	return srvMinor >= minor, nil
+}
+
+// parseVersionNumber parses a version number ignoring a trailing "+".
+func parseVersionNumber(v string) (int, error) {
+	return strconv.Atoi(strings.TrimSuffix(v, "+"))
+}
@@ -0,0 +1,63 @@
+/*
+MIT License
+
+Copyright (c) 2024 Norihiro Seto
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package kube
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/version"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestServerAtLeast(t *testing.T) {
+	ctx := context.Background()
+	newClient := func(major, minor string) *fake.Clientset {
+		client := fake.NewSimpleClientset()
+		client.Discovery().(*fakediscovery.FakeDiscovery).FakedServerVersion = &version.Info{Major: major, Minor: minor}
+		return client
+	}
+
+	tests := []struct {
+		major, minor string
+		want         bool
+		wantErr      bool
+	}{
+		{"1", "22", true, false},
+		{"1", "30", true, false},
+		{"1", "27+", true, false},
+		{"1", "21", false, false},
+		{"2", "0", true, false},
+		{"0", "99", false, false},
+		{"1", "", false, true},
+	}
+	for _, tt := range tests {
+		got, err := ServerAtLeast(ctx, newClient(tt.major, tt.minor), 1, 22)
+		assert.Equal(t, tt.wantErr, err != nil, "%s.%s", tt.major, tt.minor)
+		assert.Equal(t, tt.want, got, "%s.%s", tt.major, tt.minor)
+	}
+}
@@ -33,6 +33,8 @@ import (
 	"github.com/go-logr/logr"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
+	uzap "go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 	clog "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 )
@@ -75,14 +77,42 @@ func setupLogger(opts *zap.Options, cmd *cobra.Command) {
 	for root.HasParent() {
 		root = root.Parent()
 	}
-	cmdline := makeCommandLine(root.PersistentFlags())
-	flagSet := flag.NewFlagSet(cmdline[0], flag.ContinueOnError)
+	flagSet := flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
 	opts.BindFlags(flagSet)
+	format := flagSet.String(logFormatFlag, "", "")
+	quiet := flagSet.Bool(quietFlag, false, "")
+	cmdline := makeCommandLine(root.PersistentFlags(), flagSet)
 	_ = flagSet.Parse(cmdline[1:])
+	formatErr := applyLogFormat(opts, *format)
+	if *quiet {
+		opts.Level = zapcore.ErrorLevel
+	}
 	logger := zap.New(zap.UseFlagOptions(opts))
+	if formatErr != nil {
+		logger.Error(formatErr, "ignored log format")
+	}
 	cmd.SetContext(WithContext(cmd.Context(), logger))
 }
 
+// applyLogFormat sets the encoder of the zap options by the log format.
+// It overrides the encoder set by zap-encoder. An empty format keeps the options as is.
+func applyLogFormat(opts *zap.Options, format string) error {
+	switch format {
+	case "":
+	case "json":
+		opts.NewEncoder = func(eco ...zap.EncoderConfigOption) zapcore.Encoder {
+			return zapcore.NewJSONEncoder(encoderConfig(uzap.NewProductionEncoderConfig(), eco))
+		}
+	case "console":
+		opts.NewEncoder = func(eco ...zap.EncoderConfigOption) zapcore.Encoder {
+			return zapcore.NewConsoleEncoder(encoderConfig(uzap.NewDevelopmentEncoderConfig(), eco))
+		}
+	default:
+		return fmt.Errorf("invalid log format %q, must be one of 'json' or 'console'", format)
+	}
+	return nil
+}
+
 // makeCmdValue generates a key for a given cmd *cobra.Command object.
 func makeCmdValue(cmd *cobra.Command) string {
 	if cmd.HasParent() {
@@ -121,8 +151,24 @@ func WithContext(ctx context.Context, log logr.Logger) context.Context {
 	return clog.IntoContext(ctx, log)
 }
 
+// encoderConfig applies the encoder config options to the config.
+func encoderConfig(config zapcore.EncoderConfig, opts []zap.EncoderConfigOption) zapcore.EncoderConfig {
+	for _, opt := range opts {
+		opt(&config)
+	}
+	return config
+}
+
+const (
+	logFormatFlag = "log-format"
+	quietFlag     = "quiet"
+)
+
 // bindPFlags setups zap log options
 func bindPFlags(o *zap.Options, fs *pflag.FlagSet) {
+	fs.String(logFormatFlag, "", "Log format (one of 'json' or 'console'). Overrides zap-encoder")
+	fs.Bool(quietFlag, false, "Suppress info logs and only output errors. Overrides zap-log-level")
+
 	// Set Development mode value
 	fs.Bool("zap-devel", o.Development,
 		"Development Mode defaults(encoder=consoleEncoder,logLevel=Debug,stackTraceLevel=Warn). "+
@@ -149,12 +195,13 @@ func bindPFlags(o *zap.Options, fs *pflag.FlagSet) {
 	_ = fs.MarkHidden("zap-time-encoding")
 }
 
-// makeCommandLine makes command lines from FlagSet values
-func makeCommandLine(fs *pflag.FlagSet) []string {
+// makeCommandLine makes command lines from FlagSet values.
+// Only the flags defined in the logger FlagSet are included.
+func makeCommandLine(fs *pflag.FlagSet, logFlags *flag.FlagSet) []string {
 	result := []string{os.Args[0]}
 
 	fs.VisitAll(func(f *pflag.Flag) {
-		if f.Changed {
+		if f.Changed && logFlags.Lookup(f.Name) != nil {
 			result = append(result, fmt.Sprintf("--%s=%v", f.Name, f.Value))
 		}
 	})
@@ -0,0 +1,107 @@
+/*
+MIT License
+
+Copyright (c) 2024 Norihiro Seto
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package logger
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap/zapcore"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+)
+
+// encode encodes a log entry with the encoder of the options.
+func encode(t *testing.T, opts *zap.Options) string {
+	t.Helper()
+	if !assert.NotNil(t, opts.NewEncoder) {
+		return ""
+	}
+	enc := opts.NewEncoder()
+	buf, err := enc.EncodeEntry(zapcore.Entry{Message: "hello", Time: time.Unix(0, 0)}, nil)
+	assert.NoError(t, err)
+	return buf.String()
+}
+
+func TestApplyLogFormat(t *testing.T) {
+	opts := &zap.Options{}
+	assert.NoError(t, applyLogFormat(opts, ""))
+	assert.Nil(t, opts.NewEncoder)
+
+	assert.NoError(t, applyLogFormat(opts, "json"))
+	assert.True(t, strings.HasPrefix(encode(t, opts), "{"))
+
+	assert.NoError(t, applyLogFormat(opts, "console"))
+	assert.False(t, strings.HasPrefix(encode(t, opts), "{"))
+
+	assert.Error(t, applyLogFormat(opts, "xml"))
+}
+
+func TestSetupLogger_LogFormatWins(t *testing.T) {
+	opts := &zap.Options{}
+	root := &cobra.Command{Use: "root"}
+	root.SetContext(context.Background())
+	bindPFlags(opts, root.PersistentFlags())
+	assert.NoError(t, root.PersistentFlags().Parse([]string{"--zap-encoder=json", "--log-format=console"}))
+
+	setupLogger(opts, root)
+	assert.False(t, strings.HasPrefix(encode(t, opts), "{"))
+}
+
+func TestSetupLogger_IgnoresOtherFlags(t *testing.T) {
+	opts := &zap.Options{}
+	root := &cobra.Command{Use: "root"}
+	root.SetContext(context.Background())
+	bindPFlags(opts, root.PersistentFlags())
+	root.PersistentFlags().StringP("output", "o", "text", "")
+	assert.NoError(t, root.PersistentFlags().Parse([]string{"--output=json", "--log-format=json"}))
+
+	setupLogger(opts, root)
+	assert.True(t, strings.HasPrefix(encode(t, opts), "{"))
+}
+
+func TestSetupLogger_Quiet(t *testing.T) {
+	buf := &bytes.Buffer{}
+	opts := &zap.Options{DestWriter: buf}
+	root := &cobra.Command{Use: "root"}
+	root.SetContext(context.Background())
+	bindPFlags(opts, root.PersistentFlags())
+	assert.NoError(t, root.PersistentFlags().Parse([]string{"--zap-log-level=debug", "--quiet"}))
+
+	setupLogger(opts, root)
+	log := FromContext(root.Context())
+	log.Info("info message")
+	log.V(1).Info("debug message")
+	log.Error(errors.New("boom"), "error message")
+
+	assert.NotContains(t, buf.String(), "info message")
+	assert.NotContains(t, buf.String(), "debug message")
+	assert.Contains(t, buf.String(), "error message")
+}